@@ -26,11 +26,11 @@ contract SlotValueExample is BrevisAppZkOnly, Ownable {
     // In guest circuit we have:
     // api.OutputAddress(s.Contract)
     // api.OutputAddress(owner)
-    // api.OutputUint(64, s.BlockNum)
+    // api.OutputUint32(32, s.BlockNum)
     function decodeOutput(bytes calldata o) internal pure returns (address, address, uint64) {
         address contractAddr = address(bytes20(o[0:20]));
         address ownerAddr = address(bytes20(o[20:40]));
-        uint64 blockNum = uint64(bytes8(o[40:48]));
+        uint64 blockNum = uint64(uint32(bytes4(o[40:44])));
         return (contractAddr, ownerAddr, blockNum);
     }
 
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.18;
+
+// Verifies proofs of the output Merkle trees built by sdk.OutputMerkleRoot in
+// the app circuit. The app contract stores the root it receives in
+// handleProofResult, along with the depth of the tree (log2 of its size), and
+// users claim their values with the proofs written by
+// CircuitInput.WriteOutputMerkleTrees.
+library OutputMerkleProof {
+    bytes1 constant LEAF_PREFIX = 0x00;
+    bytes1 constant NODE_PREFIX = 0x01;
+
+    function leafHash(bytes32 key, bytes32 value) internal pure returns (bytes32) {
+        return keccak256(abi.encodePacked(LEAF_PREFIX, key, value));
+    }
+
+    function nodeHash(bytes32 left, bytes32 right) internal pure returns (bytes32) {
+        return keccak256(abi.encodePacked(NODE_PREFIX, left, right));
+    }
+
+    function verify(
+        bytes32 root,
+        uint256 depth,
+        bytes32 key,
+        bytes32 value,
+        uint256 index,
+        bytes32[] calldata proof
+    ) internal pure returns (bool) {
+        if (proof.length != depth) {
+            return false;
+        }
+        bytes32 hash = leafHash(key, value);
+        for (uint256 i = 0; i < proof.length; i++) {
+            if (index % 2 == 0) {
+                hash = nodeHash(hash, proof[i]);
+            } else {
+                hash = nodeHash(proof[i], hash);
+            }
+            index /= 2;
+        }
+        return index == 0 && hash == root;
+    }
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.18;
+
+interface IBrevisApp {
+    function brevisCallback(bytes32 _appVkHash, bytes calldata _appCircuitOutput) external;
+
+    function brevisBatchCallback(bytes32[] calldata _appVkHashes, bytes[] calldata _appCircuitOutputs) external;
+}
+
+// Stands in for BrevisRequest in tests: anyone can deliver circuit outputs to
+// an app without a proof, and OP results are valid unless set otherwise.
+contract MockBrevisRequest {
+    bool public opAppDataValid = true;
+
+    function fulfillRequest(address _app, bytes32 _appVkHash, bytes calldata _appCircuitOutput) external {
+        IBrevisApp(_app).brevisCallback(_appVkHash, _appCircuitOutput);
+    }
+
+    function fulfillRequests(
+        address _app,
+        bytes32[] calldata _appVkHashes,
+        bytes[] calldata _appCircuitOutputs
+    ) external {
+        IBrevisApp(_app).brevisBatchCallback(_appVkHashes, _appCircuitOutputs);
+    }
+
+    function setOpAppDataValid(bool _valid) external {
+        opAppDataValid = _valid;
+    }
+
+    function validateOpAppData(
+        bytes32,
+        uint64,
+        bytes32,
+        bytes32,
+        uint256,
+        uint8
+    ) external view returns (bool) {
+        return opAppDataValid;
+    }
+
+    function validateOpAppData(
+        bytes32[] calldata,
+        uint64[] calldata,
+        bytes32[] calldata,
+        bytes32[] calldata,
+        uint256,
+        uint8
+    ) external view returns (bool) {
+        return opAppDataValid;
+    }
+}
@@ -32,7 +32,7 @@ func (c *AppCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
 	s := sdk.GetUnderlying(slots, 1)
 	owner := api.ToUint248(s.Value)
 	// Output will be reflected in our contract in the form of
-	// abi.encodePacked(address,address,uint64)
+	// abi.encodePacked(address,address,uint32)
 	api.OutputAddress(s.Contract)
 	api.OutputAddress(owner)
 	api.OutputUint32(32, s.BlockNum)
@@ -22,6 +22,12 @@ type AppCircuit struct {
 // Your guest circuit must implement the sdk.AppCircuit interface
 var _ sdk.AppCircuit = &AppCircuit{}
 
+// Optionally, the guest circuit can declare its outputs by implementing
+// sdk.AppCircuitWithOutputSchema. The outputs added in Define are then checked
+// against the schema when compiling, and a Solidity library for decoding the
+// output can be generated using sdk.WriteSolidityDecoder
+var _ sdk.AppCircuitWithOutputSchema = &AppCircuit{}
+
 // sdk.ParseXXX APIs are used to convert Go/EVM data types into circuit types.
 // Note that you can only use these outside of circuit (making constant circuit
 // variables)
@@ -50,62 +56,30 @@ func (c *AppCircuit) Allocate() (maxReceipts, maxSlots, maxTransactions int) {
 	return 32, 0, 0
 }
 
+func (c *AppCircuit) OutputSchema() sdk.OutputSchema {
+	return sdk.OutputSchema{
+		{Name: "salt", Type: "bytes32"},
+		{Name: "sumVolume", Type: "uint248"},
+		{Name: "minBlockNum", Type: "uint64"},
+		{Name: "user", Type: "address"},
+	}
+}
+
 func (c *AppCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
-	u248 := api.Uint248
+	return c.define(api, in, c.swapTotals)
+}
+
+// totalsFunc aggregates the swaps of the user in the receipts into the minimum
+// block number and the sum of the volumes
+type totalsFunc func(api *sdk.CircuitAPI, receipts *sdk.DataStream[sdk.Receipt]) (minBlockNum, sumVolume sdk.Uint248)
 
+func (c *AppCircuit) define(api *sdk.CircuitAPI, in sdk.DataInput, totals totalsFunc) error {
 	// In order to use the nice methods such as .Map() and .Reduce(), raw data needs
 	// to be wrapped in a DataStream. You could also use the raw data directly if you
 	// are familiar with writing gnark circuits.
 	receipts := sdk.NewDataStream(api, in.Receipts)
 
-	// Main application logic: Run the assert function on each receipt. The function
-	// should return 1 if assertion successes and 0 otherwise
-	sdk.AssertEach(receipts, func(l sdk.Receipt) sdk.Uint248 {
-		// If the recipient field of the Swap event is uniswap router, it means the user
-		// requested native token out. We need to instead check the user's address in the
-		// Transfer event emitted by USDC contract
-		recipientIsRouter := u248.IsEqual(api.ToUint248(l.Fields[1].Value), RouterAddress)
-		// the following line translates to "if recipient is router, then use `from` as
-		// userAddr, else use `recipient`"
-		userAddr := u248.Select(
-			recipientIsRouter, api.ToUint248(l.Fields[2].Value), api.ToUint248(l.Fields[1].Value))
-		// asserts that the following equality checks each results in 1
-		assertionPassed := u248.And(
-			// 1. Check that the user address related to the swaps is consistent across all
-			// receipts
-			u248.IsEqual(userAddr, c.UserAddr),
-			// 2. Check that the contract address of each log field is the expected contract
-			u248.IsEqual(l.Fields[0].Contract, UsdcPoolAddress),
-			u248.IsEqual(l.Fields[1].Contract, UsdcPoolAddress),
-			u248.IsEqual(l.Fields[2].Contract, UsdcAddress),
-			// 3. Check the EventID of the fields are as expected
-			u248.IsEqual(l.Fields[0].EventID, EventIdSwap),
-			u248.IsEqual(l.Fields[1].EventID, EventIdSwap),
-			u248.IsEqual(l.Fields[2].EventID, EventIdTransfer),
-			// 4. Check the index of the fields are as expected
-			u248.IsZero(l.Fields[0].IsTopic),                     // `amount0` is not a topic field
-			u248.IsEqual(l.Fields[0].Index, sdk.ConstUint248(0)), // `amount0` is the 0th data field in the `Swap` event
-			l.Fields[1].IsTopic,                                  // `recipient` is a topic field
-			u248.IsEqual(l.Fields[1].Index, sdk.ConstUint248(2)), // `recipient` is the 2nd topic field in the `Swap` event
-			l.Fields[2].IsTopic,                                  // `from` is a topic field
-			u248.IsEqual(l.Fields[2].Index, sdk.ConstUint248(1)), // `from` is the 1st index field in the `Transfer` event
-		)
-		return assertionPassed
-	})
-
-	blockNums := sdk.Map(receipts, func(cur sdk.Receipt) sdk.Uint248 { return api.ToUint248(cur.BlockNum) })
-
-	volumes := sdk.Map(receipts, func(cur sdk.Receipt) sdk.Uint248 {
-		valInt := api.ToInt248(cur.Fields[0].Value)
-		return api.Int248.ABS(valInt)
-	})
-
-	// Find out the minimum block number. This enables us to find out over what range
-	// the user has a specific trading volume
-	minBlockNum := sdk.Min(blockNums)
-
-	// Sum up the volume of each trade
-	sumVolume := sdk.Sum(volumes)
+	minBlockNum, sumVolume := totals(api, receipts)
 
 	// Output will be reflected in app contract's callback in the form of
 	// _circuitOutput: abi.encodePacked(uint256,uint248,uint64,address)
@@ -117,3 +91,69 @@ func (c *AppCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
 
 	return nil
 }
+
+// swapTotals keeps the swaps of the USDC pool made by the user and aggregates
+// them. sdk.NewPipeline only records the filters and the maps, and fuses them
+// when the pipeline is reduced, which takes fewer constraints than chaining
+// sdk.Filter and sdk.Map.
+func (c *AppCircuit) swapTotals(api *sdk.CircuitAPI, receipts *sdk.DataStream[sdk.Receipt]) (minBlockNum, sumVolume sdk.Uint248) {
+	swaps := sdk.NewPipeline(receipts).
+		Filter(func(l sdk.Receipt) sdk.Uint248 { return isPoolSwap(api, l) }).
+		Filter(func(l sdk.Receipt) sdk.Uint248 { return c.isFromUser(api, l) })
+
+	// Find out the minimum block number. This enables us to find out over what range
+	// the user has a specific trading volume
+	minBlockNum = sdk.Min(sdk.PipeMap(swaps, func(cur sdk.Receipt) sdk.Uint248 {
+		return api.ToUint248(cur.BlockNum)
+	}).Collect())
+
+	// Sum up the volume of each trade
+	sumVolume = sdk.PipeMap(swaps, func(cur sdk.Receipt) sdk.Uint248 {
+		return volumeOf(api, cur)
+	}).Reduce(sdk.ConstUint248(0), func(sum, volume sdk.Uint248) sdk.Uint248 {
+		return api.Uint248.Add(sum, volume)
+	})
+	return
+}
+
+// volumeOf returns the USDC amount of the swap
+func volumeOf(api *sdk.CircuitAPI, l sdk.Receipt) sdk.Uint248 {
+	return api.Int248.ABS(api.ToInt248(l.Fields[0].Value))
+}
+
+// isPoolSwap returns 1 if the receipt is a swap of the USDC pool between USDC
+// and ETH/WETH, with the fields at the expected positions, and 0 otherwise
+func isPoolSwap(api *sdk.CircuitAPI, l sdk.Receipt) sdk.Uint248 {
+	u248 := api.Uint248
+	return u248.And(
+		// 1. Check that the contract address of each log field is the expected contract
+		u248.IsEqual(l.Fields[0].Contract, UsdcPoolAddress),
+		u248.IsEqual(l.Fields[1].Contract, UsdcPoolAddress),
+		u248.IsEqual(l.Fields[2].Contract, UsdcAddress),
+		// 2. Check the EventID of the fields are as expected
+		u248.IsEqual(l.Fields[0].EventID, EventIdSwap),
+		u248.IsEqual(l.Fields[1].EventID, EventIdSwap),
+		u248.IsEqual(l.Fields[2].EventID, EventIdTransfer),
+		// 3. Check the index of the fields are as expected
+		u248.IsZero(l.Fields[0].IsTopic),                     // `amount0` is not a topic field
+		u248.IsEqual(l.Fields[0].Index, sdk.ConstUint248(0)), // `amount0` is the 0th data field in the `Swap` event
+		l.Fields[1].IsTopic,                                  // `recipient` is a topic field
+		u248.IsEqual(l.Fields[1].Index, sdk.ConstUint248(2)), // `recipient` is the 2nd topic field in the `Swap` event
+		l.Fields[2].IsTopic,                                  // `from` is a topic field
+		u248.IsEqual(l.Fields[2].Index, sdk.ConstUint248(1)), // `from` is the 1st index field in the `Transfer` event
+	)
+}
+
+// isFromUser returns 1 if the swap is made by the user and 0 otherwise
+func (c *AppCircuit) isFromUser(api *sdk.CircuitAPI, l sdk.Receipt) sdk.Uint248 {
+	u248 := api.Uint248
+	// If the recipient field of the Swap event is uniswap router, it means the user
+	// requested native token out. We need to instead check the user's address in the
+	// Transfer event emitted by USDC contract
+	recipientIsRouter := u248.IsEqual(api.ToUint248(l.Fields[1].Value), RouterAddress)
+	// the following line translates to "if recipient is router, then use `from` as
+	// userAddr, else use `recipient`"
+	userAddr := u248.Select(
+		recipientIsRouter, api.ToUint248(l.Fields[2].Value), api.ToUint248(l.Fields[1].Value))
+	return u248.IsEqual(userAddr, c.UserAddr)
+}
@@ -0,0 +1,62 @@
+package tradingvolume
+
+import (
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// TestPipelineConstraints compares the constraints of AppCircuit, which chains
+// two filters, a map and a reduction on sdk.Pipeline, to the same circuit built
+// with the eager sdk.Filter, sdk.Map and sdk.Sum. Fusing the filters must save
+// constraints.
+func TestPipelineConstraints(t *testing.T) {
+	eager, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, newAppConstraintsCircuit(false))
+	check(err)
+	pipeline, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, newAppConstraintsCircuit(true))
+	check(err)
+	t.Logf("constraints: eager %d, pipeline %d", eager.GetNbConstraints(), pipeline.GetNbConstraints())
+	if pipeline.GetNbConstraints() >= eager.GetNbConstraints() {
+		t.Errorf("expected the pipeline to use less constraints than eager: %d >= %d",
+			pipeline.GetNbConstraints(), eager.GetNbConstraints())
+	}
+}
+
+// eagerSwapTotals is swapTotals with the eager operators
+func (c *AppCircuit) eagerSwapTotals(api *sdk.CircuitAPI, receipts *sdk.DataStream[sdk.Receipt]) (minBlockNum, sumVolume sdk.Uint248) {
+	swaps := sdk.Filter(receipts, func(l sdk.Receipt) sdk.Uint248 { return isPoolSwap(api, l) })
+	swaps = sdk.Filter(swaps, func(l sdk.Receipt) sdk.Uint248 { return c.isFromUser(api, l) })
+	minBlockNum = sdk.Min(sdk.Map(swaps, func(cur sdk.Receipt) sdk.Uint248 { return api.ToUint248(cur.BlockNum) }))
+	sumVolume = sdk.Sum(sdk.Map(swaps, func(cur sdk.Receipt) sdk.Uint248 { return volumeOf(api, cur) }))
+	return
+}
+
+// appConstraintsCircuit compiles only the app circuit, without the host circuit
+// around it, so that the constraints of the app logic can be compared
+type appConstraintsCircuit struct {
+	Receipts sdk.DataPoints[sdk.Receipt]
+	UserAddr sdk.Uint248
+	Pipeline bool `gnark:"-"`
+}
+
+func newAppConstraintsCircuit(pipeline bool) *appConstraintsCircuit {
+	maxReceipts, _, _ := (&AppCircuit{}).Allocate()
+	return &appConstraintsCircuit{
+		Receipts: sdk.NewDataPoints(maxReceipts, sdk.DefaultReceipt),
+		UserAddr: sdk.ConstUint248(0),
+		Pipeline: pipeline,
+	}
+}
+
+func (c *appConstraintsCircuit) Define(g frontend.API) error {
+	api := sdk.NewCircuitAPI(g)
+	in := sdk.DataInput{Receipts: c.Receipts}
+	app := &AppCircuit{UserAddr: c.UserAddr}
+	if c.Pipeline {
+		return app.Define(api, in)
+	}
+	return app.define(api, in, app.eagerSwapTotals)
+}
@@ -100,9 +100,9 @@ func (api *Uint521API) FromBinary(vs ...Uint248) Uint521 {
 	}
 	vars := make([]frontend.Variable, len(vs))
 	for i, v := range vs {
-		vars[i] = v
+		vars[i] = v.Val
 	}
-	return newU521(api.f.FromBits(vars))
+	return newU521(api.f.FromBits(vars...))
 }
 
 // ToBinary decomposes the input v to a list (size n) of little-endian binary digits
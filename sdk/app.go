@@ -33,6 +33,7 @@ import (
 
 const (
 	defaultConcurrentFetchLimit = 20
+	defaultStatusPollInterval   = 12 * time.Second
 )
 
 type ReceiptData struct {
@@ -108,6 +109,18 @@ func (q *rawData[T]) add(data T, index ...int) {
 	}
 }
 
+// minAllocation returns the smallest max count that fits the data, taking the
+// pinned indices into account
+func (q *rawData[T]) minAllocation() int {
+	n := len(q.ordered) + len(q.special)
+	for index := range q.special {
+		if index+1 > n {
+			n = index + 1
+		}
+	}
+	return n
+}
+
 func (q *rawData[T]) list(max int) []T {
 	var l []T
 	ordered := q.ordered
@@ -166,6 +179,10 @@ func NewBrevisHashInfo(gatewayUrlOverride string) (*BrevisHashInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("NewGatewayClient err: %w", err)
 	}
+	return fetchBrevisHashInfo(gc)
+}
+
+func fetchBrevisHashInfo(gc *GatewayClient) (*BrevisHashInfo, error) {
 	resp, err := gc.c.GetCircuitDigest(context.Background(), &gwproto.CircuitDigestRequest{})
 	if err != nil {
 		return nil, fmt.Errorf("GetCircuitDigest err: %w", err)
@@ -206,6 +223,9 @@ type BrevisApp struct {
 	mockTxs      rawData[TransactionData]
 
 	concurrentFetchLimit int
+	// interval of polling the gateway for the query status, defaults to
+	// defaultStatusPollInterval
+	statusPollInterval time.Duration
 
 	// Persists data to reduce the number of RPC queries
 	dataStore gokv.Store
@@ -353,10 +373,41 @@ func NewBrevisAppFromExisting(existing *BrevisApp) (*BrevisApp, error) {
 		txs:                  rawData[TransactionData]{},
 		dataStore:            existing.dataStore,
 		concurrentFetchLimit: existing.concurrentFetchLimit,
+		statusPollInterval:   existing.statusPollInterval,
 		BrevisHashInfo:       existing.BrevisHashInfo,
 	}, nil
 }
 
+// newBrevisAppWithGatewayClient returns a BrevisApp that talks to the gateway
+// through gc and persists data in memory. It does not connect to any RPC, so
+// the data added to it must be complete, i.e. have the block number, base fee,
+// timestamp and MPT key path set.
+func newBrevisAppWithGatewayClient(srcChainId uint64, gc *GatewayClient) (*BrevisApp, error) {
+	br, err := eth.BrevisRequestMetaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("GetAbi err: %w", err)
+	}
+	hashInfo, err := fetchBrevisHashInfo(gc)
+	if err != nil {
+		return nil, err
+	}
+	dataStore, err := store.InitStore("syncmap", "")
+	if err != nil {
+		return nil, fmt.Errorf("InitStore err: %w", err)
+	}
+	return &BrevisApp{
+		gc:                   gc,
+		brevisRequest:        br,
+		srcChainId:           srcChainId,
+		receipts:             rawData[ReceiptData]{},
+		storageVals:          rawData[StorageData]{},
+		txs:                  rawData[TransactionData]{},
+		concurrentFetchLimit: defaultConcurrentFetchLimit,
+		dataStore:            dataStore,
+		BrevisHashInfo:       hashInfo,
+	}, nil
+}
+
 // set digests directly
 func NewBrevisAppWithDigestsSetOnly(
 	p2AggRecursionLeafCircuitDigestHash, P2AggRecursionMiddleFormMiddleLeafCircuitDigestHash, P2AggRecursionNoLeafCircuitDigestHash *pgoldilocks.HashOut256,
@@ -381,6 +432,16 @@ func NewBrevisAppWithDigestsSetOnly(
 	}
 }
 
+// NewMockBrevisApp returns a BrevisApp that can only be used with mock data to
+// build circuit inputs for testing. It does not connect to any RPC or to the
+// Brevis gateway. See BuildMockCircuitInput.
+func NewMockBrevisApp() *BrevisApp {
+	return &BrevisApp{
+		concurrentFetchLimit: defaultConcurrentFetchLimit,
+		BrevisHashInfo:       &BrevisHashInfo{},
+	}
+}
+
 // NewBrevisAppWithDigestsSetOnlyFromRemote creates a BrevisApp with digests retrieved from gateway
 // Used during setup
 // TODO: Deprecated and remove
@@ -484,7 +545,11 @@ func (q *BrevisApp) BuildCircuitInput(app AppCircuit) (CircuitInput, error) {
 
 // BuildCircuitInputStage1 assigns the number of data points, sets toggles and adds mock data if specified.
 // This does not involve on-chain queries.
+// If the app circuit has allocation tiers (see AppCircuitWithTiers), the input is
+// built for the smallest tier that fits the added data.
 func (q *BrevisApp) BuildCircuitInputStage1(app AppCircuit) (CircuitInput, error) {
+	app = smallestTier(app, q.receipts.minAllocation()+q.mockReceipts.minAllocation(),
+		q.storageVals.minAllocation()+q.mockStorage.minAllocation(), q.txs.minAllocation()+q.mockTxs.minAllocation())
 	q.maxReceipts, q.maxStorage, q.maxTxs = app.Allocate()
 	err := q.checkAllocations(app)
 	if err != nil {
@@ -578,13 +643,14 @@ func (q *BrevisApp) BuildCircuitInputStage2(app AppCircuit, in CircuitInput) (Ci
 	q.assignToggleCommitment(&in)
 
 	// dry run without assigning the output commitment first to compute the output commitment using the user circuit
-	outputCommit, output, err := dryRun(in, app)
+	outputCommit, output, outputTrees, err := dryRun(in, app)
 	if err != nil {
 		return buildCircuitInputErr("failed to generate output commitment", err)
 	}
 	in.OutputCommitment = outputCommit
 	// cache dry-run output to be used in building gateway request later
 	in.dryRunOutput = output
+	in.dryRunOutputTrees = outputTrees
 
 	q.circuitInput = in // cache the generated circuit input for later use in building gateway request
 	q.buildInputCalled = true
@@ -593,6 +659,56 @@ func (q *BrevisApp) BuildCircuitInputStage2(app AppCircuit, in CircuitInput) (Ci
 	return in, nil
 }
 
+// BuildMockCircuitInput builds the circuit input from mock data only, without
+// on-chain queries or gateway calls. The input commitments of the unused data
+// slots are computed locally from empty data instead of being fetched from
+// the gateway, so the result can be used with test.IsSolved but not to
+// request a proof.
+func (q *BrevisApp) BuildMockCircuitInput(app AppCircuit) (CircuitInput, error) {
+	if q.realDataLength() > 0 {
+		return CircuitInput{}, fmt.Errorf("BuildMockCircuitInput only supports mock data")
+	}
+	in, err := q.BuildCircuitInputStage1(app)
+	if err != nil {
+		return CircuitInput{}, fmt.Errorf("BuildCircuitInputStage1 err: %w", err)
+	}
+	dummy, err := mockDummyInputCommitments()
+	if err != nil {
+		return buildCircuitInputErr("failed to compute dummy input commitments", err)
+	}
+	q.assignInputCommitment(&in, dummy)
+	q.assignToggleCommitment(&in)
+
+	outputCommit, output, outputTrees, err := dryRun(in, app)
+	if err != nil {
+		return buildCircuitInputErr("failed to generate output commitment", err)
+	}
+	in.OutputCommitment = outputCommit
+	in.dryRunOutput = output
+	in.dryRunOutputTrees = outputTrees
+	return in, nil
+}
+
+// mockDummyInputCommitments returns the commitments of empty data in the
+// format of the gateway response
+func mockDummyInputCommitments() (*gwproto.CircuitDummyInputResponse, error) {
+	hasher := utils.NewPoseidonBn254()
+	packs := [][]*big.Int{defaultReceipt().goPack(), defaultStorageSlot().goPack(), defaultTransaction().goPack()}
+	commitments := make([]string, len(packs))
+	for i, pack := range packs {
+		h, err := doHash(hasher, pack)
+		if err != nil {
+			return nil, err
+		}
+		commitments[i] = hexutil.Encode(h.Bytes())
+	}
+	return &gwproto.CircuitDummyInputResponse{
+		Receipt: commitments[0],
+		Storage: commitments[1],
+		Tx:      commitments[2],
+	}, nil
+}
+
 func (q *BrevisApp) PrepareRequest(
 	vk plonk.VerifyingKey,
 	witness witness.Witness,
@@ -767,7 +883,12 @@ func (q *BrevisApp) WaitFinalProofSubmitted(ctx context.Context) (tx common.Hash
 }
 
 func (q *BrevisApp) waitFinalProofSubmitted(cancel <-chan struct{}) (common.Hash, error) {
-	t := time.NewTicker(12 * time.Second)
+	interval := q.statusPollInterval
+	if interval == 0 {
+		interval = defaultStatusPollInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
 	for {
 		select {
 		case <-t.C:
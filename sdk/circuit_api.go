@@ -23,13 +23,23 @@ type CircuitAPI struct {
 	Uint32  *Uint32API
 	Uint64  *Uint64API
 
-	g                    frontend.API
-	output               []variable `gnark:"-"`
+	g           frontend.API
+	output      []variable `gnark:"-"`
+	outputTypes []string   `gnark:"-"`
+	// one toggle per output byte. bytes with toggle 0 are excluded from the
+	// packed output
+	outputToggles []variable `gnark:"-"`
+	// the toggle of the OutputList record currently being added, nil if not in
+	// an OutputList
+	outputToggle         variable `gnark:"-"`
+	hasOutputList        bool     `gnark:"-"`
 	checkInputUniqueness int
+	// only set in a debug run, see DebugSolve
+	tracer *debugTracer
 }
 
 func NewCircuitAPI(gapi frontend.API) *CircuitAPI {
-	return &CircuitAPI{
+	api := &CircuitAPI{
 		g:       gapi,
 		Uint248: newUint248API(gapi),
 		Uint521: newUint521API(gapi),
@@ -38,6 +48,10 @@ func NewCircuitAPI(gapi frontend.API) *CircuitAPI {
 		Uint32:  newUint32API(gapi),
 		Uint64:  newUint64API(gapi),
 	}
+	if d, ok := gapi.(*debugAPI); ok {
+		api.tracer = d.tracer
+	}
+	return api
 }
 
 // OutputXXX APIs are for processing circuit outputs. The output data is
@@ -48,14 +62,14 @@ func NewCircuitAPI(gapi frontend.API) *CircuitAPI {
 // OutputBytes32 adds an output of solidity bytes32/uint256 type
 func (api *CircuitAPI) OutputBytes32(v Bytes32) {
 	b := v.toBinaryVars(api.g)
-	api.addOutput(b)
+	api.addOutput(b, "bytes32")
 	_, ok := v.Val[0].(*big.Int)
 	dbgPrint(ok, "added bytes32 output: %s\n", v)
 }
 
 // OutputBool adds an output of solidity bool type
 func (api *CircuitAPI) OutputBool(v Uint248) {
-	api.addOutput(api.g.ToBinary(v.Val, 8))
+	api.addOutput(api.g.ToBinary(v.Val, 8), "bool")
 	_, ok := v.Val.(*big.Int)
 	dbgPrint(ok, "added bool output: %d\n", v.Val)
 }
@@ -69,7 +83,7 @@ func (api *CircuitAPI) OutputUint(bitSize int, v Uint248) {
 		panic("bitSize must be multiple of 8 and should not exceed 248")
 	}
 	b := api.g.ToBinary(v.Val, bitSize)
-	api.addOutput(b)
+	api.addOutput(b, fmt.Sprintf("uint%d", bitSize))
 	_, ok := v.Val.(*big.Int)
 	dbgPrint(ok, "added uint%d output: %d\n", bitSize, v.Val)
 }
@@ -83,7 +97,7 @@ func (api *CircuitAPI) OutputUint32(bitSize int, v Uint32) {
 		panic("bitSize must be multiple of 8 and should not exceed 32")
 	}
 	b := api.g.ToBinary(v.Val, bitSize)
-	api.addOutput(b)
+	api.addOutput(b, fmt.Sprintf("uint%d", bitSize))
 	_, ok := v.Val.(*big.Int)
 	dbgPrint(ok, "added uint%d output: %d\n", bitSize, v.Val)
 }
@@ -97,28 +111,38 @@ func (api *CircuitAPI) OutputUint64(bitSize int, v Uint64) {
 		panic("bitSize must be multiple of 8  and should not exceed 64")
 	}
 	b := api.g.ToBinary(v.Val, bitSize)
-	api.addOutput(b)
+	api.addOutput(b, fmt.Sprintf("uint%d", bitSize))
 	_, ok := v.Val.(*big.Int)
 	dbgPrint(ok, "added uint%d output: %d\n", bitSize, v.Val)
 }
 
 // OutputAddress adds an output of solidity address type.
 func (api *CircuitAPI) OutputAddress(v Uint248) {
-	api.addOutput(api.g.ToBinary(v.Val, 20*8))
+	api.addOutput(api.g.ToBinary(v.Val, 20*8), "address")
 	_, ok := v.Val.(*big.Int)
 	dbgPrint(ok, "added address output: %x\n", v.Val)
 }
 
-func (api *CircuitAPI) addOutput(bits []variable) {
+func (api *CircuitAPI) addOutput(bits []variable, typ string) {
 	if len(bits)%8 != 0 {
 		panic("bits size must be multiple of 8")
 	}
+	api.outputTypes = append(api.outputTypes, typ)
+	var toggle variable = 1
+	if api.outputToggle != nil {
+		toggle = api.outputToggle
+	}
 	// the decomposed v bits are little-endian bits. The way evm uses Keccak expects
 	// the input to be big-endian bytes, but the bits in each byte are little endian
 	b := flipByGroups(bits, 8)
 	api.output = append(api.output, b...)
+	for i := 0; i < len(b)/8; i++ {
+		api.outputToggles = append(api.outputToggles, toggle)
+	}
 	if len(b) > 0 && !frontend.IsCanonical(b[0]) /*only set dryRunOutput when dryRun*/ {
-		dryRunOutput = append(dryRunOutput, bits2Bytes(b)...)
+		if fromInterface(toggle).Sign() != 0 {
+			dryRunOutput = append(dryRunOutput, bits2Bytes(b)...)
+		}
 	}
 }
 
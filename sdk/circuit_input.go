@@ -1,7 +1,9 @@
 package sdk
 
 import (
+	"fmt"
 	"math/big"
+	"path/filepath"
 
 	bn254_fr "github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	"github.com/ethereum/go-ethereum/common"
@@ -53,7 +55,8 @@ type CircuitInput struct {
 	DummyStorageInputCommitment     frontend.Variable `gnark:",public"`
 	DummyTransactionInputCommitment frontend.Variable `gnark:",public"`
 
-	dryRunOutput []byte `gnark:"-"`
+	dryRunOutput      []byte              `gnark:"-"`
+	dryRunOutputTrees []*OutputMerkleTree `gnark:"-"`
 }
 
 func defaultCircuitInput(maxReceipts, maxStorage, maxTxs, dataPoints int) CircuitInput {
@@ -99,6 +102,26 @@ func (in CircuitInput) GetAbiPackedOutput() []byte {
 	return ret
 }
 
+// GetOutputMerkleTrees returns the trees built by OutputMerkleRoot in the
+// order they are added to the output
+func (in CircuitInput) GetOutputMerkleTrees() []*OutputMerkleTree {
+	ret := make([]*OutputMerkleTree, len(in.dryRunOutputTrees))
+	copy(ret, in.dryRunOutputTrees)
+	return ret
+}
+
+// WriteOutputMerkleTrees saves the trees built by OutputMerkleRoot. The i-th
+// tree is saved under dir/tree<i>. See OutputMerkleTree.Save
+func (in CircuitInput) WriteOutputMerkleTrees(dir string) error {
+	for i, t := range in.dryRunOutputTrees {
+		err := t.Save(filepath.Join(dir, fmt.Sprintf("tree%d", i)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // OutputCommitment represents the value of a keccak256 hash H in the form of {H[:16], H[16:]}
 type OutputCommitment [2]frontend.Variable
 
@@ -0,0 +1,269 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/consensys/gnark/constraint"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+)
+
+// compileCacheVersion changes whenever the fingerprint changes for the same
+// circuit, so that old cache entries are not used
+const compileCacheVersion = 2
+
+// CircuitFingerprint identifies the constraint system an app circuit compiles
+// to without compiling it. It is derived from:
+//   - the versions of the SDK and gnark,
+//   - the allocation of the app circuit,
+//   - the structure of the app circuit, i.e. its type and the values of its
+//     fields, which may configure Define,
+//   - the running executable, since the code of Define cannot be inspected.
+//     Go builds are reproducible, so the executable only changes when some
+//     code, including Define, has changed.
+func CircuitFingerprint(app AppCircuit) (string, error) {
+	parts, err := fingerprintParts(app)
+	if err != nil {
+		return "", err
+	}
+	return fingerprintOf(parts), nil
+}
+
+func fingerprintOf(parts [][2]string) string {
+	h := sha256.New()
+	h.Write(encodeParts(parts))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func encodeParts(parts [][2]string) []byte {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		fmt.Fprintf(&buf, "%s %s\n", part[0], part[1])
+	}
+	return buf.Bytes()
+}
+
+// fingerprintParts returns the name and the value of each input of the
+// fingerprint of the app circuit
+func fingerprintParts(app AppCircuit) ([][2]string, error) {
+	exe, err := executableDigest()
+	if err != nil {
+		return nil, fmt.Errorf("cannot fingerprint the executable: %w", err)
+	}
+	structure := sha256.New()
+	writeStructure(structure, reflect.ValueOf(app), map[uintptr]bool{})
+	return [][2]string{
+		{"compile-cache", fmt.Sprint(compileCacheVersion)},
+		{"brevis-sdk", moduleVersion(sdkModulePath)},
+		{"gnark", moduleVersion(gnarkModulePath)},
+		{"allocation", AllocationOf(app).String()},
+		{"executable", exe},
+		{"structure", hex.EncodeToString(structure.Sum(nil))},
+	}, nil
+}
+
+var executableDigest = sync.OnceValues(func() (string, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, bufio.NewReaderSize(f, 1<<20)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+})
+
+// writeStructure writes the type and the value of v, following pointers but
+// not their addresses, which change on every run
+func writeStructure(h hash.Hash, v reflect.Value, visited map[uintptr]bool) {
+	if !v.IsValid() {
+		fmt.Fprint(h, "nil;")
+		return
+	}
+	fmt.Fprintf(h, "%s(", v.Type())
+	defer fmt.Fprint(h, ");")
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			fmt.Fprint(h, "nil")
+			return
+		}
+		if v.Kind() == reflect.Pointer {
+			if visited[v.Pointer()] {
+				fmt.Fprint(h, "cycle")
+				return
+			}
+			visited[v.Pointer()] = true
+		}
+		writeStructure(h, v.Elem(), visited)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			fmt.Fprintf(h, "%s %q:", t.Field(i).Name, t.Field(i).Tag)
+			writeStructure(h, v.Field(i), visited)
+		}
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(h, "%d:", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			writeStructure(h, v.Index(i), visited)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		for _, k := range keys {
+			writeStructure(h, k, visited)
+			writeStructure(h, v.MapIndex(k), visited)
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		// only the type is known
+	default:
+		fmt.Fprint(h, v)
+	}
+}
+
+// CompileCached is like CompileOnly, but the compiled circuit is cached in
+// cacheDir under the fingerprint of the app circuit (see CircuitFingerprint),
+// so that the app circuit is only compiled again once it has changed. The
+// circuit is compiled without the cache if it cannot be fingerprinted.
+//
+// Since the fingerprint includes the running executable, any rebuild of the
+// program misses the cache, even if Define has not changed. On a miss, the
+// inputs of the fingerprint that changed since the circuit of the same type was
+// last cached are logged.
+func CompileCached(app AppCircuit, cacheDir string) (constraint.ConstraintSystem, error) {
+	parts, err := fingerprintParts(app)
+	if err != nil {
+		fmt.Printf("compiling without cache: %s\n", err.Error())
+		return CompileOnly(app)
+	}
+	dir := os.ExpandEnv(cacheDir)
+	path := filepath.Join(dir, "ccs_"+fingerprintOf(parts))
+	before := time.Now()
+	ccs, err := readCachedCircuit(path)
+	if err == nil {
+		fmt.Printf("compiled circuit read from cache %s in %s, number constraints %d\n", path, time.Since(before), ccs.GetNbConstraints())
+		return ccs, nil
+	}
+	if os.IsNotExist(err) {
+		fmt.Printf("compiled circuit not in cache, compiling it: %s\n", cacheMissReason(dir, app, parts))
+	} else {
+		fmt.Printf("cannot read compiled circuit from cache, compiling again: %s\n", err.Error())
+	}
+
+	ccs, err = CompileOnly(app)
+	if err != nil {
+		return nil, err
+	}
+	// the cache only saves time, the compiled circuit is usable anyway
+	if err = writeCachedCircuit(path, ccs); err != nil {
+		fmt.Printf("cannot cache compiled circuit: %s\n", err.Error())
+		return ccs, nil
+	}
+	if err = writeLastParts(dir, app, parts); err != nil {
+		fmt.Printf("cannot record the fingerprint of the cached circuit: %s\n", err.Error())
+	}
+	return ccs, nil
+}
+
+// lastPartsFile returns the file that records the fingerprint inputs of the
+// circuit of the type of app that was last cached
+func lastPartsFile(dir string, app AppCircuit) string {
+	sum := sha256.Sum256([]byte(reflect.TypeOf(app).String()))
+	return filepath.Join(dir, "last_"+hex.EncodeToString(sum[:8]))
+}
+
+func writeLastParts(dir string, app AppCircuit, parts [][2]string) error {
+	return os.WriteFile(lastPartsFile(dir, app), encodeParts(parts), 0644)
+}
+
+// cacheMissReason tells which inputs of the fingerprint changed since the
+// circuit of the type of app was last cached
+func cacheMissReason(dir string, app AppCircuit, parts [][2]string) string {
+	content, err := os.ReadFile(lastPartsFile(dir, app))
+	if err != nil {
+		return fmt.Sprintf("no circuit of type %T has been cached before", app)
+	}
+	last := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if name, value, ok := strings.Cut(line, " "); ok {
+			last[name] = value
+		}
+	}
+	var changed []string
+	for _, part := range parts {
+		if last[part[0]] != part[1] {
+			changed = append(changed, part[0])
+		}
+	}
+	switch {
+	case len(changed) == 0:
+		return "the cache entry has been removed"
+	case len(changed) == 1 && changed[0] == "executable":
+		return "the executable has been rebuilt since the circuit was last cached, the code of Define may have changed"
+	}
+	return fmt.Sprintf("%s changed since the circuit was last cached", strings.Join(changed, ", "))
+}
+
+func readCachedCircuit(path string) (constraint.ConstraintSystem, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ccs := new(cs_bn254.SparseR1CS)
+	if _, err = ccs.ReadFrom(bufio.NewReaderSize(f, 1<<20)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ccs, nil
+}
+
+// writeCachedCircuit writes the circuit through a temp file, so that a cache
+// entry is either complete or absent
+func writeCachedCircuit(path string, ccs constraint.ConstraintSystem) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	w := bufio.NewWriterSize(f, 1<<20)
+	if _, err = ccs.WriteTo(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Printf("compiled circuit cached in %s\n", path)
+	return nil
+}
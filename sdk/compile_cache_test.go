@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileCache(t *testing.T) {
+	app := Tiers(&TestTiersCircuit{})[0]
+	dir := t.TempDir()
+	parts, err := fingerprintParts(app)
+	check(err)
+	expectMissReason(t, cacheMissReason(dir, app, parts), "has been cached before")
+	ccs, err := CompileCached(app, dir)
+	check(err)
+	expectMissReason(t, cacheMissReason(dir, app, parts), "entry has been removed")
+	rebuilt := append([][2]string{}, parts...)
+	rebuilt[4][1] = "other"
+	expectMissReason(t, cacheMissReason(dir, app, rebuilt), "executable has been rebuilt")
+	rebuilt[3][1] = "other"
+	expectMissReason(t, cacheMissReason(dir, app, rebuilt), "allocation, executable changed")
+	fingerprint, err := CircuitFingerprint(app)
+	check(err)
+	path := filepath.Join(dir, "ccs_"+fingerprint)
+	if _, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	cached, err := CompileCached(app, dir)
+	check(err)
+	if cached.GetNbConstraints() != ccs.GetNbConstraints() {
+		t.Fatalf("cached circuit has %d constraints, expected %d", cached.GetNbConstraints(), ccs.GetNbConstraints())
+	}
+
+	// a broken cache entry is replaced
+	check(os.WriteFile(path, []byte{1, 2, 3}, 0644))
+	_, err = CompileCached(app, dir)
+	check(err)
+	if _, err = readCachedCircuit(path); err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprints := map[string]bool{}
+	for _, c := range []AppCircuit{
+		app,
+		Tiers(&TestTiersCircuit{})[1],
+		&fingerprintCircuit{Threshold: 1},
+		&fingerprintCircuit{Threshold: 2},
+		&fingerprintCircuit{Threshold: 2, Tokens: map[string]int{"a": 1}},
+	} {
+		f, err := CircuitFingerprint(c)
+		check(err)
+		again, err := CircuitFingerprint(c)
+		check(err)
+		if f != again {
+			t.Fatalf("fingerprint of %T is not stable", c)
+		}
+		fingerprints[f] = true
+	}
+	if len(fingerprints) != 5 {
+		t.Fatalf("expected 5 different fingerprints, got %d", len(fingerprints))
+	}
+}
+
+func expectMissReason(t *testing.T, reason, expected string) {
+	t.Helper()
+	if !strings.Contains(reason, expected) {
+		t.Fatalf("cache miss reason %q does not contain %q", reason, expected)
+	}
+}
+
+type fingerprintCircuit struct {
+	TestTiersCircuit
+	Threshold int
+	Tokens    map[string]int
+}
@@ -13,16 +13,30 @@ type DataStream[T CircuitVariable] struct {
 	api        *CircuitAPI
 	underlying []T
 	toggles    []frontend.Variable
+	// source names the kind of input data (e.g. receipt) the elements originate
+	// from, if the element positions still match the input data. Used to report
+	// the data index in debug runs
+	source string
 }
 
 func NewDataStream[T CircuitVariable](api *CircuitAPI, in DataPoints[T]) *DataStream[T] {
 	if len(in.Raw) != len(in.Toggles) {
 		panic("inconsistent data lengths")
 	}
+	var source string
+	switch any(*new(T)).(type) {
+	case Receipt:
+		source = "receipt"
+	case StorageSlot:
+		source = "storage slot"
+	case Transaction:
+		source = "transaction"
+	}
 	return &DataStream[T]{
 		api:        api,
 		underlying: in.Raw,
 		toggles:    in.Toggles,
+		source:     source,
 	}
 }
 
@@ -51,7 +65,9 @@ func (ds *DataStream[T]) Show() {
 func GetUnderlying[T CircuitVariable](ds *DataStream[T], index int) T {
 	v := ds.underlying[index]
 	t := ds.toggles[index]
+	done := ds.api.traceElement("GetUnderlying", ds.source, index)
 	ds.api.g.AssertIsEqual(t, 1)
+	done()
 	return v
 }
 
@@ -91,16 +107,144 @@ func WindowUnderlying[T CircuitVariable](ds *DataStream[T], size int, step ...in
 	return newDataStream(ds.api, ret, toggles)
 }
 
+// TumblingWindow splits a DataStream into numWindows consecutive, non-overlapping
+// windows of windowSize, keyed on a monotonically increasing field such as
+// BlockTimestamp or BlockNum. Windows are aligned to multiples of windowSize and
+// the first window is the one containing the key of the first valid element.
+// Each returned DataStream shares the underlying data of ds and only toggles on
+// the elements that fall into its window.
+// Asserts that the keys of valid elements are sorted in ascending order and that
+// every valid element falls into one of the windows.
+// Example: keys [10, 20, 35, 41], windowSize 10, numWindows 4 -> windows
+// [10, 20) [20, 30) [30, 40) [40, 50) containing [10], [20], [35], [41]
+func TumblingWindow[T CircuitVariable](ds *DataStream[T], key GetValueFunc[T], windowSize, numWindows int) []*DataStream[T] {
+	return SlidingWindow(ds, key, windowSize, windowSize, numWindows)
+}
+
+// SlidingWindow splits a DataStream into numWindows possibly overlapping
+// windows of windowSize, each starting step after the previous one. Windows are
+// keyed on a monotonically increasing field such as BlockTimestamp or BlockNum.
+// windowSize must be a multiple of step. Window starts are aligned to multiples
+// of step and the first window is the one starting at the step containing the
+// key of the first valid element. Each returned DataStream shares the underlying
+// data of ds and only toggles on the elements that fall into its window.
+// Asserts that the keys of valid elements are sorted in ascending order and that
+// every valid element falls into at least one of the windows.
+// Example: keys [0, 1, 2, 3], windowSize 2, step 1, numWindows 3 -> windows
+// containing [0, 1], [1, 2], [2, 3]
+func SlidingWindow[T CircuitVariable](ds *DataStream[T], key GetValueFunc[T], windowSize, step, numWindows int) []*DataStream[T] {
+	if windowSize <= 0 || step <= 0 || numWindows <= 0 {
+		panic(fmt.Errorf("invalid window params: windowSize %d, step %d, numWindows %d", windowSize, step, numWindows))
+	}
+	if windowSize%step != 0 {
+		panic(fmt.Errorf("window size %d must be a multiple of step %d", windowSize, step))
+	}
+	g := ds.api.g
+	stepsPerWindow := windowSize / step
+	// each valid element must be in one of the steps covered by the windows
+	numSteps := numWindows + stepsPerWindow - 1
+	inStep := stepIndicators(ds, key, step, numSteps)
+
+	windows := make([]*DataStream[T], numWindows)
+	for j := 0; j < numWindows; j++ {
+		toggles := make([]frontend.Variable, len(ds.underlying))
+		for i := range ds.underlying {
+			var in frontend.Variable = 0
+			for s := j; s < j+stepsPerWindow; s++ {
+				in = g.Add(in, inStep[i][s])
+			}
+			toggles[i] = g.Mul(ds.toggles[i], in)
+		}
+		windows[j] = newDataStream(ds.api, ds.underlying, toggles)
+	}
+	return windows
+}
+
+// BucketBy assigns the elements of a DataStream to numBuckets consecutive
+// buckets of bucketSize keyed on a monotonically increasing field, such as
+// per 24h buckets of BlockTimestamp, then calls reducer on each bucket. Buckets
+// are aligned to multiples of bucketSize and the first bucket is the one
+// containing the key of the first valid element. In the returned data stream,
+// the element at index i is the aggregation result of the i-th bucket and is
+// only toggled on if the bucket contains at least one valid element. Uses
+// TumblingWindow under the hood and asserts the same ordering.
+func BucketBy[T, R CircuitVariable](
+	ds *DataStream[T],
+	key GetValueFunc[T],
+	bucketSize, numBuckets int,
+	reducer ReduceFunc[T, R],
+	reducerInit R,
+) *DataStream[R] {
+	g := ds.api.g
+	buckets := TumblingWindow(ds, key, bucketSize, numBuckets)
+	aggResults := make([]R, numBuckets)
+	aggResultToggles := make([]frontend.Variable, numBuckets)
+	for i, bucket := range buckets {
+		aggResults[i] = Reduce(bucket, reducerInit, reducer)
+		aggResultToggles[i] = g.Sub(1, g.IsZero(Count(bucket).Val))
+	}
+	return newDataStream(ds.api, aggResults, aggResultToggles)
+}
+
+// stepIndicators computes for each element i and each step s in [0, numSteps)
+// whether the key of the element falls into the s-th step-sized slot counting
+// from the slot of the first valid element. Entries of toggled off elements are
+// meaningless and must be masked out by the caller using the toggles. Asserts
+// that the keys of valid elements are sorted in ascending order and that each
+// valid element falls into exactly one of the steps.
+func stepIndicators[T CircuitVariable](ds *DataStream[T], key GetValueFunc[T], step, numSteps int) [][]frontend.Variable {
+	api := ds.api
+	g := api.g
+	u248 := api.Uint248
+
+	AssertSorted(ds, func(a, b T) Uint248 {
+		return u248.Not(u248.IsGreaterThan(key(a), key(b)))
+	})
+
+	slots := make([]frontend.Variable, len(ds.underlying))
+	for i, data := range ds.underlying {
+		q, _ := u248.Div(key(data), ConstUint248(step))
+		slots[i] = q.Val
+	}
+
+	// the slot of the first valid element is the smallest because the keys are sorted
+	var first frontend.Variable = 0
+	var found frontend.Variable = 0
+	for i, slot := range slots {
+		take := g.Mul(ds.toggles[i], g.Sub(1, found))
+		first = g.Select(take, slot, first)
+		found = g.Select(take, 1, found)
+	}
+
+	indicators := make([][]frontend.Variable, len(ds.underlying))
+	for i, slot := range slots {
+		// zeroing out the slots of toggled off elements as their keys could be anything
+		rel := g.Select(ds.toggles[i], g.Sub(slot, first), 0)
+		indicators[i] = make([]frontend.Variable, numSteps)
+		var sum frontend.Variable = 0
+		for s := 0; s < numSteps; s++ {
+			indicators[i][s] = api.isEqual(rel, s)
+			sum = g.Add(sum, indicators[i][s])
+		}
+		// every valid element must fall into one of the steps. since the steps are
+		// mutually exclusive, the sum is either 0 or 1
+		g.AssertIsEqual(g.Select(ds.toggles[i], sum, 1), 1)
+	}
+	return indicators
+}
+
 // AssertFunc returns 1 if the assertion passes, and 0 otherwise
 type AssertFunc[T CircuitVariable] func(current T) Uint248
 
 // AssertEach asserts on each valid element in the data stream
 func AssertEach[T CircuitVariable](ds *DataStream[T], assertFunc AssertFunc[T]) {
 	for i, data := range ds.underlying {
+		done := ds.api.traceElement("AssertEach", ds.source, i)
 		pass := assertFunc(data).Val
 		valid := ds.api.isEqual(ds.toggles[i], 1)
 		pass = ds.api.g.Select(valid, pass, 1)
 		ds.api.g.AssertIsEqual(pass, 1)
+		done()
 	}
 }
 
@@ -127,7 +271,9 @@ func IsSorted[T CircuitVariable](ds *DataStream[T], sortFunc SortFunc[T]) Uint24
 		curr := ds.underlying[i]
 		currValid := ds.toggles[i]
 
+		done := ds.api.traceElement("IsSorted", ds.source, i)
 		newSorted := sortFunc(prev, curr).Val
+		done()
 		// if we found anything unsorted, then the rest of the sorted check is effectively useless
 		useNewSorted := api.And(sorted, api.And(prevValid, currValid))
 		sorted = api.Select(useNewSorted, newSorted, sorted)
@@ -140,7 +286,27 @@ func IsSorted[T CircuitVariable](ds *DataStream[T], sortFunc SortFunc[T]) Uint24
 
 // AssertSorted Performs the sortFunc on each valid pair of data points and asserts the result to be 1.
 func AssertSorted[T CircuitVariable](ds *DataStream[T], sortFunc SortFunc[T]) {
-	ds.api.Uint248.AssertIsEqual(IsSorted(ds, sortFunc), newU248(1))
+	if ds.api.tracer == nil {
+		ds.api.Uint248.AssertIsEqual(IsSorted(ds, sortFunc), newU248(1))
+		return
+	}
+	// in a debug run, each pair is asserted on its own so that the report has
+	// the index of the first element out of order. Same pairing as IsSorted
+	api := ds.api.g
+	prev := ds.underlying[0]
+	prevValid := ds.toggles[0]
+	for i := 1; i < len(ds.underlying); i++ {
+		curr := ds.underlying[i]
+		currValid := ds.toggles[i]
+
+		done := ds.api.traceElement("AssertSorted", ds.source, i)
+		sorted := sortFunc(prev, curr).Val
+		api.AssertIsEqual(api.Select(api.And(prevValid, currValid), sorted, 1), 1)
+		done()
+
+		prev = Select(ds.api, newU248(currValid), curr, prev)
+		prevValid = api.Select(currValid, currValid, prevValid)
+	}
 }
 
 // Count returns the number of valid elements (i.e. toggled on) in the data stream.
@@ -167,11 +333,15 @@ func ZipMap2[T0, T1, R CircuitVariable](
 	res := make([]R, len(a.underlying))
 	for i := range a.underlying {
 		va, vb := a.underlying[i], b[i]
+		done := a.api.traceElement("ZipMap2", a.source, i)
 		res[i] = zipFunc(va, vb)
+		done()
 	}
 	toggles := make([]frontend.Variable, len(a.toggles))
 	copy(toggles, a.toggles)
-	return newDataStream(a.api, res, toggles)
+	ret := newDataStream(a.api, res, toggles)
+	ret.source = a.source
+	return ret
 }
 
 type ZipMap3Func[T0, T1, T2, R CircuitVariable] func(a T0, b T1, c T2) R
@@ -190,11 +360,15 @@ func ZipMap3[T0, T1, T2, R CircuitVariable](
 	res := make([]R, len(a.underlying))
 	for i := range a.underlying {
 		va, vb, vc := a.underlying[i], b[i], c[i]
+		done := a.api.traceElement("ZipMap3", a.source, i)
 		res[i] = zipFunc(va, vb, vc)
+		done()
 	}
 	toggles := make([]frontend.Variable, len(a.toggles))
 	copy(toggles, a.toggles)
-	return newDataStream(a.api, res, toggles)
+	ret := newDataStream(a.api, res, toggles)
+	ret.source = a.source
+	return ret
 }
 
 type GetValueFunc[T any] func(current T) Uint248
@@ -291,9 +465,13 @@ type MapFunc[T, R CircuitVariable] func(current T) R
 func Map[T, R CircuitVariable](ds *DataStream[T], mapFunc MapFunc[T, R]) *DataStream[R] {
 	res := make([]R, len(ds.underlying))
 	for i, data := range ds.underlying {
+		done := ds.api.traceElement("Map", ds.source, i)
 		res[i] = mapFunc(data)
+		done()
 	}
-	return newDataStream(ds.api, res, ds.toggles)
+	ret := newDataStream(ds.api, res, ds.toggles)
+	ret.source = ds.source
+	return ret
 }
 
 type ReduceFunc[T, R CircuitVariable] func(accumulator R, current T) (newAccumulator R)
@@ -303,20 +481,51 @@ type ReduceFunc[T, R CircuitVariable] func(accumulator R, current T) (newAccumul
 func Reduce[T, R CircuitVariable](ds *DataStream[T], initial R, reducer ReduceFunc[T, R]) R {
 	acc := initial
 	for i, data := range ds.underlying {
-		newAcc := reducer(acc, data)
-		oldAccVals := acc.Values()
-		if len(newAcc.Values()) != len(oldAccVals) {
-			panic("not the same number of elements between original and reduced variables")
-		}
-		values := make([]frontend.Variable, len(oldAccVals))
-		for j, newAccV := range newAcc.Values() {
-			values[j] = ds.api.g.Select(ds.toggles[i], newAccV, oldAccVals[j])
-		}
-		acc = acc.FromValues(values...).(R)
+		done := ds.api.traceElement("Reduce", ds.source, i)
+		acc = reduceStep(ds.api, ds.toggles[i], acc, data, reducer)
+		done()
 	}
 	return acc
 }
 
+// Scan is like Reduce, but instead of only returning the final accumulator, it
+// returns a data stream of every intermediate accumulator, e.g. a running
+// balance or a cumulative volume. The element at index i of the result is the
+// accumulator after processing the element at index i of the source data
+// stream. Toggled off elements pass the accumulator through unchanged, and the
+// result data stream keeps the toggles from the source data stream.
+// Example: Scan([1,2,3,4] with toggles [1,1,0,1], 0, mySumFunc) -> [1,3,3,7]
+func Scan[T, R CircuitVariable](ds *DataStream[T], initial R, reducer ReduceFunc[T, R]) *DataStream[R] {
+	res := make([]R, len(ds.underlying))
+	acc := initial
+	for i, data := range ds.underlying {
+		done := ds.api.traceElement("Scan", ds.source, i)
+		acc = reduceStep(ds.api, ds.toggles[i], acc, data, reducer)
+		res[i] = acc
+		done()
+	}
+	toggles := make([]frontend.Variable, len(ds.toggles))
+	copy(toggles, ds.toggles)
+	ret := newDataStream(ds.api, res, toggles)
+	ret.source = ds.source
+	return ret
+}
+
+// reduceStep calls the reducer on the current element and only takes the new
+// accumulator if the element is toggled on
+func reduceStep[T, R CircuitVariable](api *CircuitAPI, toggle frontend.Variable, acc R, data T, reducer ReduceFunc[T, R]) R {
+	newAcc := reducer(acc, data)
+	oldAccVals := acc.Values()
+	if len(newAcc.Values()) != len(oldAccVals) {
+		panic("not the same number of elements between original and reduced variables")
+	}
+	values := make([]frontend.Variable, len(oldAccVals))
+	for j, newAccV := range newAcc.Values() {
+		values[j] = api.g.Select(toggle, newAccV, oldAccVals[j])
+	}
+	return acc.FromValues(values...).(R)
+}
+
 // FilterFunc must return 1/0 to include/exclude `current` in the filter result
 type FilterFunc[T CircuitVariable] func(current T) Uint248
 
@@ -325,11 +534,15 @@ func Filter[T CircuitVariable](ds *DataStream[T], predicate FilterFunc[T]) *Data
 	api := ds.api.g
 	newToggles := make([]frontend.Variable, len(ds.underlying))
 	for i, data := range ds.underlying {
+		done := ds.api.traceElement("Filter", ds.source, i)
 		toggle := predicate(data).Val
 		valid := ds.api.isEqual(ds.toggles[i], 1)
 		newToggles[i] = api.Select(api.And(toggle, valid), 1, 0)
+		done()
 	}
-	return newDataStream(ds.api, ds.underlying, newToggles)
+	ret := newDataStream(ds.api, ds.underlying, newToggles)
+	ret.source = ds.source
+	return ret
 }
 
 // MinGeneric finds out the minimum value from the data stream with the user
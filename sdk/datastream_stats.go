@@ -0,0 +1,156 @@
+package sdk
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/multicommit"
+)
+
+// Median finds out the median of the valid values in the data stream. If the
+// number of valid values is even, the median is the floored mean of the two
+// middle values. Sorts the values using SortHint and proves the sorting in
+// circuit. Note if the data stream is empty (all data points are toggled off),
+// this function returns 0.
+func Median(ds *DataStream[Uint248]) Uint248 {
+	u248 := ds.api.Uint248
+	sorted := sortValid(ds)
+	count := Count(ds)
+	// for odd count 2h+1, both lo and hi are h. for even count 2h, lo is h-1 and hi is h
+	half, rem := u248.Div(count, newU248(2))
+	lo := kthSmallest(ds.api, sorted, count, u248.Sub(u248.Add(half, rem), newU248(1)))
+	hi := kthSmallest(ds.api, sorted, count, half)
+	median, _ := u248.Div(u248.Add(lo, hi), newU248(2))
+	return median
+}
+
+// Percentile finds out the p-th percentile of the valid values in the data
+// stream using the nearest-rank method, i.e. the smallest value such that at
+// least p percent of the values are less than or equal to it. p must be in range
+// [0, 100]. Percentile(ds, 0) returns the minimum and Percentile(ds, 100)
+// returns the maximum. Sorts the values using SortHint and proves the sorting in
+// circuit. Note if the data stream is empty (all data points are toggled off),
+// this function returns 0.
+func Percentile(ds *DataStream[Uint248], p int) Uint248 {
+	if p < 0 || p > 100 {
+		panic(fmt.Errorf("percentile must be in range [0, 100], got %d", p))
+	}
+	u248 := ds.api.Uint248
+	sorted := sortValid(ds)
+	count := Count(ds)
+	// rank = ceil(p * count / 100), the 1-based index of the percentile value
+	rank, _ := u248.Div(u248.Add(u248.Mul(count, newU248(p)), newU248(99)), newU248(100))
+	// rank is 0 only if p is 0, in which case we take the first value
+	index := u248.Sub(u248.Add(rank, u248.IsZero(rank)), newU248(1))
+	return kthSmallest(ds.api, sorted, count, index)
+}
+
+// Variance calculates the population variance of the valid values in the data
+// stream, scaled by 10^decimals and floored. e.g. a variance of 2.5 is returned
+// as 25 if decimals is 1. Computed exactly as (n*Σx² - (Σx)²) * 10^decimals / n²,
+// so the caller must make sure n*Σx²*10^decimals does not overflow Uint248. Note
+// if the data stream is empty (all data points are toggled off), this function
+// returns 0.
+func Variance(ds *DataStream[Uint248], decimals int) Uint248 {
+	u248 := ds.api.Uint248
+	count := Count(ds)
+	sum := Sum(ds)
+	sumSq := Reduce(ds, newU248(0), func(acc Uint248, curr Uint248) Uint248 {
+		return u248.Add(acc, u248.Mul(curr, curr))
+	})
+	num := u248.Sub(u248.Mul(count, sumSq), u248.Mul(sum, sum))
+	num = u248.Mul(num, newU248(pow10(decimals)))
+	// avoid division by zero when the data stream is empty, num is 0 anyway
+	den := u248.Mul(count, count)
+	den = u248.Add(den, u248.IsZero(den))
+	quo, _ := u248.Div(num, den)
+	return quo
+}
+
+// StdDev calculates the population standard deviation of the valid values in
+// the data stream, scaled by 10^decimals and floored. Uses Variance and Sqrt, so
+// the caller must make sure n*Σx²*10^(2*decimals) does not overflow Uint248.
+func StdDev(ds *DataStream[Uint248], decimals int) Uint248 {
+	return ds.api.Uint248.Sqrt(Variance(ds, 2*decimals))
+}
+
+// WeightedMean calculates Σ(value*weight) / Σweight over the valid elements of
+// the data stream, scaled by 10^decimals and floored. For example, the volume
+// weighted average price of a stream of trades is WeightedMean(trades, price,
+// volume, 0). The caller must make sure Σ(value*weight)*10^decimals does not
+// overflow Uint248. Note if the data stream is empty or all weights are zero,
+// this function returns 0.
+func WeightedMean[T CircuitVariable](ds *DataStream[T], value, weight GetValueFunc[T], decimals int) Uint248 {
+	u248 := ds.api.Uint248
+	init := Tuple2[Uint248, Uint248]{F0: newU248(0), F1: newU248(0)}
+	sums := Reduce(ds, init, func(acc Tuple2[Uint248, Uint248], curr T) Tuple2[Uint248, Uint248] {
+		w := weight(curr)
+		return Tuple2[Uint248, Uint248]{
+			F0: u248.Add(acc.F0, u248.Mul(value(curr), w)),
+			F1: u248.Add(acc.F1, w),
+		}
+	})
+	num := u248.Mul(sums.F0, newU248(pow10(decimals)))
+	den := u248.Add(sums.F1, u248.IsZero(sums.F1))
+	quo, _ := u248.Div(num, den)
+	return quo
+}
+
+// sortValid returns the valid values of the data stream offset by one (so that
+// they are distinguishable from the toggled off elements which become 0), sorted
+// in descending order. The sorting is computed by SortHint and proven in circuit
+// by asserting that the hint output is in descending order and is a permutation
+// of the input (through a grand product check on a random challenge).
+func sortValid(ds *DataStream[Uint248]) []frontend.Variable {
+	g := ds.api.g
+	in := make([]frontend.Variable, len(ds.underlying))
+	for i, v := range ds.underlying {
+		in[i] = g.Mul(ds.toggles[i], g.Add(v.Val, 1))
+	}
+	sorted, err := g.Compiler().NewHint(SortHint, len(in), in...)
+	if err != nil {
+		panic(fmt.Errorf("failed to initialize SortHint instance: %s", err.Error()))
+	}
+	for i := 0; i < len(sorted)-1; i++ {
+		g.AssertIsLessOrEqual(sorted[i+1], sorted[i])
+	}
+	committed := append(append([]frontend.Variable{}, in...), sorted...)
+	multicommit.WithCommitment(g, func(api frontend.API, gamma frontend.Variable) error {
+		// Π_{a \in in} a+ɣ = Π_{b \in sorted} b+ɣ
+		var lhs, rhs frontend.Variable = 1, 1
+		for i := range in {
+			lhs = api.Mul(lhs, api.Add(in[i], gamma))
+			rhs = api.Mul(rhs, api.Add(sorted[i], gamma))
+		}
+		api.AssertIsEqual(lhs, rhs)
+		return nil
+	}, committed...)
+	return sorted
+}
+
+// kthSmallest picks the k-th (0-based) smallest value from the output of
+// sortValid. Returns 0 if count is 0 or if k is out of range [0, count).
+func kthSmallest(api *CircuitAPI, sorted []frontend.Variable, count, k Uint248) Uint248 {
+	g := api.g
+	// sorted is descending, so the k-th smallest is at index count-1-k
+	index := g.Sub(g.Sub(count.Val, 1), k.Val)
+	var picked, found frontend.Variable = 0, 0
+	for i, v := range sorted {
+		hit := api.isEqual(index, i)
+		picked = g.Add(picked, g.Mul(hit, v))
+		found = g.Add(found, hit)
+	}
+	// the zero entries at the end of sorted are not valid values, they can only be
+	// picked if the data stream is empty
+	found = g.Mul(found, g.Sub(1, g.IsZero(count.Val)))
+	// undo the offset added in sortValid
+	return newU248(g.Select(found, g.Sub(picked, 1), 0))
+}
+
+func pow10(decimals int) *big.Int {
+	if decimals < 0 {
+		panic(fmt.Errorf("decimals must not be negative, got %d", decimals))
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+}
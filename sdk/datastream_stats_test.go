@@ -0,0 +1,139 @@
+package sdk
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+var testPercentiles = []int{0, 1, 10, 25, 50, 75, 90, 99, 100}
+
+const testStatsDecimals = 4
+
+func TestStats(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for round := 0; round < 10; round++ {
+		n := 16
+		values := make([]*big.Int, n)
+		weights := make([]*big.Int, n)
+		toggles := make([]frontend.Variable, n)
+		var validValues, validWeights []*big.Int
+		for i := 0; i < n; i++ {
+			values[i] = big.NewInt(r.Int63n(1_000_000))
+			weights[i] = big.NewInt(r.Int63n(1000))
+			toggles[i] = 0
+			// the first round has no valid elements
+			if round > 0 && r.Intn(4) > 0 {
+				toggles[i] = 1
+				validValues = append(validValues, values[i])
+				validWeights = append(validWeights, weights[i])
+			}
+		}
+		c := &TestStatsCircuit{
+			In:           DataPoints[Tuple2[Uint248, Uint248]]{Raw: make([]Tuple2[Uint248, Uint248], n), Toggles: toggles},
+			Median:       refMedian(validValues),
+			Variance:     refVariance(validValues, testStatsDecimals),
+			StdDev:       new(big.Int).Sqrt(refVariance(validValues, 2*testStatsDecimals)),
+			WeightedMean: refWeightedMean(validValues, validWeights, testStatsDecimals),
+		}
+		for i := range values {
+			c.In.Raw[i] = Tuple2[Uint248, Uint248]{F0: newU248(values[i]), F1: newU248(weights[i])}
+		}
+		for _, p := range testPercentiles {
+			c.Percentiles = append(c.Percentiles, refPercentile(validValues, p))
+		}
+		err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatalf("round %d: %s", round, err.Error())
+		}
+	}
+}
+
+type TestStatsCircuit struct {
+	In           DataPoints[Tuple2[Uint248, Uint248]]
+	Median       frontend.Variable
+	Percentiles  []frontend.Variable
+	Variance     frontend.Variable
+	StdDev       frontend.Variable
+	WeightedMean frontend.Variable
+}
+
+func (c *TestStatsCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	in := NewDataStream(api, c.In)
+	values := Map(in, func(cur Tuple2[Uint248, Uint248]) Uint248 { return cur.F0 })
+
+	g.AssertIsEqual(Median(values).Val, c.Median)
+	for i, p := range testPercentiles {
+		g.AssertIsEqual(Percentile(values, p).Val, c.Percentiles[i])
+	}
+	g.AssertIsEqual(Variance(values, testStatsDecimals).Val, c.Variance)
+	g.AssertIsEqual(StdDev(values, testStatsDecimals).Val, c.StdDev)
+	vwap := WeightedMean(in,
+		func(cur Tuple2[Uint248, Uint248]) Uint248 { return cur.F0 },
+		func(cur Tuple2[Uint248, Uint248]) Uint248 { return cur.F1 },
+		testStatsDecimals)
+	g.AssertIsEqual(vwap.Val, c.WeightedMean)
+	return nil
+}
+
+func sortedCopy(vs []*big.Int) []*big.Int {
+	ret := make([]*big.Int, len(vs))
+	copy(ret, vs)
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Cmp(ret[j]) < 0 })
+	return ret
+}
+
+func refMedian(vs []*big.Int) *big.Int {
+	if len(vs) == 0 {
+		return big.NewInt(0)
+	}
+	s := sortedCopy(vs)
+	lo, hi := s[(len(s)-1)/2], s[len(s)/2]
+	return new(big.Int).Rsh(new(big.Int).Add(lo, hi), 1)
+}
+
+func refPercentile(vs []*big.Int, p int) *big.Int {
+	if len(vs) == 0 {
+		return big.NewInt(0)
+	}
+	s := sortedCopy(vs)
+	rank := (p*len(s) + 99) / 100
+	if rank == 0 {
+		rank = 1
+	}
+	return s[rank-1]
+}
+
+func refVariance(vs []*big.Int, decimals int) *big.Int {
+	if len(vs) == 0 {
+		return big.NewInt(0)
+	}
+	n := big.NewInt(int64(len(vs)))
+	sum, sumSq := new(big.Int), new(big.Int)
+	for _, v := range vs {
+		sum.Add(sum, v)
+		sumSq.Add(sumSq, new(big.Int).Mul(v, v))
+	}
+	num := new(big.Int).Sub(new(big.Int).Mul(n, sumSq), new(big.Int).Mul(sum, sum))
+	num.Mul(num, pow10(decimals))
+	return num.Quo(num, new(big.Int).Mul(n, n))
+}
+
+func refWeightedMean(vs, ws []*big.Int, decimals int) *big.Int {
+	num, den := new(big.Int), new(big.Int)
+	for i := range vs {
+		num.Add(num, new(big.Int).Mul(vs[i], ws[i]))
+		den.Add(den, ws[i])
+	}
+	if den.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	num.Mul(num, pow10(decimals))
+	return num.Quo(num, den)
+}
@@ -286,3 +286,116 @@ func (c *TestComplexCircuit) Define(g frontend.API) error {
 	api.Uint248.AssertIsEqual(rowMin.F1, ConstUint248(200))
 	return nil
 }
+
+func TestWindowByKey(t *testing.T) {
+	c := &TestWindowByKeyCircuit{}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+}
+
+type TestWindowByKeyCircuit struct{}
+
+func (c *TestWindowByKeyCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	u248 := api.Uint248
+
+	// (timestamp, volume) pairs. the last two are toggled off and have garbage keys
+	in := NewDataStream(api, DataPoints[Tuple2[Uint248, Uint248]]{
+		Raw: []Tuple2[Uint248, Uint248]{
+			{newU248(105), newU248(1)},
+			{newU248(110), newU248(2)},
+			{newU248(125), newU248(3)},
+			{newU248(131), newU248(4)},
+			{newU248(139), newU248(5)},
+			{newU248(158), newU248(6)},
+			{newU248(1), newU248(100)},
+			{newU248(0), newU248(100)},
+		},
+		Toggles: []frontend.Variable{1, 1, 1, 1, 1, 1, 0, 0},
+	})
+	key := func(cur Tuple2[Uint248, Uint248]) Uint248 { return cur.F0 }
+	volume := func(cur Tuple2[Uint248, Uint248]) Uint248 { return cur.F1 }
+
+	// buckets [100, 110) [110, 120) [120, 130) [130, 140) [140, 150) [150, 160)
+	reducer := func(acc Uint248, cur Tuple2[Uint248, Uint248]) Uint248 { return u248.Add(acc, volume(cur)) }
+	buckets := BucketBy(in, key, 10, 6, reducer, newU248(0))
+	expectedSums := []int{1, 2, 3, 9, 0, 6}
+	expectedToggles := []int{1, 1, 1, 1, 0, 1}
+	for i := range expectedSums {
+		u248.AssertIsEqual(buckets.underlying[i], newU248(expectedSums[i]))
+		g.AssertIsEqual(buckets.toggles[i], expectedToggles[i])
+	}
+
+	tumbling := TumblingWindow(in, key, 20, 3) // [100, 120) [120, 140) [140, 160)
+	expectedCounts := []int{2, 3, 1}
+	for i, w := range tumbling {
+		u248.AssertIsEqual(Count(w), newU248(expectedCounts[i]))
+	}
+
+	sliding := SlidingWindow(in, key, 20, 10, 5) // [100, 120) [110, 130) ... [140, 160)
+	expectedCounts = []int{2, 2, 3, 2, 1}
+	for i, w := range sliding {
+		u248.AssertIsEqual(Count(w), newU248(expectedCounts[i]))
+		u248.AssertIsEqual(Sum(Map(w, volume)), newU248([]int{3, 5, 12, 9, 6}[i]))
+	}
+	return nil
+}
+
+func TestWindowByKeyUnsorted(t *testing.T) {
+	c := &TestWindowByKeyUnsortedCircuit{}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil {
+		t.Error("expected unsorted keys to fail")
+	}
+}
+
+type TestWindowByKeyUnsortedCircuit struct{}
+
+func (c *TestWindowByKeyUnsortedCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	in := NewDataStream(api, DataPoints[Uint248]{
+		Raw:     newU248s(10, 30, 20, 40),
+		Toggles: []frontend.Variable{1, 1, 1, 1},
+	})
+	TumblingWindow(in, func(cur Uint248) Uint248 { return cur }, 10, 4)
+	return nil
+}
+
+func TestScan(t *testing.T) {
+	c := &TestScanCircuit{}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+}
+
+type TestScanCircuit struct{}
+
+func (c *TestScanCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	u248 := api.Uint248
+
+	in := NewDataStream(api, DataPoints[Uint248]{
+		Raw:     newU248s(5, 2, 100, 8, 1, 6),
+		Toggles: []frontend.Variable{1, 1, 0, 1, 1, 0},
+	})
+
+	// running sum: disabled elements pass the accumulator through unchanged
+	sums := Scan(in, newU248(0), func(acc Uint248, curr Uint248) Uint248 { return u248.Add(acc, curr) })
+	expected := []int{5, 7, 7, 15, 16, 16}
+	for i, v := range sums.underlying {
+		u248.AssertIsEqual(v, newU248(expected[i]))
+		g.AssertIsEqual(sums.toggles[i], in.toggles[i])
+	}
+
+	// high-water mark
+	highs := Scan(in, newU248(0), func(acc Uint248, curr Uint248) Uint248 {
+		return u248.Select(u248.IsGreaterThan(curr, acc), curr, acc)
+	})
+	expected = []int{5, 5, 5, 8, 8, 8}
+	for i, v := range highs.underlying {
+		u248.AssertIsEqual(v, newU248(expected[i]))
+	}
+
+	// the last accumulator of a scan is the result of reduce
+	u248.AssertIsEqual(sums.underlying[len(sums.underlying)-1], Sum(in))
+	return nil
+}
@@ -0,0 +1,194 @@
+package sdk
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// DebugReport describes the first assertion that failed in a debug run
+type DebugReport struct {
+	// Op is the failing assertion, e.g. AssertIsEqual
+	Op string
+	// Operands are the values the assertion was called with
+	Operands []*big.Int
+	// Contexts are the DataStream operations and the data index being processed
+	// when the assertion failed, from the outermost to the innermost, e.g.
+	// "AssertEach on receipt #3"
+	Contexts []string
+	// CallSites are the SDK and user functions that lead to the assertion, from
+	// the innermost to the outermost, e.g.
+	// "sdk.AssertEach (datastream.go:230)"
+	CallSites []string
+}
+
+func (r *DebugReport) String() string {
+	operands := make([]string, len(r.Operands))
+	for i, o := range r.Operands {
+		operands[i] = o.String()
+	}
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "assertion failed: %s(%s)\n", r.Op, strings.Join(operands, ", "))
+	if len(r.Contexts) > 0 {
+		fmt.Fprintf(b, "  while: %s\n", strings.Join(r.Contexts, " > "))
+	}
+	for i, s := range r.CallSites {
+		prefix := "     "
+		if i == 0 {
+			prefix = "  at "
+		}
+		fmt.Fprintf(b, "%s%s\n", prefix, s)
+	}
+	return b.String()
+}
+
+// DebugSolve solves the app circuit like test.IsSolved, but with every
+// assertion traced back to the SDK call that made it and the data index that
+// was being processed. If an assertion fails, the returned report describes the
+// first failing assertion. The error is the error returned by the solver.
+//
+// Example:
+//
+//	report, err := sdk.DebugSolve(appCircuit, appCircuitAssignment, in)
+//	if report != nil {
+//		fmt.Println(report)
+//	}
+func DebugSolve(circuit, assign AppCircuit, in CircuitInput) (*DebugReport, error) {
+	// DebugSolve writes the dry run output, so it cannot run concurrently with dryRun
+	dryRunLock.Lock()
+	defer dryRunLock.Unlock()
+
+	tracer := &debugTracer{}
+	host := DefaultHostCircuit(TierOf(circuit, in))
+	host.tracer = tracer
+	assignment := NewHostCircuit(in.Clone(), assign)
+	err := test.IsSolved(host, assignment, ecc.BN254.ScalarField())
+	return tracer.report, err
+}
+
+// debugTracer keeps the DataStream operations currently being built in a debug
+// run and records the first failing assertion
+type debugTracer struct {
+	contexts []string
+	report   *DebugReport
+}
+
+// traceElement marks the element at index of a data stream as being processed
+// by op. The returned function must be called once done with the element.
+// No-op if not in a debug run.
+func (api *CircuitAPI) traceElement(op, source string, index int) func() {
+	if source == "" {
+		source = "element"
+	}
+	return api.tracer.trace("%s on %s #%d", op, source, index)
+}
+
+// trace adds a context to the reports until the returned function is called.
+// No-op on a nil tracer.
+func (t *debugTracer) trace(format string, args ...interface{}) func() {
+	if t == nil {
+		return func() {}
+	}
+	t.contexts = append(t.contexts, fmt.Sprintf(format, args...))
+	n := len(t.contexts)
+	return func() { t.contexts = t.contexts[:n-1] }
+}
+
+// debugAPI wraps a frontend.API to check every assertion on the concrete values
+// of a test engine solve before it is passed on to the wrapped API
+type debugAPI struct {
+	frontend.API
+	tracer *debugTracer
+}
+
+func (d *debugAPI) AssertIsEqual(a, b frontend.Variable) {
+	d.check("AssertIsEqual", func(v []*big.Int) bool { return v[0].Cmp(v[1]) == 0 }, a, b)
+	d.API.AssertIsEqual(a, b)
+}
+
+func (d *debugAPI) AssertIsDifferent(a, b frontend.Variable) {
+	d.check("AssertIsDifferent", func(v []*big.Int) bool { return v[0].Cmp(v[1]) != 0 }, a, b)
+	d.API.AssertIsDifferent(a, b)
+}
+
+func (d *debugAPI) AssertIsBoolean(a frontend.Variable) {
+	d.check("AssertIsBoolean", func(v []*big.Int) bool { return v[0].IsUint64() && v[0].Uint64() <= 1 }, a)
+	d.API.AssertIsBoolean(a)
+}
+
+func (d *debugAPI) AssertIsLessOrEqual(a, bound frontend.Variable) {
+	d.check("AssertIsLessOrEqual", func(v []*big.Int) bool { return v[0].Cmp(v[1]) <= 0 }, a, bound)
+	d.API.AssertIsLessOrEqual(a, bound)
+}
+
+// SetKeyValue and GetKeyValue forward to the wrapped API, which is required by
+// multicommit
+func (d *debugAPI) SetKeyValue(key, value any) {
+	d.API.(interface{ SetKeyValue(key, value any) }).SetKeyValue(key, value)
+}
+
+func (d *debugAPI) GetKeyValue(key any) any {
+	return d.API.(interface{ GetKeyValue(key any) any }).GetKeyValue(key)
+}
+
+func (d *debugAPI) check(op string, holds func(v []*big.Int) bool, vs ...frontend.Variable) {
+	if d.tracer.report != nil {
+		return
+	}
+	values := make([]*big.Int, len(vs))
+	for i, v := range vs {
+		// values are only known when solving with the test engine
+		if !isConcrete(v) {
+			return
+		}
+		values[i] = new(big.Int).Mod(fromInterface(v), d.Compiler().Field())
+	}
+	if holds(values) {
+		return
+	}
+	d.tracer.report = &DebugReport{
+		Op:        op,
+		Operands:  values,
+		Contexts:  append([]string{}, d.tracer.contexts...),
+		CallSites: debugCallSites(),
+	}
+}
+
+func isConcrete(v frontend.Variable) bool {
+	switch v.(type) {
+	case *big.Int, big.Int, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool:
+		return true
+	}
+	return false
+}
+
+// debugCallSites returns the call stack from the caller of the debugAPI
+// assertion up to HostCircuit.Define or the emulator, skipping the frames of
+// gnark and the Go runtime
+func debugCallSites() []string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var sites []string
+	for {
+		f, more := frames.Next()
+		if strings.Contains(f.Function, "(*HostCircuit).Define") || f.Function == sdkPackagePrefix+"(*tierCircuit).Define" ||
+			f.Function == sdkPackagePrefix+"emulate" {
+			break
+		}
+		if !strings.HasPrefix(f.Function, "github.com/consensys/") && !strings.HasPrefix(f.Function, "runtime.") {
+			name := f.Function[strings.LastIndex(f.Function, "/")+1:]
+			sites = append(sites, fmt.Sprintf("%s (%s:%d)", name, filepath.Base(f.File), f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return sites
+}
@@ -0,0 +1,79 @@
+package sdk
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+func TestDebugTrace(t *testing.T) {
+	tracer := &debugTracer{}
+	c := &TestDebugCircuit{tracer: tracer, Bound: 5}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil {
+		t.Fatal("expected circuit to fail")
+	}
+	r := tracer.report
+	if r == nil {
+		t.Fatal("expected a debug report")
+	}
+	t.Log(r)
+	if r.Op != "AssertIsLessOrEqual" || r.Operands[0].Int64() != 6 || r.Operands[1].Int64() != 5 {
+		t.Errorf("unexpected failing assertion %s %v", r.Op, r.Operands)
+	}
+	if strings.Join(r.Contexts, " > ") != "Map on receipt #3 > AssertEach on element #0" {
+		t.Errorf("unexpected contexts %v", r.Contexts)
+	}
+	if len(r.CallSites) < 2 || !strings.HasPrefix(r.CallSites[0], "sdk.(*Uint248API).AssertIsLessOrEqual (api_uint248.go:") {
+		t.Errorf("unexpected call sites %v", r.CallSites)
+	}
+	if s := r.String(); !strings.Contains(s, "assertion failed: AssertIsLessOrEqual(6, 5)") ||
+		!strings.Contains(s, "while: Map on receipt #3") {
+		t.Errorf("unexpected report string %s", s)
+	}
+
+	// the first failure is reported, and passing circuits have no report
+	tracer = &debugTracer{}
+	c = &TestDebugCircuit{tracer: tracer, Bound: 100}
+	err = test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+	if tracer.report != nil {
+		t.Errorf("expected no report, got %s", tracer.report)
+	}
+}
+
+type TestDebugCircuit struct {
+	Bound  frontend.Variable
+	tracer *debugTracer
+}
+
+func (c *TestDebugCircuit) Define(g frontend.API) error {
+	if c.tracer != nil {
+		g = &debugAPI{API: g, tracer: c.tracer}
+	}
+	api := NewCircuitAPI(g)
+	in := NewDataPoints(4, defaultReceipt)
+	for i := range in.Raw {
+		in.Raw[i].BlockNum = ConstUint32(5 + i)
+		in.Toggles[i] = 1
+	}
+	receipts := NewDataStream(api, in)
+	bound := newU248(c.Bound)
+	// the inner data stream is not derived from the input data, so its elements
+	// are reported without a source
+	Map(receipts, func(r Receipt) Uint248 {
+		blockNums := NewDataStream(api, DataPoints[Uint248]{
+			Raw:     []Uint248{api.ToUint248(r.BlockNum)},
+			Toggles: []frontend.Variable{1},
+		})
+		AssertEach(blockNums, func(v Uint248) Uint248 {
+			api.Uint248.AssertIsLessOrEqual(api.Uint248.Sub(v, newU248(2)), bound)
+			return newU248(1)
+		})
+		return newU248(0)
+	})
+	return nil
+}
@@ -0,0 +1,429 @@
+package sdk
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// EmulatorResult is the result of running an app circuit in the emulator
+type EmulatorResult struct {
+	// Output is the circuit output, in the same encoding as
+	// CircuitInput.GetAbiPackedOutput
+	Output []byte
+	// OutputMerkleTrees are the trees built by OutputMerkleRoot, in the order
+	// they are added to the output
+	OutputMerkleTrees []*OutputMerkleTree
+	// Failures are all the assertions that do not hold, in the order they are
+	// made. The circuit is satisfied if there are none.
+	Failures []*DebugReport
+}
+
+// Ok tells whether all assertions hold
+func (r *EmulatorResult) Ok() bool {
+	return len(r.Failures) == 0
+}
+
+// Emulate runs the Define function of the app circuit directly over the values
+// of the assignment and the circuit input, without building the host circuit.
+// Every type API and DataStream operation behaves as with the gnark solver, so
+// this can be used to iterate on the circuit logic in milliseconds. The
+// input commitments and the output commitment are not computed, which is why a
+// passing emulation must still be confirmed with test.IsSolved or
+// test.ProverSucceeded.
+//
+// Unlike the solver, the emulator does not stop at the first failing
+// assertion. All failing assertions are reported in the result. An error is
+// returned if Define returns an error or panics.
+func Emulate(assign AppCircuit, in CircuitInput) (*EmulatorResult, error) {
+	return emulate(assign, in.Clone().DataInput, nil)
+}
+
+// hintHook is called with the outputs of every hint call, which it may modify.
+// call is the index of the hint call in the order Define makes them, and
+// contexts are the data items being processed, see DebugReport.
+type hintHook func(call int, f solver.Hint, out []*big.Int, contexts []string)
+
+func emulate(guest AppCircuit, in DataInput, onHint hintHook) (ret *EmulatorResult, err error) {
+	// the outputs are collected in the dry run globals
+	dryRunLock.Lock()
+	defer dryRunLock.Unlock()
+	dryRunOutput = nil
+	dryRunOutputTrees = nil
+
+	e := &emulatorAPI{
+		q:      ecc.BN254.ScalarField(),
+		tracer: &debugTracer{},
+		kv:     map[any]any{},
+		onHint: onHint,
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("emulator: %v", r)
+		}
+	}()
+	api := NewCircuitAPI(e)
+	api.tracer = e.tracer
+	err = guest.Define(api, in)
+	if err != nil {
+		return nil, fmt.Errorf("error building user-defined circuit %s", err.Error())
+	}
+	// deferred functions may defer more functions
+	for i := 0; i < len(e.deferred); i++ {
+		err = e.deferred[i](e)
+		if err != nil {
+			return nil, fmt.Errorf("deferred function %d: %s", i, err.Error())
+		}
+	}
+
+	ret = &EmulatorResult{
+		Output:            make([]byte, len(dryRunOutput)),
+		OutputMerkleTrees: make([]*OutputMerkleTree, len(dryRunOutputTrees)),
+		Failures:          e.failures,
+	}
+	copy(ret.Output, dryRunOutput)
+	copy(ret.OutputMerkleTrees, dryRunOutputTrees)
+	return ret, nil
+}
+
+// emulatorAPI implements frontend.API over big.Int values. It follows the
+// semantics of the gnark test engine, except that failing assertions are
+// recorded instead of panicking, and that big.Int values are reduced modulo the
+// field like the values of a witness.
+type emulatorAPI struct {
+	q        *big.Int
+	tracer   *debugTracer
+	failures []*DebugReport
+	kv       map[any]any
+	deferred []func(frontend.API) error
+	// onHint and the number of hint calls made so far
+	onHint    hintHook
+	hintCalls int
+}
+
+// fail records a failing assertion
+func (e *emulatorAPI) fail(op string, operands ...*big.Int) {
+	sites := debugCallSites()
+	for len(sites) > 0 && strings.HasPrefix(sites[0], "sdk.(*emulatorAPI)") {
+		sites = sites[1:]
+	}
+	e.failures = append(e.failures, &DebugReport{
+		Op:        op,
+		Operands:  operands,
+		Contexts:  append([]string{}, e.tracer.contexts...),
+		CallSites: sites,
+	})
+}
+
+func (e *emulatorAPI) toBigInt(v frontend.Variable) *big.Int {
+	switch vv := v.(type) {
+	case *big.Int:
+		return new(big.Int).Mod(vv, e.q)
+	case big.Int:
+		return new(big.Int).Mod(&vv, e.q)
+	}
+	b := fromInterface(v)
+	return b.Mod(b, e.q)
+}
+
+func (e *emulatorAPI) mod(v *big.Int) *big.Int {
+	return v.Mod(v, e.q)
+}
+
+func (e *emulatorAPI) mustBeBoolean(op string, vs ...*big.Int) {
+	for _, v := range vs {
+		if !v.IsUint64() || v.Uint64() > 1 {
+			e.fail(op, v)
+		}
+	}
+}
+
+func (e *emulatorAPI) Add(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := new(big.Int).Add(e.toBigInt(i1), e.toBigInt(i2))
+	for _, v := range in {
+		res.Add(res, e.toBigInt(v))
+	}
+	return e.mod(res)
+}
+
+func (e *emulatorAPI) MulAcc(a, b, c frontend.Variable) frontend.Variable {
+	res := new(big.Int).Mul(e.toBigInt(b), e.toBigInt(c))
+	return e.mod(res.Add(res, e.toBigInt(a)))
+}
+
+func (e *emulatorAPI) Neg(i1 frontend.Variable) frontend.Variable {
+	return e.mod(new(big.Int).Neg(e.toBigInt(i1)))
+}
+
+func (e *emulatorAPI) Sub(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := new(big.Int).Sub(e.toBigInt(i1), e.toBigInt(i2))
+	for _, v := range in {
+		res.Sub(res, e.toBigInt(v))
+	}
+	return e.mod(res)
+}
+
+func (e *emulatorAPI) Mul(i1, i2 frontend.Variable, in ...frontend.Variable) frontend.Variable {
+	res := e.mod(new(big.Int).Mul(e.toBigInt(i1), e.toBigInt(i2)))
+	for _, v := range in {
+		e.mod(res.Mul(res, e.toBigInt(v)))
+	}
+	return res
+}
+
+func (e *emulatorAPI) DivUnchecked(i1, i2 frontend.Variable) frontend.Variable {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Sign() == 0 && b2.Sign() == 0 {
+		return big.NewInt(0)
+	}
+	return e.div("DivUnchecked", b1, b2)
+}
+
+func (e *emulatorAPI) Div(i1, i2 frontend.Variable) frontend.Variable {
+	return e.div("Div", e.toBigInt(i1), e.toBigInt(i2))
+}
+
+func (e *emulatorAPI) div(op string, b1, b2 *big.Int) *big.Int {
+	res := new(big.Int)
+	if res.ModInverse(b2, e.q) == nil {
+		e.fail(op, b1, b2)
+		return res
+	}
+	return e.mod(res.Mul(res, b1))
+}
+
+func (e *emulatorAPI) Inverse(i1 frontend.Variable) frontend.Variable {
+	return e.div("Inverse", big.NewInt(1), e.toBigInt(i1))
+}
+
+func (e *emulatorAPI) ToBinary(i1 frontend.Variable, n ...int) []frontend.Variable {
+	nbBits := e.FieldBitLen()
+	if len(n) == 1 {
+		nbBits = n[0]
+	}
+	b := e.toBigInt(i1)
+	if b.BitLen() > nbBits {
+		e.fail("ToBinary", b, big.NewInt(int64(nbBits)))
+	}
+	bits := make([]frontend.Variable, nbBits)
+	for i := range bits {
+		bits[i] = big.NewInt(int64(b.Bit(i)))
+	}
+	return bits
+}
+
+func (e *emulatorAPI) FromBinary(b ...frontend.Variable) frontend.Variable {
+	res := new(big.Int)
+	for i := len(b) - 1; i >= 0; i-- {
+		bit := e.toBigInt(b[i])
+		e.mustBeBoolean("FromBinary", bit)
+		res.Lsh(res, 1).Add(res, bit)
+	}
+	return e.mod(res)
+}
+
+func (e *emulatorAPI) Xor(a, b frontend.Variable) frontend.Variable {
+	b1, b2 := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("Xor", b1, b2)
+	return new(big.Int).Xor(b1, b2)
+}
+
+func (e *emulatorAPI) Or(a, b frontend.Variable) frontend.Variable {
+	b1, b2 := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("Or", b1, b2)
+	return new(big.Int).Or(b1, b2)
+}
+
+func (e *emulatorAPI) And(a, b frontend.Variable) frontend.Variable {
+	b1, b2 := e.toBigInt(a), e.toBigInt(b)
+	e.mustBeBoolean("And", b1, b2)
+	return new(big.Int).And(b1, b2)
+}
+
+func (e *emulatorAPI) Select(b frontend.Variable, i1, i2 frontend.Variable) frontend.Variable {
+	s := e.toBigInt(b)
+	e.mustBeBoolean("Select", s)
+	if s.Sign() != 0 {
+		return e.toBigInt(i1)
+	}
+	return e.toBigInt(i2)
+}
+
+func (e *emulatorAPI) Lookup2(b0, b1 frontend.Variable, i0, i1, i2, i3 frontend.Variable) frontend.Variable {
+	s0, s1 := e.toBigInt(b0), e.toBigInt(b1)
+	e.mustBeBoolean("Lookup2", s0, s1)
+	i := s0.Bit(0) | s1.Bit(0)<<1
+	return e.toBigInt([]frontend.Variable{i0, i1, i2, i3}[i])
+}
+
+func (e *emulatorAPI) IsZero(i1 frontend.Variable) frontend.Variable {
+	if e.toBigInt(i1).Sign() == 0 {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+func (e *emulatorAPI) Cmp(i1, i2 frontend.Variable) frontend.Variable {
+	return e.mod(big.NewInt(int64(e.toBigInt(i1).Cmp(e.toBigInt(i2)))))
+}
+
+func (e *emulatorAPI) AssertIsEqual(i1, i2 frontend.Variable) {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Cmp(b2) != 0 {
+		e.fail("AssertIsEqual", b1, b2)
+	}
+}
+
+func (e *emulatorAPI) AssertIsDifferent(i1, i2 frontend.Variable) {
+	b1, b2 := e.toBigInt(i1), e.toBigInt(i2)
+	if b1.Cmp(b2) == 0 {
+		e.fail("AssertIsDifferent", b1, b2)
+	}
+}
+
+func (e *emulatorAPI) AssertIsBoolean(i1 frontend.Variable) {
+	e.mustBeBoolean("AssertIsBoolean", e.toBigInt(i1))
+}
+
+func (e *emulatorAPI) AssertIsCrumb(i1 frontend.Variable) {
+	b := e.toBigInt(i1)
+	if !b.IsUint64() || b.Uint64() > 3 {
+		e.fail("AssertIsCrumb", b)
+	}
+}
+
+func (e *emulatorAPI) AssertIsLessOrEqual(v frontend.Variable, bound frontend.Variable) {
+	b1, b2 := e.toBigInt(v), e.toBigInt(bound)
+	if b1.Cmp(b2) > 0 {
+		e.fail("AssertIsLessOrEqual", b1, b2)
+	}
+}
+
+// Check implements frontend.Rangechecker, so that range checks are done on the
+// values instead of being deferred
+func (e *emulatorAPI) Check(v frontend.Variable, bits int) {
+	b := e.toBigInt(v)
+	if b.BitLen() > bits {
+		e.fail("RangeCheck", b, big.NewInt(int64(bits)))
+	}
+}
+
+func (e *emulatorAPI) Println(a ...frontend.Variable) {
+	values := make([]string, len(a))
+	for i, v := range a {
+		if s, ok := v.(string); ok {
+			values[i] = s
+		} else {
+			values[i] = e.toBigInt(v).String()
+		}
+	}
+	fmt.Println("(emulator)", strings.Join(values, " "))
+}
+
+func (e *emulatorAPI) Compiler() frontend.Compiler {
+	return e
+}
+
+func (e *emulatorAPI) NewHint(f solver.Hint, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
+	if nbOutputs <= 0 {
+		return nil, fmt.Errorf("hint function must return at least one output")
+	}
+	in := make([]*big.Int, len(inputs))
+	for i := range inputs {
+		in[i] = e.toBigInt(inputs[i])
+	}
+	res := make([]*big.Int, nbOutputs)
+	for i := range res {
+		res[i] = new(big.Int)
+	}
+	err := f(e.q, in, res)
+	if err != nil {
+		// the solver fails on hint errors
+		e.fail(fmt.Sprintf("%s: %s", solver.GetHintName(f), err.Error()), in...)
+	}
+	if e.onHint != nil {
+		e.onHint(e.hintCalls, f, res, e.tracer.contexts)
+	}
+	e.hintCalls++
+	out := make([]frontend.Variable, nbOutputs)
+	for i := range res {
+		out[i] = e.mod(res[i])
+	}
+	return out, nil
+}
+
+// ConstantValue returns false like the gnark test engine, so that gadgets build
+// the same operations as when solving
+func (e *emulatorAPI) ConstantValue(v frontend.Variable) (*big.Int, bool) {
+	return e.toBigInt(v), false
+}
+
+func (e *emulatorAPI) IsBoolean(v frontend.Variable) bool {
+	b := e.toBigInt(v)
+	return b.IsUint64() && b.Uint64() <= 1
+}
+
+func (e *emulatorAPI) MarkBoolean(v frontend.Variable) {
+	e.mustBeBoolean("MarkBoolean", e.toBigInt(v))
+}
+
+func (e *emulatorAPI) Field() *big.Int {
+	return e.q
+}
+
+func (e *emulatorAPI) FieldBitLen() int {
+	return e.q.BitLen()
+}
+
+func (e *emulatorAPI) Defer(cb func(frontend.API) error) {
+	e.deferred = append(e.deferred, cb)
+}
+
+func (e *emulatorAPI) AddBlueprint(constraint.Blueprint) constraint.BlueprintID {
+	panic("blueprints are not supported by the emulator")
+}
+
+func (e *emulatorAPI) AddInstruction(constraint.BlueprintID, []uint32) []uint32 {
+	panic("blueprints are not supported by the emulator")
+}
+
+func (e *emulatorAPI) InternalVariable(wireID uint32) frontend.Variable {
+	panic("InternalVariable is not supported by the emulator")
+}
+
+func (e *emulatorAPI) ToCanonicalVariable(frontend.Variable) frontend.CanonicalVariable {
+	panic("ToCanonicalVariable is not supported by the emulator")
+}
+
+func (e *emulatorAPI) SetGkrInfo(constraint.GkrInfo) error {
+	return fmt.Errorf("GKR is not supported by the emulator")
+}
+
+// Commit returns a hash of the values. Like the commitments of the solver, it
+// is used as a random challenge.
+func (e *emulatorAPI) Commit(v ...frontend.Variable) (frontend.Variable, error) {
+	values := make([][]byte, len(v))
+	for i := range v {
+		values[i] = e.toBigInt(v[i]).FillBytes(make([]byte, 32))
+	}
+	res := e.mod(new(big.Int).SetBytes(crypto.Keccak256(values...)))
+	if res.Sign() == 0 {
+		res.SetUint64(1)
+	}
+	return res, nil
+}
+
+// SetKeyValue and GetKeyValue are required by multicommit and emulated fields
+func (e *emulatorAPI) SetKeyValue(key, value any) {
+	e.kv[key] = value
+}
+
+func (e *emulatorAPI) GetKeyValue(key any) any {
+	return e.kv[key]
+}
@@ -0,0 +1,226 @@
+package sdk
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// TestEmulatorDifferential checks that the emulator agrees with the gnark
+// solver on whether the circuit is satisfied and on the output
+func TestEmulatorDifferential(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	max248 := new(big.Int).Lsh(big.NewInt(1), 248)
+	randValue := func() *big.Int {
+		switch rnd.Intn(4) {
+		case 0:
+			return big.NewInt(rnd.Int63n(4))
+		case 1:
+			return big.NewInt(rnd.Int63n(1 << 32))
+		case 2:
+			return new(big.Int).Rand(rnd, new(big.Int).Lsh(big.NewInt(1), 64))
+		}
+		return new(big.Int).Rand(rnd, max248)
+	}
+
+	var failed int
+	for i := 0; i < 30; i++ {
+		types := &TestEmulatorTypesCircuit{A: randValue(), B: randValue(), C: randValue()}
+		failed += checkEmulator(t, types, emulatorTestInput(nil))
+
+		values := make([]*big.Int, 4)
+		for j := range values {
+			values[j] = big.NewInt(rnd.Int63n(1000))
+		}
+		streams := &TestEmulatorStreamCircuit{Threshold: rnd.Int63n(1200)}
+		failed += checkEmulator(t, streams, emulatorTestInput(values[:1+rnd.Intn(4)]))
+	}
+	t.Logf("%d of 60 circuits not satisfied", failed)
+	// both satisfied and unsatisfied circuits are covered
+	if failed == 0 || failed == 60 {
+		t.Errorf("expected some of the circuits to fail, %d failed", failed)
+	}
+
+	// constants out of the range of the field are reduced like the values of a
+	// witness. The test engine does not reduce big.Int values, so the solver
+	// runs on the reduced values
+	q := ecc.BN254.ScalarField()
+	negative := big.NewInt(-5)
+	oversized := new(big.Int).Add(q, big.NewInt(7))
+	for _, c := range []*TestEmulatorTypesCircuit{
+		{A: negative, B: big.NewInt(3), C: big.NewInt(100)},
+		{A: big.NewInt(100), B: negative, C: negative},
+		{A: oversized, B: big.NewInt(3), C: big.NewInt(100)},
+		{A: big.NewInt(100), B: oversized, C: oversized},
+	} {
+		reduced := &TestEmulatorTypesCircuit{
+			A: new(big.Int).Mod(c.A.(*big.Int), q),
+			B: new(big.Int).Mod(c.B.(*big.Int), q),
+			C: new(big.Int).Mod(c.C.(*big.Int), q),
+		}
+		checkEmulator(t, reduced, emulatorTestInput(nil))
+
+		res, err := emulate(c, emulatorTestInput(nil), nil)
+		check(err)
+		expected, err := emulate(reduced, emulatorTestInput(nil), nil)
+		check(err)
+		if res.Ok() != expected.Ok() || !bytes.Equal(res.Output, expected.Output) {
+			t.Errorf("%+v: emulator failures %v and output %x, expected failures %v and output %x",
+				c, res.Failures, res.Output, expected.Failures, expected.Output)
+		}
+	}
+}
+
+// checkEmulator returns 1 if the circuit is not satisfied
+func checkEmulator(t *testing.T, guest AppCircuit, in DataInput) int {
+	res, err := emulate(guest, in, nil)
+	check(err)
+
+	dryRunLock.Lock()
+	dryRunOutput = nil
+	c := &TestEmulatorCircuit{Guest: guest, Input: in}
+	solverErr := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	output := dryRunOutput
+	dryRunLock.Unlock()
+
+	if res.Ok() != (solverErr == nil) {
+		t.Fatalf("%+v: emulator failures %v, solver error %v", guest, res.Failures, solverErr)
+	}
+	if !res.Ok() {
+		return 1
+	}
+	if !bytes.Equal(res.Output, output) {
+		t.Fatalf("%+v: emulator output %x, solver output %x", guest, res.Output, output)
+	}
+	return 0
+}
+
+func TestEmulatorFailures(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(50), big.NewInt(7), big.NewInt(70)}
+	res, err := emulate(&TestEmulatorStreamCircuit{Threshold: 10}, emulatorTestInput(values), nil)
+	check(err)
+	// all failing assertions are reported, not only the first one
+	if len(res.Failures) != 2 {
+		t.Fatalf("expected 2 failures, got %v", res.Failures)
+	}
+	for i, index := range []string{"#1", "#3"} {
+		r := res.Failures[i]
+		if r.Op != "AssertIsLessOrEqual" || !strings.HasSuffix(r.Contexts[0], index) {
+			t.Errorf("unexpected failure %s", r)
+		}
+		if len(r.CallSites) == 0 || !strings.HasPrefix(r.CallSites[0], "sdk.(*Uint248API).AssertIsLessOrEqual") {
+			t.Errorf("unexpected call sites %v", r.CallSites)
+		}
+	}
+
+	// panics in Define are returned as errors
+	_, err = emulate(&TestEmulatorPanicCircuit{}, emulatorTestInput(values), nil)
+	if err == nil {
+		t.Error("expected emulator error")
+	}
+}
+
+func emulatorTestInput(values []*big.Int) DataInput {
+	in := DataInput{
+		Receipts:     NewDataPoints(4, defaultReceipt),
+		StorageSlots: NewDataPoints(0, defaultStorageSlot),
+		Transactions: NewDataPoints(0, defaultTransaction),
+	}
+	for i := range in.Receipts.Raw {
+		in.Receipts.Toggles[i] = 0
+		if i < len(values) {
+			in.Receipts.Toggles[i] = 1
+			in.Receipts.Raw[i].BlockNum = ConstUint32(100 + i)
+			in.Receipts.Raw[i].Fields[0].Contract = ConstUint248(i % 2)
+			in.Receipts.Raw[i].Fields[0].Value = ConstFromBigEndianBytes(values[i].Bytes())
+		}
+	}
+	return in
+}
+
+type TestEmulatorCircuit struct {
+	Guest AppCircuit
+	Input DataInput
+}
+
+func (c *TestEmulatorCircuit) Define(g frontend.API) error {
+	return c.Guest.Define(NewCircuitAPI(g), c.Input)
+}
+
+type TestEmulatorTypesCircuit struct {
+	A, B, C frontend.Variable
+}
+
+func (c *TestEmulatorTypesCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 4, 0, 0
+}
+
+func (c *TestEmulatorTypesCircuit) Define(api *CircuitAPI, in DataInput) error {
+	a, b, cc := newU248(c.A), newU248(c.B), newU248(c.C)
+	q, r := api.Uint248.Div(a, b)
+	api.OutputUint(248, q)
+	api.OutputUint(248, r)
+	api.OutputUint(248, api.Uint248.Sqrt(cc))
+	api.OutputBool(api.Uint248.IsLessThan(a, cc))
+	api.OutputBool(api.Int248.IsLessThan(newI248(c.A), newI248(c.C)))
+	api.OutputUint(248, api.Int248.ABS(newI248(c.B)))
+
+	bits := api.Uint248.ToBinary(cc, 248)
+	api.OutputBytes32(api.Bytes32.FromBinary(bits[:64]...))
+	bits64 := make([]Uint64, 64)
+	for i := range bits64 {
+		bits64[i] = newU64(bits[i].Val)
+	}
+	api.OutputUint64(64, api.Uint64.Add(api.Uint64.FromBinary(bits64...), newU64(1)))
+
+	p := api.Uint521.Mul(api.ToUint521(a), api.ToUint521(b))
+	api.Uint521.AssertIsLessOrEqual(api.ToUint521(cc), p)
+	return nil
+}
+
+type TestEmulatorStreamCircuit struct {
+	Threshold frontend.Variable
+}
+
+func (c *TestEmulatorStreamCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 4, 0, 0
+}
+
+func (c *TestEmulatorStreamCircuit) Define(api *CircuitAPI, in DataInput) error {
+	receipts := NewDataStream(api, in.Receipts)
+	values := Map(receipts, func(r Receipt) Uint248 { return api.ToUint248(r.Fields[0].Value) })
+	AssertEach(values, func(v Uint248) Uint248 {
+		api.Uint248.AssertIsLessOrEqual(v, newU248(c.Threshold))
+		return newU248(1)
+	})
+	api.OutputUint(248, Sum(values))
+	api.OutputUint(248, Max(values))
+	api.OutputUint(248, Min(values))
+	api.OutputUint(64, Count(values))
+	api.OutputUint(248, Median(values))
+	grouped, err := GroupBy(receipts, func(acc Uint248, r Receipt) Uint248 {
+		return api.Uint248.Add(acc, api.ToUint248(r.Fields[0].Value))
+	}, newU248(0), func(r Receipt) Uint248 { return r.Fields[0].Contract }, 2)
+	if err != nil {
+		return err
+	}
+	api.OutputUint(248, Sum(grouped))
+	return nil
+}
+
+type TestEmulatorPanicCircuit struct{}
+
+func (c *TestEmulatorPanicCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 4, 0, 0
+}
+
+func (c *TestEmulatorPanicCircuit) Define(api *CircuitAPI, in DataInput) error {
+	GetUnderlying(NewDataStream(api, in.Receipts), 4)
+	return nil
+}
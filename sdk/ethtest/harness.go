@@ -0,0 +1,262 @@
+// Package ethtest deploys Brevis app contracts on the simulated backend of
+// go-ethereum, so that their handling of circuit outputs can be tested in Go
+// against the outputs of real circuit inputs, without Hardhat or a chain.
+package ethtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/eth"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// Artifact is a compiled contract
+type Artifact struct {
+	Name     string
+	ABI      abi.ABI
+	Bytecode []byte
+}
+
+// NewArtifact parses the ABI JSON and the hex bytecode of a compiled contract
+func NewArtifact(name, abiJSON, bytecode string) (*Artifact, error) {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, fmt.Errorf("invalid abi of %s: %w", name, err)
+	}
+	code, err := hexutil.Decode(bytecode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bytecode of %s: %w", name, err)
+	}
+	return &Artifact{Name: name, ABI: parsed, Bytecode: code}, nil
+}
+
+// LoadHardhatArtifact reads the artifact of the contract from the artifacts
+// directory of Hardhat, e.g. examples/contracts/artifacts after
+// `npx hardhat compile`
+func LoadHardhatArtifact(artifactsDir, name string) (*Artifact, error) {
+	var path string
+	err := filepath.WalkDir(artifactsDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == name+".json" && filepath.Base(filepath.Dir(p)) != "build-info" {
+			path = p
+			return fs.SkipAll
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no artifact of %s in %s", name, artifactsDir)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var artifact struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+	}
+	if err = json.Unmarshal(b, &artifact); err != nil {
+		return nil, fmt.Errorf("invalid artifact %s: %w", path, err)
+	}
+	return NewArtifact(name, string(artifact.ABI), artifact.Bytecode)
+}
+
+// Harness is a simulated chain with a funded account that deploys contracts and
+// sends transactions, and an address that app contracts accept callbacks from
+// as BrevisRequest
+type Harness struct {
+	Backend *simulated.Backend
+	Client  simulated.Client
+	Auth    *bind.TransactOpts
+
+	// BrevisRequest is the address to deploy app contracts with
+	BrevisRequest common.Address
+	mock          *bind.BoundContract
+}
+
+// NewHarness starts a simulated chain. If mockBrevisRequest is not nil, it is
+// deployed (see MockBrevisRequest.sol in examples/contracts) and callbacks are
+// delivered through it. Otherwise the account of the harness calls apps
+// directly as their BrevisRequest.
+func NewHarness(mockBrevisRequest *Artifact) (*Harness, error) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	balance := new(big.Int).Exp(big.NewInt(10), big.NewInt(24), nil)
+	backend := simulated.NewBackend(types.GenesisAlloc{from: {Balance: balance}})
+	// the genesis block has a difficulty, so calls on it, e.g. the gas estimation
+	// of deployments, run before the merge and reject PUSH0 of solc >= 0.8.20
+	backend.Commit()
+	h := &Harness{Backend: backend, Client: backend.Client(), BrevisRequest: from}
+	chainId, err := h.Client.ChainID(context.Background())
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if h.Auth, err = bind.NewKeyedTransactorWithChainID(key, chainId); err != nil {
+		backend.Close()
+		return nil, err
+	}
+	if mockBrevisRequest != nil {
+		addr, err := h.Deploy(mockBrevisRequest)
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		h.BrevisRequest = addr
+		h.mock = h.Bind(mockBrevisRequest, addr)
+	}
+	return h, nil
+}
+
+// Close stops the simulated chain
+func (h *Harness) Close() error {
+	return h.Backend.Close()
+}
+
+// Deploy deploys the contract with the constructor args and mines it
+func (h *Harness) Deploy(a *Artifact, args ...interface{}) (common.Address, error) {
+	addr, tx, _, err := bind.DeployContract(h.Auth, a.ABI, a.Bytecode, h.Client, args...)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to deploy %s: %w", a.Name, err)
+	}
+	if _, err = h.mine(tx); err != nil {
+		return common.Address{}, fmt.Errorf("failed to deploy %s: %w", a.Name, err)
+	}
+	return addr, nil
+}
+
+// Bind returns the contract of the artifact deployed at addr
+func (h *Harness) Bind(a *Artifact, addr common.Address) *bind.BoundContract {
+	return bind.NewBoundContract(addr, a.ABI, h.Client, h.Client, h.Client)
+}
+
+// Transact calls the method of the contract deployed at addr, e.g. setVkHash,
+// and returns the receipt once it is mined
+func (h *Harness) Transact(a *Artifact, addr common.Address, method string, args ...interface{}) (*types.Receipt, error) {
+	tx, err := h.Bind(a, addr).Transact(h.Auth, method, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", a.Name, method, err)
+	}
+	return h.mine(tx)
+}
+
+// Callback delivers the output of the circuit input to the app contract at
+// addr through brevisCallback, as BrevisRequest does once the proof of the
+// input is verified, and returns the receipt once it is mined
+func (h *Harness) Callback(app common.Address, appVkHash common.Hash, in sdk.CircuitInput) (*types.Receipt, error) {
+	output := in.GetAbiPackedOutput()
+	var tx *types.Transaction
+	var err error
+	if h.mock != nil {
+		tx, err = h.mock.Transact(h.Auth, "fulfillRequest", app, appVkHash, output)
+	} else {
+		var brevisApp *eth.BrevisAppTransactor
+		if brevisApp, err = eth.NewBrevisAppTransactor(app, h.Client); err != nil {
+			return nil, err
+		}
+		tx, err = brevisApp.BrevisCallback(h.Auth, appVkHash, output)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("brevisCallback: %w", err)
+	}
+	return h.mine(tx)
+}
+
+// BatchCallback is like Callback with brevisBatchCallback for several inputs
+func (h *Harness) BatchCallback(app common.Address, appVkHashes []common.Hash, ins []sdk.CircuitInput) (*types.Receipt, error) {
+	if len(appVkHashes) != len(ins) {
+		return nil, fmt.Errorf("%d vk hashes for %d circuit inputs", len(appVkHashes), len(ins))
+	}
+	vkHashes := make([][32]byte, len(ins))
+	outputs := make([][]byte, len(ins))
+	for i, in := range ins {
+		vkHashes[i] = appVkHashes[i]
+		outputs[i] = in.GetAbiPackedOutput()
+	}
+	var tx *types.Transaction
+	var err error
+	if h.mock != nil {
+		tx, err = h.mock.Transact(h.Auth, "fulfillRequests", app, vkHashes, outputs)
+	} else {
+		var brevisApp *eth.BrevisAppTransactor
+		if brevisApp, err = eth.NewBrevisAppTransactor(app, h.Client); err != nil {
+			return nil, err
+		}
+		tx, err = brevisApp.BrevisBatchCallback(h.Auth, vkHashes, outputs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("brevisBatchCallback: %w", err)
+	}
+	return h.mine(tx)
+}
+
+// Events decodes the events of the contract with the name from the logs of the
+// receipt, including the indexed fields
+func (h *Harness) Events(a *Artifact, receipt *types.Receipt, name string) ([]map[string]interface{}, error) {
+	event, ok := a.ABI.Events[name]
+	if !ok {
+		return nil, fmt.Errorf("no event %s in %s", name, a.Name)
+	}
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	var events []map[string]interface{}
+	for _, log := range receipt.Logs {
+		if len(log.Topics) == 0 || log.Topics[0] != event.ID {
+			continue
+		}
+		values := map[string]interface{}{}
+		if err := a.ABI.UnpackIntoMap(values, name, log.Data); err != nil {
+			return nil, err
+		}
+		if err := abi.ParseTopicsIntoMap(values, indexed, log.Topics[1:]); err != nil {
+			return nil, err
+		}
+		events = append(events, values)
+	}
+	return events, nil
+}
+
+// mine mines the tx and returns its receipt, or the revert reason if it failed
+func (h *Harness) mine(tx *types.Transaction) (*types.Receipt, error) {
+	h.Backend.Commit()
+	receipt, err := h.Client.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		return receipt, nil
+	}
+	// replay the tx to get the revert reason
+	msg := ethereum.CallMsg{From: h.Auth.From, To: tx.To(), Gas: tx.Gas(), Value: tx.Value(), Data: tx.Data()}
+	_, err = h.Client.CallContract(context.Background(), msg, new(big.Int).Sub(receipt.BlockNumber, big.NewInt(1)))
+	if err == nil {
+		err = fmt.Errorf("execution reverted")
+	}
+	return receipt, fmt.Errorf("tx %s failed: %w", tx.Hash(), err)
+}
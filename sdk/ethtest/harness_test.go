@@ -0,0 +1,205 @@
+package ethtest
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/examples/slot"
+	"github.com/brevis-network/brevis-sdk/examples/tokenTransfer"
+	"github.com/brevis-network/brevis-sdk/examples/tradingvolume"
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/eth"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The artifacts of MockBrevisRequest and of the example apps are committed in
+// testdata, compiled with the solc and the settings of examples/contracts, so
+// that the tests run without Hardhat. Regenerate them when the contracts change.
+// testdata/openzeppelin has the OpenZeppelin v5 contracts the examples import.
+//go:generate go run ../internal/solcjs -root ../../examples/contracts -remap @openzeppelin/=testdata/openzeppelin/ -runs 800 -via-ir -o testdata contracts/mock/MockBrevisRequest.sol contracts/examples/slot/SlotValueExample.sol contracts/examples/tokenTransfer/TokenTransferExample.sol contracts/examples/tradingvolume/TradingVolumeExample.sol
+
+const artifactsDir = "testdata"
+
+var (
+	usdc    = common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	account = common.HexToAddress("0x58b529F9084D7eAA598EB3477Fe36064C5B7bbC1")
+	vkHash  = common.HexToHash("0x01")
+)
+
+// loggerBytecode deploys a contract that logs its calldata with LOG0, i.e.
+// CALLDATASIZE PUSH0 PUSH0 CALLDATACOPY CALLDATASIZE PUSH0 LOG0 STOP
+const loggerBytecode = "0x6008600a5f3960085ff3" + "365f5f37365fa000"
+
+func TestHarnessCallback(t *testing.T) {
+	brevisApp, err := eth.BrevisAppMetaData.GetAbi()
+	check(err)
+	logger := &Artifact{Name: "Logger", ABI: *brevisApp}
+	logger.Bytecode = common.FromHex(loggerBytecode)
+
+	h, err := NewHarness(nil)
+	check(err)
+	defer h.Close()
+	addr, err := h.Deploy(logger)
+	check(err)
+	in := tokenTransferInput(600000000)
+	receipt, err := h.Callback(addr, vkHash, in)
+	check(err)
+
+	// the app receives the abi packed output of the circuit
+	data := receipt.Logs[0].Data
+	args, err := brevisApp.Methods["brevisCallback"].Inputs.Unpack(data[4:])
+	check(err)
+	output := args[1].([]byte)
+	expected := append(append(common.LeftPadBytes(big.NewInt(100).Bytes(), 8), account.Bytes()...), common.BigToHash(big.NewInt(600000000)).Bytes()...)
+	if args[0].([32]byte) != vkHash || !bytes.Equal(output, expected) {
+		t.Fatalf("unexpected callback vk hash %x, output %x", args[0], output)
+	}
+
+	receipt, err = h.BatchCallback(addr, []common.Hash{vkHash, vkHash}, []sdk.CircuitInput{in, tokenTransferInput(700000000)})
+	check(err)
+	args, err = brevisApp.Methods["brevisBatchCallback"].Inputs.Unpack(receipt.Logs[0].Data[4:])
+	check(err)
+	if outputs := args[1].([][]byte); len(outputs) != 2 || !bytes.Equal(outputs[0], expected) {
+		t.Fatalf("unexpected batch callback outputs %x", outputs)
+	}
+}
+
+func TestTokenTransferExample(t *testing.T) {
+	h, app, addr := deployExample("TokenTransfer")
+	defer h.Close()
+
+	receipt, err := h.Callback(addr, vkHash, tokenTransferInput(600000000))
+	check(err)
+	events, err := h.Events(app, receipt, "TransferAmountAttested")
+	check(err)
+	if len(events) != 1 ||
+		events[0]["blockNum"].(uint64) != 100 ||
+		events[0]["account"].(common.Address) != account ||
+		events[0]["volume"].(*big.Int).Int64() != 600000000 {
+		t.Fatalf("unexpected events %v", events)
+	}
+
+	_, err = h.Callback(addr, common.HexToHash("0x02"), tokenTransferInput(600000000))
+	if err == nil || !strings.Contains(err.Error(), "invalid vk") {
+		t.Fatalf("expected an invalid vk error, got %v", err)
+	}
+}
+
+func TestSlotValueExample(t *testing.T) {
+	h, app, addr := deployExample("SlotValueExample")
+	defer h.Close()
+
+	contract := common.HexToAddress("0x5427FEFA711Eff984124bFBB1AB6fbf5E3DA1820")
+	owner := common.HexToAddress("0xf5DB804101d8600c26598A1Ba465166c33CdAA4b")
+	q := sdk.NewMockBrevisApp()
+	// the circuit reads the owner from the storage slot at index 1
+	q.AddMockStorage(sdk.StorageData{
+		BlockNum:     big.NewInt(19000000),
+		BlockBaseFee: big.NewInt(1),
+		Address:      contract,
+		Slot:         common.Hash{},
+		Value:        common.BytesToHash(owner.Bytes()),
+	}, 1)
+	in, err := q.BuildMockCircuitInput(&slot.AppCircuit{})
+	check(err)
+
+	receipt, err := h.Callback(addr, vkHash, in)
+	check(err)
+	events, err := h.Events(app, receipt, "PastOwnerAttested")
+	check(err)
+	if len(events) != 1 ||
+		events[0]["contractAddr"].(common.Address) != contract ||
+		events[0]["ownerAddr"].(common.Address) != owner ||
+		events[0]["blockNum"].(uint64) != 19000000 {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+func TestTradingVolumeExample(t *testing.T) {
+	h, app, addr := deployExample("TradingVolumeExample")
+	defer h.Close()
+
+	router := common.HexToAddress("0xEf1c6E67703c7BD7107eed8303Fbe6EC2554BF6B")
+	pool := common.HexToAddress("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640")
+	eventIdSwap := crypto.Keccak256Hash([]byte("Swap(address,address,int256,int256,uint160,uint128,int24)"))
+	eventIdTransfer := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	// swap adds a swap of the account with the amount of USDC, negative if the
+	// account bought USDC. If ethOut, the account took native ETH out, i.e. the
+	// recipient of the swap is the router.
+	q := sdk.NewMockBrevisApp()
+	swap := func(blockNum, amount int64, ethOut bool) {
+		recipient := account
+		if ethOut {
+			recipient = router
+		}
+		q.AddMockReceipt(sdk.ReceiptData{
+			BlockNum:     big.NewInt(blockNum),
+			BlockBaseFee: big.NewInt(1),
+			MptKeyPath:   big.NewInt(blockNum),
+			Fields: []sdk.LogFieldData{
+				{Contract: pool, EventID: eventIdSwap, LogPos: 2, IsTopic: false, FieldIndex: 0, Value: common.BytesToHash(math.U256Bytes(big.NewInt(amount)))},
+				{Contract: pool, EventID: eventIdSwap, LogPos: 2, IsTopic: true, FieldIndex: 2, Value: common.BytesToHash(recipient.Bytes())},
+				{Contract: usdc, EventID: eventIdTransfer, LogPos: 3, IsTopic: true, FieldIndex: 1, Value: common.BytesToHash(account.Bytes())},
+			},
+		})
+	}
+	swap(19000200, 1000000000, true)
+	swap(19000100, -400000000, false)
+	in, err := q.BuildMockCircuitInput(&tradingvolume.AppCircuit{UserAddr: sdk.ConstUint248(account)})
+	check(err)
+
+	receipt, err := h.Callback(addr, vkHash, in)
+	check(err)
+	events, err := h.Events(app, receipt, "TradingVolumeAttested")
+	check(err)
+	if len(events) != 1 ||
+		events[0]["account"].(common.Address) != account ||
+		events[0]["sumVolume"].(*big.Int).Int64() != 1400000000 ||
+		events[0]["minBlockNum"].(uint64) != 19000100 {
+		t.Fatalf("unexpected events %v", events)
+	}
+}
+
+// deployExample deploys the example app through MockBrevisRequest, with
+// vkHash as its vk hash
+func deployExample(name string) (*Harness, *Artifact, common.Address) {
+	mock, err := LoadHardhatArtifact(artifactsDir, "MockBrevisRequest")
+	check(err)
+	app, err := LoadHardhatArtifact(artifactsDir, name)
+	check(err)
+	h, err := NewHarness(mock)
+	check(err)
+	addr, err := h.Deploy(app, h.BrevisRequest)
+	check(err)
+	_, err = h.Transact(app, addr, "setVkHash", vkHash)
+	check(err)
+	return h, app, addr
+}
+
+// tokenTransferInput builds the input of the token transfer example for a
+// USDC transfer of the volume from account
+func tokenTransferInput(volume int64) sdk.CircuitInput {
+	q := sdk.NewMockBrevisApp()
+	q.AddMockReceipt(sdk.ReceiptData{
+		BlockNum:     big.NewInt(100),
+		BlockBaseFee: big.NewInt(1),
+		MptKeyPath:   big.NewInt(1),
+		Fields: []sdk.LogFieldData{
+			{Contract: usdc, IsTopic: true, FieldIndex: 1, Value: common.BytesToHash(account.Bytes())},
+			{Contract: usdc, IsTopic: false, FieldIndex: 0, Value: common.BigToHash(big.NewInt(volume))},
+		},
+	})
+	in, err := q.BuildMockCircuitInput(&tokenTransfer.AppCircuit{})
+	check(err)
+	return in
+}
+
+func check(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,377 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	pgoldilocks "github.com/OpenAssetStandards/poseidon-goldilocks-go"
+	"github.com/brevis-network/brevis-sdk/sdk/proto/gwproto"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// simulatorStatusPollInterval is the status poll interval of the BrevisApps of
+// GatewaySimulator.NewBrevisApp, the simulator answers without delay
+const simulatorStatusPollInterval = 10 * time.Millisecond
+
+// GatewayFeeSchedule is the fee the GatewaySimulator charges for a query: Base
+// plus a fee per receipt, storage slot and transaction. Nil fees are zero.
+type GatewayFeeSchedule struct {
+	Base           *big.Int
+	PerReceipt     *big.Int
+	PerStorage     *big.Int
+	PerTransaction *big.Int
+}
+
+// Fee returns the fee of a query of the data
+func (f GatewayFeeSchedule) Fee(nbReceipts, nbStorage, nbTxs int) *big.Int {
+	fee := new(big.Int)
+	add := func(v *big.Int, n int) {
+		if v != nil {
+			fee.Add(fee, new(big.Int).Mul(v, big.NewInt(int64(n))))
+		}
+	}
+	add(f.Base, 1)
+	add(f.PerReceipt, nbReceipts)
+	add(f.PerStorage, nbStorage)
+	add(f.PerTransaction, nbTxs)
+	return fee
+}
+
+type GatewaySimulatorConfig struct {
+	// HashInfo is returned by GetCircuitDigest. Nil hashes are zero.
+	HashInfo *BrevisHashInfo
+	// VkHashes are the Brevis vk hashes registered from the start, i.e. the vk
+	// hashes the setup prints and stores in the setup manifest. More are
+	// registered with SubmitVK.
+	VkHashes []common.Hash
+	Fees     GatewayFeeSchedule
+	// AutoPay marks queries as paid once they are prepared. Otherwise queries
+	// wait for Pay, which stands for the fee payment of sendRequest.
+	AutoPay bool
+}
+
+// GatewaySimulator is an in-process gwproto.GatewayServer for running the
+// Brevis flow of flow.md offline, e.g. in integration tests. Its state only
+// changes with the calls it receives, so the same calls always lead to the
+// same query keys, fees and statuses:
+//   - SubmitVK registers the vk hash of an app circuit.
+//   - PrepareQuery computes the vk hash of the app circuit info and rejects
+//     the query if it is not registered. Otherwise it issues a query key and
+//     quotes the fee of the query. The query is QS_TO_BE_PAID, or QS_PAID with
+//     AutoPay.
+//   - Pay moves a query from QS_TO_BE_PAID to QS_PAID.
+//   - SubmitAppCircuitProof verifies the proof against the vk and the public
+//     witness of the app circuit info of PrepareQuery. A valid proof moves a
+//     paid query to QS_PROOF_READY, an invalid one moves it to QS_FAILED.
+//   - GetQueryStatus reports QS_PROOF_READY once, after which the final proof
+//     is submitted and the query is QS_COMPLETE.
+//
+// The simulator neither queries nor proves any on-chain data.
+type GatewaySimulator struct {
+	gwproto.UnimplementedGatewayServer
+
+	config GatewaySimulatorConfig
+
+	lock     sync.Mutex
+	nonce    uint64
+	queries  map[string]*simulatedQuery
+	vkHashes map[common.Hash]bool
+
+	server *grpc.Server
+	lis    *bufconn.Listener
+}
+
+var _ gwproto.GatewayServer = &GatewaySimulator{}
+
+type simulatedQuery struct {
+	nonce         uint64
+	status        gwproto.QueryStatus
+	vk            plonk.VerifyingKey
+	publicWitness witness.Witness
+	proof         string
+	txHash        string
+}
+
+func NewGatewaySimulator(config GatewaySimulatorConfig) *GatewaySimulator {
+	s := &GatewaySimulator{
+		config:   config,
+		queries:  map[string]*simulatedQuery{},
+		vkHashes: map[common.Hash]bool{},
+		server:   grpc.NewServer(),
+		lis:      bufconn.Listen(1024 * 1024),
+	}
+	for _, h := range config.VkHashes {
+		s.vkHashes[h] = true
+	}
+	gwproto.RegisterGatewayServer(s.server, s)
+	go s.server.Serve(s.lis)
+	return s
+}
+
+// Client returns a GatewayClient connected to the simulator in process
+func (s *GatewaySimulator) Client() (*GatewayClient, error) {
+	conn, err := grpc.NewClient("passthrough:///gateway-simulator",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &GatewayClient{c: gwproto.NewGatewayClient(conn)}, nil
+}
+
+// Listen also serves the simulator at the TCP address, e.g. "127.0.0.1:0", and
+// returns the address it listens to, which can be used as the gateway URL of
+// NewBrevisApp or of the prover service
+func (s *GatewaySimulator) Listen(address string) (string, error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return "", fmt.Errorf("failed to start gateway simulator: %w", err)
+	}
+	go s.server.Serve(lis)
+	return lis.Addr().String(), nil
+}
+
+// Stop stops serving the simulator
+func (s *GatewaySimulator) Stop() {
+	s.server.Stop()
+}
+
+// NewBrevisApp returns a BrevisApp of the source chain that uses the simulator
+// as its gateway. It does not connect to any RPC, so the data added to it must
+// be complete, i.e. have the block number, base fee, timestamp and MPT key
+// path set.
+func (s *GatewaySimulator) NewBrevisApp(srcChainId uint64) (*BrevisApp, error) {
+	gc, err := s.Client()
+	if err != nil {
+		return nil, err
+	}
+	app, err := newBrevisAppWithGatewayClient(srcChainId, gc)
+	if err != nil {
+		return nil, err
+	}
+	app.statusPollInterval = simulatorStatusPollInterval
+	return app, nil
+}
+
+// Pay marks the query as paid
+func (s *GatewaySimulator) Pay(queryHash string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	q, ok := s.queries[queryHash]
+	if !ok {
+		return fmt.Errorf("query %s not found", queryHash)
+	}
+	if q.status != gwproto.QueryStatus_QS_TO_BE_PAID {
+		return fmt.Errorf("query %s is %s, not to be paid", queryHash, q.status)
+	}
+	q.status = gwproto.QueryStatus_QS_PAID
+	if q.proof != "" {
+		q.status = gwproto.QueryStatus_QS_PROOF_READY
+	}
+	return nil
+}
+
+// Status returns the status of the query without moving it forward like
+// GetQueryStatus, or QS_UNKNOWN for unknown queries
+func (s *GatewaySimulator) Status(queryHash string) gwproto.QueryStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if q, ok := s.queries[queryHash]; ok {
+		return q.status
+	}
+	return gwproto.QueryStatus_QS_UNKNOWN
+}
+
+func (s *GatewaySimulator) PrepareQuery(ctx context.Context, req *gwproto.PrepareQueryRequest) (*gwproto.PrepareQueryResponse, error) {
+	info := req.GetAppCircuitInfo()
+	if info == nil {
+		return &gwproto.PrepareQueryResponse{Err: newGatewayErr("app circuit info is required")}, nil
+	}
+	vk, err := decodeHex(plonk.NewVerifyingKey(ecc.BN254), info.GetVk())
+	if err != nil {
+		return &gwproto.PrepareQueryResponse{Err: newGatewayErr("invalid vk: %s", err.Error())}, nil
+	}
+	a := Allocation{MaxReceipts: int(info.GetMaxReceipts()), MaxStorage: int(info.GetMaxStorage()), MaxTransactions: int(info.GetMaxTx())}
+	vkHash, err := brevisVkHash(vk, a, s.hashInfo())
+	if err != nil {
+		return &gwproto.PrepareQueryResponse{Err: newGatewayErr("failed to compute vk hash: %s", err.Error())}, nil
+	}
+	wpub, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	if wpub, err = decodeHex(wpub, info.GetWitness()); err != nil {
+		return &gwproto.PrepareQueryResponse{Err: newGatewayErr("invalid witness: %s", err.Error())}, nil
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.vkHashes[common.HexToHash(vkHash)] {
+		return &gwproto.PrepareQueryResponse{Err: newGatewayErr("vk hash %s of allocation %s is not registered", vkHash, a)}, nil
+	}
+	nonce := s.nonce
+	s.nonce++
+	queryHash := hexutil.Encode(crypto.Keccak256(binary.BigEndian.AppendUint64(nil, nonce), b))
+	status := gwproto.QueryStatus_QS_TO_BE_PAID
+	if s.config.AutoPay {
+		status = gwproto.QueryStatus_QS_PAID
+	}
+	s.queries[queryHash] = &simulatedQuery{nonce: nonce, status: status, vk: vk, publicWitness: wpub}
+	fee := s.config.Fees.Fee(len(req.GetReceiptInfos()), len(req.GetStorageQueryInfos()), len(req.GetTransactionInfos()))
+	return &gwproto.PrepareQueryResponse{
+		QueryKey: &gwproto.QueryKey{QueryHash: queryHash, Nonce: nonce},
+		Fee:      fee.String(),
+	}, nil
+}
+
+func (s *GatewaySimulator) SubmitAppCircuitProof(ctx context.Context, req *gwproto.SubmitAppCircuitProofRequest) (*gwproto.SubmitAppCircuitProofResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	q, err := s.query(req.GetQueryKey())
+	if err != nil {
+		return &gwproto.SubmitAppCircuitProofResponse{Err: newGatewayErr(err.Error())}, nil
+	}
+	if q.proof != "" || (q.status != gwproto.QueryStatus_QS_TO_BE_PAID && q.status != gwproto.QueryStatus_QS_PAID) {
+		return &gwproto.SubmitAppCircuitProofResponse{Err: newGatewayErr("query is %s, the proof cannot be submitted", q.status)}, nil
+	}
+	proof, err := decodeHex(plonk.NewProof(ecc.BN254), req.GetProof())
+	if err == nil {
+		err = Verify(q.vk, q.publicWitness, proof)
+	}
+	if err != nil {
+		q.status = gwproto.QueryStatus_QS_FAILED
+		return &gwproto.SubmitAppCircuitProofResponse{Err: newGatewayErr("invalid proof: %s", err.Error())}, nil
+	}
+	q.proof = req.GetProof()
+	if q.status == gwproto.QueryStatus_QS_PAID {
+		q.status = gwproto.QueryStatus_QS_PROOF_READY
+	}
+	return &gwproto.SubmitAppCircuitProofResponse{Success: true}, nil
+}
+
+func (s *GatewaySimulator) GetQueryStatus(ctx context.Context, req *gwproto.GetQueryStatusRequest) (*gwproto.GetQueryStatusResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	q, err := s.query(req.GetQueryKey())
+	if err != nil {
+		return &gwproto.GetQueryStatusResponse{Err: newGatewayErr(err.Error())}, nil
+	}
+	res := &gwproto.GetQueryStatusResponse{Status: q.status, TxHash: q.txHash, Proof: q.proof}
+	if q.status == gwproto.QueryStatus_QS_PROOF_READY {
+		// the final proof is submitted by the next status query
+		q.status = gwproto.QueryStatus_QS_COMPLETE
+		q.txHash = hexutil.Encode(crypto.Keccak256([]byte("final proof"), []byte(req.GetQueryKey().GetQueryHash())))
+	}
+	return res, nil
+}
+
+func (s *GatewaySimulator) GetCircuitDigest(ctx context.Context, req *gwproto.CircuitDigestRequest) (*gwproto.CircuitDigestResponse, error) {
+	h := s.hashInfo()
+	res := &gwproto.CircuitDigestResponse{}
+	for _, digest := range []*pgoldilocks.HashOut256{
+		h.P2AggRecursionLeafCircuitDigestHash,
+		h.P2AggRecursionMiddleFormMiddleLeafCircuitDigestHash,
+		h.P2AggRecursionNoLeafCircuitDigestHash,
+	} {
+		res.HashesLimbs = append(res.HashesLimbs, digest[:]...)
+	}
+	for _, v := range []*big.Int{
+		h.GnarkReceiptVkHash, h.GnarkStorageVkHash, h.GnarkTxVkHash, h.GnarkMiddleNodeVkHash,
+		h.P2Bn128WrapCircuitDigestHashForOnly2Leaf, h.P2Bn128WrapCircuitDigestHashForOnlyFromLeafRecursion, h.P2Bn128WrapCircuitDigestHash,
+	} {
+		res.GnarkVks = append(res.GnarkVks, hexutil.EncodeBig(v))
+	}
+	return res, nil
+}
+
+// hashInfo returns the hash info of the config with the nil hashes set to zero,
+// as the BrevisApps receive it from GetCircuitDigest
+func (s *GatewaySimulator) hashInfo() *BrevisHashInfo {
+	h := BrevisHashInfo{}
+	if s.config.HashInfo != nil {
+		h = *s.config.HashInfo
+	}
+	for _, digest := range []**pgoldilocks.HashOut256{
+		&h.P2AggRecursionLeafCircuitDigestHash,
+		&h.P2AggRecursionMiddleFormMiddleLeafCircuitDigestHash,
+		&h.P2AggRecursionNoLeafCircuitDigestHash,
+	} {
+		if *digest == nil {
+			*digest = &pgoldilocks.HashOut256{}
+		}
+	}
+	for _, v := range []**big.Int{
+		&h.GnarkReceiptVkHash, &h.GnarkStorageVkHash, &h.GnarkTxVkHash, &h.GnarkMiddleNodeVkHash,
+		&h.P2Bn128WrapCircuitDigestHashForOnly2Leaf, &h.P2Bn128WrapCircuitDigestHashForOnlyFromLeafRecursion, &h.P2Bn128WrapCircuitDigestHash,
+	} {
+		if *v == nil {
+			*v = new(big.Int)
+		}
+	}
+	return &h
+}
+
+func (s *GatewaySimulator) GetCircuitDummyInputRequest(ctx context.Context, req *gwproto.CircuitDummyInputRequest) (*gwproto.CircuitDummyInputResponse, error) {
+	res, err := mockDummyInputCommitments()
+	if err != nil {
+		return &gwproto.CircuitDummyInputResponse{Err: newGatewayErr(err.Error())}, nil
+	}
+	return res, nil
+}
+
+func (s *GatewaySimulator) SubmitVK(ctx context.Context, req *gwproto.SubmitVKRequest) (*gwproto.SubmitVKResponse, error) {
+	if _, err := decodeHex(plonk.NewVerifyingKey(ecc.BN254), req.GetVkRaw()); err != nil {
+		return &gwproto.SubmitVKResponse{Err: newGatewayErr("invalid vk: %s", err.Error())}, nil
+	}
+	vkHash, err := hexutil.Decode(req.GetVkHash())
+	if err != nil || len(vkHash) != common.HashLength {
+		return &gwproto.SubmitVKResponse{Err: newGatewayErr("invalid vk hash %s", req.GetVkHash())}, nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.vkHashes[common.BytesToHash(vkHash)] = true
+	return &gwproto.SubmitVKResponse{}, nil
+}
+
+func (s *GatewaySimulator) query(key *gwproto.QueryKey) (*simulatedQuery, error) {
+	q, ok := s.queries[key.GetQueryHash()]
+	if !ok || q.nonce != key.GetNonce() {
+		return nil, fmt.Errorf("query %s with nonce %d not found", key.GetQueryHash(), key.GetNonce())
+	}
+	return q, nil
+}
+
+func newGatewayErr(format string, args ...any) *gwproto.ErrMsg {
+	return &gwproto.ErrMsg{Code: gwproto.ErrCode_ERROR_CODE_UNDEFINED, Msg: fmt.Sprintf(format, args...)}
+}
+
+func decodeHex[T io.ReaderFrom](v T, s string) (T, error) {
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		return v, err
+	}
+	_, err = v.ReadFrom(bytes.NewReader(b))
+	return v, err
+}
@@ -0,0 +1,128 @@
+package sdk
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brevis-network/brevis-sdk/sdk/proto/gwproto"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestGatewaySimulator(t *testing.T) {
+	// a small circuit stands for the app circuit, its vk is registered with the
+	// vk hash of the tier of TestTiersCircuit the 3 receipts are proven in
+	ccs, pk, vk := testPkSetup(&exportCircuit{})
+	w, err := frontend.NewWitness(&exportCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	check(err)
+	proof, err := Prove(ccs, pk, w)
+	check(err)
+	vkHash, err := brevisVkHash(vk, Allocation{MaxReceipts: 32}, testHashInfo())
+	check(err)
+
+	config := GatewaySimulatorConfig{
+		HashInfo: testHashInfo(),
+		VkHashes: []common.Hash{common.HexToHash(vkHash)},
+		Fees:     GatewayFeeSchedule{Base: big.NewInt(1000), PerReceipt: big.NewInt(10)},
+	}
+	sim := NewGatewaySimulator(config)
+	defer sim.Stop()
+	prepareRequest := func(sim *GatewaySimulator) (*BrevisApp, common.Hash, uint64, *big.Int, error) {
+		q, err := sim.NewBrevisApp(1)
+		check(err)
+		for i := 0; i < 3; i++ {
+			q.AddReceipt(simulatedReceipt(i))
+		}
+		_, err = q.BuildCircuitInput(&TestTiersCircuit{})
+		check(err)
+		option := gwproto.QueryOption_ZK_MODE
+		_, requestId, nonce, fee, err := q.PrepareRequest(vk, w, 1, 1, common.Address{}, common.Address{1}, 0, &option, "")
+		return q, requestId, nonce, fee, err
+	}
+	prepare := func(sim *GatewaySimulator) (*BrevisApp, common.Hash, uint64, *big.Int) {
+		q, requestId, nonce, fee, err := prepareRequest(sim)
+		check(err)
+		return q, requestId, nonce, fee
+	}
+
+	q, requestId, nonce, fee := prepare(sim)
+	if q.GnarkTxVkHash.Int64() != 18 || q.P2AggRecursionNoLeafCircuitDigestHash[3] != 12 {
+		t.Fatalf("unexpected hash info %+v", q.BrevisHashInfo)
+	}
+	if nonce != 0 || fee.Int64() != 1030 {
+		t.Fatalf("unexpected nonce %d and fee %s", nonce, fee)
+	}
+	queryHash := hexutil.Encode(requestId[:])
+	expectStatus := func(sim *GatewaySimulator, queryHash string, expected gwproto.QueryStatus) {
+		t.Helper()
+		if status := sim.Status(queryHash); status != expected {
+			t.Fatalf("expected status %s, got %s", expected, status)
+		}
+	}
+	expectStatus(sim, queryHash, gwproto.QueryStatus_QS_TO_BE_PAID)
+	check(q.SubmitProof(proof))
+	expectStatus(sim, queryHash, gwproto.QueryStatus_QS_TO_BE_PAID)
+	check(sim.Pay(queryHash))
+	expectStatus(sim, queryHash, gwproto.QueryStatus_QS_PROOF_READY)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	tx, err := q.WaitFinalProofSubmitted(ctx)
+	check(err)
+	if tx == (common.Hash{}) {
+		t.Fatal("expected the tx of the final proof")
+	}
+	expectStatus(sim, queryHash, gwproto.QueryStatus_QS_COMPLETE)
+	if err = q.SubmitProof(proof); err == nil {
+		t.Fatal("a completed query should not accept proofs")
+	}
+
+	// queries of unregistered vks are rejected until the vk is submitted
+	config.VkHashes = nil
+	sim2 := NewGatewaySimulator(config)
+	defer sim2.Stop()
+	if _, _, _, _, err = prepareRequest(sim2); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected an unregistered vk error, got %v", err)
+	}
+	res, err := sim2.SubmitVK(context.Background(), &gwproto.SubmitVKRequest{VkHash: vkHash, VkRaw: hexutil.Encode(mustWriteToBytes(vk))})
+	check(err)
+	if res.Err != nil {
+		t.Fatalf("failed to submit vk: %s", res.Err)
+	}
+
+	// the same calls lead to the same query keys
+	_, requestId2, _, _ := prepare(sim2)
+	if requestId2 != requestId {
+		t.Fatalf("query keys are not deterministic: %s, %s", requestId, requestId2)
+	}
+	q, requestId, nonce, _ = prepare(sim2)
+	if requestId == requestId2 || nonce != 1 {
+		t.Fatalf("expected a new query key, got %s with nonce %d", requestId, nonce)
+	}
+
+	// proofs of another public witness fail the query
+	w, err = frontend.NewWitness(&exportCircuit{X: 2, Y: 4}, ecc.BN254.ScalarField())
+	check(err)
+	proof, err = Prove(ccs, pk, w)
+	check(err)
+	if err = q.SubmitProof(proof); err == nil || !strings.Contains(err.Error(), "invalid proof") {
+		t.Fatalf("expected an invalid proof error, got %v", err)
+	}
+	expectStatus(sim2, hexutil.Encode(requestId[:]), gwproto.QueryStatus_QS_FAILED)
+	if err = sim2.Pay(hexutil.Encode(requestId[:])); err == nil {
+		t.Fatal("a failed query should not be paid")
+	}
+}
+
+// simulatedReceipt is a receipt that does not need to be queried from RPC
+func simulatedReceipt(i int) ReceiptData {
+	r := testTiersReceipt(i)
+	r.TxHash = common.BigToHash(big.NewInt(int64(i + 1)))
+	r.MptKeyPath = big.NewInt(int64(i + 1))
+	r.BlockTimestamp = 1700000000
+	return r
+}
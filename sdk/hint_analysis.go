@@ -0,0 +1,257 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// UnderconstrainedHint describes a hint call whose outputs can be changed
+// without failing any assertion of the circuit. A prover can then substitute
+// its own values for the hint outputs.
+type UnderconstrainedHint struct {
+	// Hint is the name of the hint function, e.g. sdk.QuoRemHint
+	Hint string
+	// Call is the index of the hint call, in the order Define makes them
+	Call int
+	// Contexts are the DataStream operations and the data index being processed
+	// when the hint is called, see DebugReport
+	Contexts []string
+	// CallSites are the SDK and user functions that lead to the hint call, from
+	// the innermost to the outermost
+	CallSites []string
+	// Perturbation is the change made to the hint outputs, e.g. "out[1]+1"
+	Perturbation string
+	// OutputChanged tells whether the perturbation also changes the circuit
+	// output
+	OutputChanged bool
+}
+
+func (h *UnderconstrainedHint) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "underconstrained hint: %s call #%d still satisfies the circuit with %s", h.Hint, h.Call, h.Perturbation)
+	if h.OutputChanged {
+		b.WriteString(" and a different circuit output")
+	}
+	b.WriteString("\n")
+	if len(h.Contexts) > 0 {
+		fmt.Fprintf(b, "  while: %s\n", strings.Join(h.Contexts, " > "))
+	}
+	for i, s := range h.CallSites {
+		prefix := "     "
+		if i == 0 {
+			prefix = "  at "
+		}
+		fmt.Fprintf(b, "%s%s\n", prefix, s)
+	}
+	return b.String()
+}
+
+// AnalyzeHints looks for hints whose outputs are not sufficiently constrained
+// by the app circuit. The circuit is first solved in the emulator (see
+// Emulate) with the assignment and the input, which must satisfy it. Then, for
+// every hint call, it is solved again with the hint outputs perturbed, one
+// output at a time: incremented, decremented, and swapped with the next output.
+// If any of these still satisfies the circuit, the perturbation is confirmed
+// with the gnark solver, where the hint is wrapped to perturb the outputs of the
+// same call, and the hint call is reported. Once a call is reported, the other
+// calls made from the same place are skipped.
+//
+// Both the hints called by the SDK, e.g. QuoRemHint in Uint248API.Div, and the
+// custom hints added with CircuitAPI.NewHint are analyzed. The hints of gnark
+// and the hints of the host circuit are not. Since only a few perturbations
+// are tried, an empty result does not prove that the hints are sound. The
+// perturbations are searched in the emulator only, which follows the semantics
+// of the gnark test engine, so a perturbation that the solver would accept but
+// the emulator rejects is not found.
+//
+// Example:
+//
+//	hints, err := sdk.AnalyzeHints(appCircuitAssignment, in)
+//	for _, h := range hints {
+//		fmt.Println(h)
+//	}
+func AnalyzeHints(assign AppCircuit, in CircuitInput) ([]*UnderconstrainedHint, error) {
+	data := in.Clone().DataInput
+	var calls []*UnderconstrainedHint
+	var outs [][]*big.Int
+	base, err := emulate(assign, data, func(call int, f solver.Hint, out []*big.Int, contexts []string) {
+		calls = append(calls, &UnderconstrainedHint{
+			Hint:      hintName(f),
+			Call:      call,
+			Contexts:  append([]string{}, contexts...),
+			CallSites: debugCallSites(),
+		})
+		outs = append(outs, copyBigInts(out))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !base.Ok() {
+		return nil, fmt.Errorf("the assignment does not satisfy the circuit:\n%s", base.Failures[0])
+	}
+
+	var ret []*UnderconstrainedHint
+	flagged := map[string]bool{}
+	// the calls are identified in the solver by the number of calls made to
+	// the same hint before, as the solver also calls the hints of gnark
+	nthCall := map[string]int{}
+	for i, call := range calls {
+		nth := nthCall[call.Hint]
+		nthCall[call.Hint]++
+		if strings.HasPrefix(call.Hint, "github.com/consensys/") {
+			continue
+		}
+		site := call.Hint + strings.Join(call.CallSites, ";")
+		if flagged[site] {
+			continue
+		}
+		for _, p := range hintPerturbations(outs[i]) {
+			res, err := emulate(assign, data, func(call int, _ solver.Hint, out []*big.Int, _ []string) {
+				if call == i {
+					p.apply(out)
+				}
+			})
+			if err != nil || !res.Ok() {
+				continue
+			}
+			if err = solvePerturbed(assign, data, call.Hint, nth, p); err != nil {
+				continue
+			}
+			call.Perturbation = p.name
+			call.OutputChanged = !bytes.Equal(res.Output, base.Output)
+			ret = append(ret, call)
+			flagged[site] = true
+			break
+		}
+	}
+	return ret, nil
+}
+
+// solvePerturbed solves the app circuit with the gnark test engine, with the
+// outputs of the nth call of the hint perturbed
+func solvePerturbed(assign AppCircuit, data DataInput, hint string, nth int, p hintPerturbation) error {
+	h := &perturbedHint{hint: hint, nth: nth, perturbation: p}
+	c := &perturbedHintCircuit{Guest: assign, Input: data, h: h}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil && !h.perturbed {
+		return fmt.Errorf("%s call #%d is not made by the solver", hint, nth)
+	}
+	return err
+}
+
+// perturbedHint is the nth call of the hint to perturb, and the calls of the
+// hint made so far
+type perturbedHint struct {
+	hint         string
+	nth          int
+	perturbation hintPerturbation
+	calls        int
+	perturbed    bool
+}
+
+// perturbedHintCircuit runs the app circuit with the outputs of a hint call
+// perturbed
+type perturbedHintCircuit struct {
+	Guest AppCircuit
+	Input DataInput
+	// a pointer, as the test engine solves a copy of the circuit
+	h *perturbedHint
+}
+
+func (c *perturbedHintCircuit) Define(g frontend.API) error {
+	return c.Guest.Define(NewCircuitAPI(&perturbedHintAPI{API: g, h: c.h}), c.Input)
+}
+
+// perturbedHintAPI wraps a frontend.API to wrap the hint of the perturbed call
+type perturbedHintAPI struct {
+	frontend.API
+	h *perturbedHint
+}
+
+func (p *perturbedHintAPI) Compiler() frontend.Compiler {
+	return &perturbedHintCompiler{Compiler: p.API.Compiler(), h: p.h}
+}
+
+// SetKeyValue and GetKeyValue forward to the wrapped API, which is required by
+// multicommit
+func (p *perturbedHintAPI) SetKeyValue(key, value any) {
+	p.API.(interface{ SetKeyValue(key, value any) }).SetKeyValue(key, value)
+}
+
+func (p *perturbedHintAPI) GetKeyValue(key any) any {
+	return p.API.(interface{ GetKeyValue(key any) any }).GetKeyValue(key)
+}
+
+type perturbedHintCompiler struct {
+	frontend.Compiler
+	h *perturbedHint
+}
+
+func (p *perturbedHintCompiler) NewHint(f solver.Hint, nbOutputs int, inputs ...frontend.Variable) ([]frontend.Variable, error) {
+	h := p.h
+	if hintName(f) != h.hint {
+		return p.Compiler.NewHint(f, nbOutputs, inputs...)
+	}
+	call := h.calls
+	h.calls++
+	if call != h.nth {
+		return p.Compiler.NewHint(f, nbOutputs, inputs...)
+	}
+	h.perturbed = true
+	perturbed := func(q *big.Int, in, out []*big.Int) error {
+		if err := f(q, in, out); err != nil {
+			return err
+		}
+		h.perturbation.apply(out)
+		return nil
+	}
+	return p.Compiler.NewHint(perturbed, nbOutputs, inputs...)
+}
+
+type hintPerturbation struct {
+	name  string
+	apply func(out []*big.Int)
+}
+
+func hintPerturbations(out []*big.Int) []hintPerturbation {
+	var ret []hintPerturbation
+	for j := range out {
+		j := j
+		ret = append(ret,
+			hintPerturbation{fmt.Sprintf("out[%d]+1", j), func(out []*big.Int) { out[j].Add(out[j], big.NewInt(1)) }},
+			hintPerturbation{fmt.Sprintf("out[%d]-1", j), func(out []*big.Int) { out[j].Sub(out[j], big.NewInt(1)) }},
+		)
+		// swapping equal outputs changes nothing
+		if j+1 < len(out) && out[j].Cmp(out[j+1]) != 0 {
+			ret = append(ret, hintPerturbation{fmt.Sprintf("out[%d], out[%d] swapped", j, j+1), func(out []*big.Int) {
+				out[j], out[j+1] = out[j+1], out[j]
+			}})
+		}
+	}
+	return ret
+}
+
+// hintName returns the name of the hint function without the path of its
+// package, except for gnark hints
+func hintName(f solver.Hint) string {
+	name := solver.GetHintName(f)
+	if strings.HasPrefix(name, "github.com/consensys/") {
+		return name
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func copyBigInts(vs []*big.Int) []*big.Int {
+	ret := make([]*big.Int, len(vs))
+	for i, v := range vs {
+		ret[i] = new(big.Int).Set(v)
+	}
+	return ret
+}
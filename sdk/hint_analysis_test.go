@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+func TestAnalyzeHints(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(50), big.NewInt(7), big.NewInt(70)}
+	in := CircuitInput{DataInput: emulatorTestInput(values)}
+
+	// the hints used by the SDK are sound
+	hints, err := AnalyzeHints(&TestHintsCircuit{}, in)
+	check(err)
+	for _, h := range hints {
+		t.Errorf("unexpected %s", h)
+	}
+
+	hints, err = AnalyzeHints(&TestHintsCircuit{underconstrained: true}, in)
+	check(err)
+	if len(hints) != 1 {
+		t.Fatalf("expected 1 underconstrained hint, got %v", hints)
+	}
+	h := hints[0]
+	t.Log(h)
+	if h.Hint != "sdk.testDoubleHint" || !h.OutputChanged || h.Perturbation != "out[0]+1" {
+		t.Errorf("unexpected %s", h)
+	}
+	if len(h.CallSites) < 2 || !strings.HasPrefix(h.CallSites[0], "sdk.(*CircuitAPI).NewHint") ||
+		!strings.HasPrefix(h.CallSites[1], "sdk.(*TestHintsCircuit).Define") {
+		t.Errorf("unexpected call sites %v", h.CallSites)
+	}
+
+	// GroupBy does not check that the group values include every value of the
+	// data stream, so a group can be left out of the result
+	hints, err = AnalyzeHints(&TestHintsCircuit{groupBy: true}, in)
+	check(err)
+	if len(hints) != 1 || hints[0].Hint != "sdk.GroupValuesHint" || !hints[0].OutputChanged {
+		t.Fatalf("expected GroupValuesHint to be underconstrained, got %v", hints)
+	}
+	t.Log(hints[0])
+
+	// every call of a site is analyzed, the double of the last value only is
+	// not checked
+	hints, err = AnalyzeHints(&TestHintsCircuit{lastUnchecked: true}, in)
+	check(err)
+	if len(hints) != 1 || hints[0].Hint != "sdk.testDoubleHint" ||
+		strings.Join(hints[0].Contexts, " > ") != "Map on receipt #3" {
+		t.Fatalf("expected the double of receipt #3 to be underconstrained, got %v", hints)
+	}
+}
+
+func TestSolvePerturbed(t *testing.T) {
+	values := []*big.Int{big.NewInt(5), big.NewInt(50), big.NewInt(7), big.NewInt(70)}
+	data := emulatorTestInput(values)
+	plusOne := hintPerturbation{"out[0]+1", func(out []*big.Int) { out[0].Add(out[0], big.NewInt(1)) }}
+	c := &TestHintsCircuit{lastUnchecked: true}
+	for nth := 0; nth < 3; nth++ {
+		if err := solvePerturbed(c, data, "sdk.testDoubleHint", nth, plusOne); err == nil {
+			t.Errorf("expected the solver to reject the perturbed call #%d", nth)
+		}
+	}
+	check(solvePerturbed(c, data, "sdk.testDoubleHint", 3, plusOne))
+	if err := solvePerturbed(c, data, "sdk.testDoubleHint", 4, plusOne); err == nil {
+		t.Error("expected an error on a call that is not made")
+	}
+}
+
+func testDoubleHint(_ *big.Int, in, out []*big.Int) error {
+	out[0].Lsh(in[0], 1)
+	return nil
+}
+
+func init() {
+	solver.RegisterHint(testDoubleHint)
+}
+
+type TestHintsCircuit struct {
+	groupBy          bool
+	underconstrained bool
+	lastUnchecked    bool
+}
+
+func (c *TestHintsCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 4, 0, 0
+}
+
+func (c *TestHintsCircuit) Define(api *CircuitAPI, in DataInput) error {
+	receipts := NewDataStream(api, in.Receipts)
+	values := Map(receipts, func(r Receipt) Uint248 {
+		q, _ := api.Uint248.Div(api.ToUint248(r.Fields[0].Value), newU248(3))
+		return api.Uint248.Sqrt(q)
+	})
+	api.OutputUint(248, Median(values))
+	api.OutputUint(248, Count(Filter(values, func(v Uint248) Uint248 {
+		return api.ToUint248(api.Uint64.IsLessThan(newU64(v.Val), newU64(3)))
+	})))
+	if c.groupBy {
+		grouped, err := GroupBy(receipts, func(acc Uint248, r Receipt) Uint248 {
+			return api.Uint248.Add(acc, api.ToUint248(r.Fields[0].Value))
+		}, newU248(0), func(r Receipt) Uint248 { return r.Fields[0].Contract }, 2)
+		if err != nil {
+			return err
+		}
+		api.OutputUint(248, Sum(grouped))
+	}
+
+	if c.underconstrained {
+		// only checks that the double is at least the value
+		first := GetUnderlying(values, 0)
+		out, err := api.NewHint(testDoubleHint, 1, first.Val)
+		if err != nil {
+			return err
+		}
+		double := newU248(out[0])
+		api.Uint248.AssertIsLessOrEqual(first, double)
+		api.OutputUint(248, double)
+	}
+
+	if c.lastUnchecked {
+		doubles := Map(receipts, func(r Receipt) Uint248 {
+			v := api.ToUint248(r.Fields[0].Value)
+			out, err := api.NewHint(testDoubleHint, 1, v.Val)
+			check(err)
+			double := newU248(out[0])
+			isLast := api.Uint248.IsGreaterThan(v, newU248(60))
+			api.Uint248.AssertIsEqual(Select(api, isLast, double, api.Uint248.Add(v, v)), double)
+			return double
+		})
+		api.OutputUint(248, Sum(doubles))
+	}
+	return nil
+}
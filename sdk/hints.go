@@ -20,7 +20,7 @@ func registerHints() {
 }
 
 func GetHints() []solver.Hint {
-	return []solver.Hint{QuoRemHint, SqrtHint, SortHint, GroupValuesHint, CmpHint}
+	return []solver.Hint{QuoRemHint, SqrtHint, SortHint, GroupValuesHint, CmpHint, CompactHint}
 }
 
 func QuoRemHint(_ *big.Int, in, out []*big.Int) error {
@@ -30,6 +30,9 @@ func QuoRemHint(_ *big.Int, in, out []*big.Int) error {
 	if len(out) != 2 {
 		return fmt.Errorf("QuoRemHint: output len must be 2")
 	}
+	if in[1].Sign() == 0 {
+		return fmt.Errorf("QuoRemHint: division by zero")
+	}
 	out[0] = new(big.Int)
 	out[1] = new(big.Int)
 	out[0].QuoRem(in[0], in[1], out[1])
@@ -62,3 +65,24 @@ func SortHint(_ *big.Int, in, out []*big.Int) error {
 	copy(out, l)
 	return nil
 }
+
+// CompactHint takes n toggles followed by n values and moves the values with a
+// non-zero toggle to the front, keeping their order. The rest of the output is
+// filled with zeros
+func CompactHint(_ *big.Int, in, out []*big.Int) error {
+	if len(in) != 2*len(out) {
+		return fmt.Errorf("CompactHint: input len must be 2 * output len")
+	}
+	toggles, values := in[:len(out)], in[len(out):]
+	j := 0
+	for i := range values {
+		if toggles[i].Sign() != 0 {
+			out[j] = new(big.Int).Set(values[i])
+			j++
+		}
+	}
+	for ; j < len(out); j++ {
+		out[j] = new(big.Int)
+	}
+	return nil
+}
@@ -19,7 +19,8 @@ type AppCircuit interface {
 }
 
 type HostCircuit struct {
-	api frontend.API
+	api    frontend.API
+	tracer *debugTracer
 
 	Input CircuitInput
 	Guest AppCircuit
@@ -35,14 +36,20 @@ func DefaultHostCircuit(app AppCircuit) *HostCircuit {
 	return h
 }
 
+// NewHostCircuit returns the host circuit of the guest and the input. If the
+// guest has allocation tiers, the tier the input is built for is used, see
+// TierOf.
 func NewHostCircuit(in CircuitInput, guest AppCircuit) *HostCircuit {
 	return &HostCircuit{
 		Input: in,
-		Guest: guest,
+		Guest: TierOf(guest, in),
 	}
 }
 
 func (c *HostCircuit) Define(gapi frontend.API) error {
+	if c.tracer != nil {
+		gapi = &debugAPI{API: gapi, tracer: c.tracer}
+	}
 	c.api = gapi
 	api := NewCircuitAPI(gapi)
 	err := c.commitInput()
@@ -53,6 +60,12 @@ func (c *HostCircuit) Define(gapi frontend.API) error {
 	if err != nil {
 		return fmt.Errorf("error building user-defined circuit %s", err.Error())
 	}
+	if guest, ok := untiered(c.Guest).(AppCircuitWithOutputSchema); ok {
+		err = guest.OutputSchema().checkOutputs(api.outputTypes)
+		if err != nil {
+			return fmt.Errorf("circuit outputs do not match the output schema: %s", err.Error())
+		}
+	}
 
 	toggles := c.Input.Toggles()
 	if len(c.Input.InputCommitments) != len(toggles) {
@@ -70,7 +83,12 @@ func (c *HostCircuit) Define(gapi frontend.API) error {
 		return fmt.Errorf("error building user-defined circuit calMerkleRoot fail, %s", err.Error())
 	}
 	gapi.AssertIsEqual(inputCommitmentRoot, c.Input.InputCommitmentsRoot)
-	outputCommit := c.commitOutput(api.output)
+	var outputCommit OutputCommitment
+	if api.hasOutputList {
+		outputCommit = c.commitCompactedOutput(api.output, api.outputToggles)
+	} else {
+		outputCommit = c.commitOutput(api.output)
+	}
 	dryRunOutputCommit = outputCommit
 	gapi.AssertIsEqual(outputCommit[0], c.Input.OutputCommitment[0])
 	gapi.AssertIsEqual(outputCommit[1], c.Input.OutputCommitment[1])
@@ -146,7 +164,9 @@ func (c *HostCircuit) commitInput() error {
 
 	// adding constraint for input commitments (both effective commitments and dummies)
 	for i := 0; i < c.dataLen(); i++ {
+		done := c.tracer.trace("input commitment of %s", c.dataPointName(i))
 		c.api.AssertIsEqual(c.Input.InputCommitments[i], inputCommits[i])
+		done()
 	}
 
 	toggles := c.Input.Toggles()
@@ -210,6 +230,20 @@ func assertInputUniqueness(api frontend.API, in []frontend.Variable, shouldCheck
 	}, in...)
 }
 
+// dataPointName names the i-th data point across receipts, storage slots and
+// transactions, e.g. "storage slot #2"
+func (c *HostCircuit) dataPointName(i int) string {
+	d := c.Input
+	if i < len(d.Receipts.Raw) {
+		return fmt.Sprintf("receipt #%d", i)
+	}
+	i -= len(d.Receipts.Raw)
+	if i < len(d.StorageSlots.Raw) {
+		return fmt.Sprintf("storage slot #%d", i)
+	}
+	return fmt.Sprintf("transaction #%d", i-len(d.StorageSlots.Raw))
+}
+
 func (c *HostCircuit) dataLen() int {
 	d := c.Input
 	return len(d.Receipts.Raw) + len(d.StorageSlots.Raw) + len(d.Transactions.Raw)
@@ -273,6 +307,103 @@ func (c *HostCircuit) commitOutput(bits []frontend.Variable) OutputCommitment {
 	return commit
 }
 
+// commitCompactedOutput commits the user's output using Keccak256 after
+// removing the bytes whose toggle is 0. It is used when the output contains
+// OutputLists, so the commitment is over only the real records.
+//
+// The compaction is computed by CompactHint and proven with a grand product
+// check over (position, byte) pairs: each toggled on input byte at position p
+// (the number of toggled on bytes before it) must equal the compacted byte at p.
+// The keccak padding is then placed right after the compacted data, and the
+// hash of the round that contains the padding end is selected.
+func (c *HostCircuit) commitCompactedOutput(bits, toggles []frontend.Variable) OutputCommitment {
+	if len(bits) != len(toggles)*8 {
+		panic(fmt.Errorf("len bits (%d) must be 8 * len toggles (%d)", len(bits), len(toggles)))
+	}
+	g := c.api
+	n := len(toggles)
+	in := make([]frontend.Variable, n)
+	positions := make([]frontend.Variable, n)
+	var count frontend.Variable = 0
+	for i := range in {
+		// bits are little-endian in every byte
+		in[i] = g.FromBinary(bits[i*8 : i*8+8]...)
+		positions[i] = count
+		count = g.Add(count, toggles[i])
+	}
+	compacted, err := g.Compiler().NewHint(CompactHint, n, append(append([]frontend.Variable{}, toggles...), in...)...)
+	if err != nil {
+		panic(fmt.Errorf("failed to initialize CompactHint instance: %s", err.Error()))
+	}
+
+	rounds := n/136 + 1
+	paddedLen := rounds * 136
+	// isData[k] = k < count, isPadStart[k] = k == count
+	isData := make([]frontend.Variable, n)
+	isPadStart := make([]frontend.Variable, paddedLen)
+	var ended frontend.Variable = 0
+	for k := 0; k < paddedLen; k++ {
+		if k > n {
+			isPadStart[k] = 0
+			continue
+		}
+		isPadStart[k] = g.IsZero(g.Sub(count, k))
+		ended = g.Add(ended, isPadStart[k])
+		if k < n {
+			isData[k] = g.Sub(1, ended)
+		}
+	}
+
+	committed := append(append(append([]frontend.Variable{}, toggles...), in...), compacted...)
+	multicommit.WithCommitment(g, func(api frontend.API, gamma frontend.Variable) error {
+		// a pair (p, v) is encoded as ɣ² - ɣv - p
+		// Π_{i, toggles[i]=1} (ɣ² - ɣ*in[i] - positions[i]) = Π_{k < count} (ɣ² - ɣ*compacted[k] - k)
+		gamma2 := api.Mul(gamma, gamma)
+		var lhs, rhs frontend.Variable = 1, 1
+		for i := range in {
+			term := api.Sub(gamma2, api.Mul(gamma, in[i]), positions[i], 1)
+			lhs = api.Mul(lhs, api.Add(api.Mul(toggles[i], term), 1))
+			term = api.Sub(gamma2, api.Mul(gamma, compacted[i]), i, 1)
+			rhs = api.Mul(rhs, api.Add(api.Mul(isData[i], term), 1))
+		}
+		api.AssertIsEqual(lhs, rhs)
+		return nil
+	}, committed...)
+
+	// pad 101 after the compacted data. the last byte of the round that contains
+	// the padding start gets the end bit
+	var roundIndex frontend.Variable = 0
+	padded := make([]frontend.Variable, 0, paddedLen*8)
+	for r := 0; r < rounds; r++ {
+		var inRound frontend.Variable = 0
+		for k := r * 136; k < (r+1)*136; k++ {
+			inRound = g.Add(inRound, isPadStart[k])
+		}
+		roundIndex = g.Add(roundIndex, g.Mul(inRound, r))
+		for k := r * 136; k < (r+1)*136; k++ {
+			var b frontend.Variable = isPadStart[k]
+			if k < n {
+				b = g.Add(b, g.Mul(isData[k], compacted[k]))
+			}
+			if k == (r+1)*136-1 {
+				b = g.Add(b, g.Mul(inRound, 128))
+			}
+			// little-endian bits in every byte
+			padded = append(padded, g.ToBinary(b, 8)...)
+		}
+	}
+
+	fmt.Printf("commit compacted output: rounds %d, max data len %d, padded len %d\n",
+		rounds, len(bits), len(padded))
+
+	hashBits := keccak.Keccak256Bits(c.api, rounds, roundIndex, padded)
+	bitsLE := utils.FlipByGroups(hashBits[:], 8)
+	return OutputCommitment{
+		c.api.FromBinary(bitsLE[128:]...),
+		c.api.FromBinary(bitsLE[:128]...),
+	}
+}
+
 func bits2Bytes(data []frontend.Variable) []byte {
 	if len(data)%8 != 0 {
 		panic("data size must be multiple of 8")
@@ -297,23 +428,25 @@ func bits2Bytes(data []frontend.Variable) []byte {
 // be careful to use it with lock.
 var dryRunOutput []byte
 var dryRunOutputCommit OutputCommitment
+var dryRunOutputTrees []*OutputMerkleTree
 var dryRunLock sync.Mutex
 
-func dryRun(in CircuitInput, guest AppCircuit) (OutputCommitment, []byte, error) {
+func dryRun(in CircuitInput, guest AppCircuit) (OutputCommitment, []byte, []*OutputMerkleTree, error) {
 	dryRunLock.Lock()
 	defer dryRunLock.Unlock()
 	// resetting state
 	dryRunOutputCommit = OutputCommitment{nil, nil}
 	dryRunOutput = nil
+	dryRunOutputTrees = nil
 
-	circuit := &HostCircuit{Input: in, Guest: guest}
-	assignment := &HostCircuit{Input: in, Guest: guest}
+	circuit := NewHostCircuit(in, guest)
+	assignment := NewHostCircuit(in, guest)
 
 	err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
 	if err != nil {
 		// if dry out == 0 after dry run, means the run failed
 		if dryRunOutputCommit[0] == nil && dryRunOutputCommit[1] == nil {
-			return dryRunOutputCommit, nil, fmt.Errorf("dry run failed: %s", err.Error())
+			return dryRunOutputCommit, nil, nil, fmt.Errorf("dry run failed: %s", err.Error())
 		}
 	}
 
@@ -324,7 +457,10 @@ func dryRun(in CircuitInput, guest AppCircuit) (OutputCommitment, []byte, error)
 	commit := OutputCommitment{}
 	copy(commit[:], dryRunOutputCommit[:])
 
-	return commit, out, nil
+	trees := make([]*OutputMerkleTree, len(dryRunOutputTrees))
+	copy(trees, dryRunOutputTrees)
+
+	return commit, out, trees, nil
 }
 
 // return merkle root hash,
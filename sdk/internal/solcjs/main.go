@@ -0,0 +1,259 @@
+// Command solcjs compiles Solidity contracts into Hardhat artifacts with a
+// pinned solc, to generate the contract fixtures of the Go tests. It runs the
+// soljson (emscripten) build of solc with node, taken from a Go module so that
+// it is fetched and checksummed by the Go module proxy like any dependency.
+//
+// Usage:
+//
+//	go run ./sdk/internal/solcjs [flags] -root dir -o outDir file.sol...
+//
+// The files and their relative imports are resolved in the root dir. Other
+// imports, e.g. @openzeppelin/contracts/access/Ownable.sol, are resolved with
+// the -remap flags. An artifact outDir/<contract>.json, with the abi and the
+// bytecode, is written for each contract of the files that has bytecode.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// the solc build is soljson 0.8.21 embedded in the module
+const (
+	solcModule   = "github.com/rxtech-lab/solc-go@v0.1.2"
+	soljsonFile  = "embedded-binaries/soljson-v0.8.21+commit.d9974bed.js"
+	solcVersion  = "0.8.21+commit.d9974bed"
+	artifactType = "hh-sol-artifact-1"
+)
+
+// compileJS runs the standard JSON compiler interface of soljson on stdin
+const compileJS = `const fs = require('fs');
+const soljson = require(process.argv[2]);
+const compile = soljson.cwrap('solidity_compile', 'string', ['string', 'number', 'number']);
+process.stdout.write(compile(fs.readFileSync(0, 'utf8'), 0, 0));
+`
+
+var importRegexp = regexp.MustCompile(`import\s+(?:[^;]*?\bfrom\s+)?["']([^"']+)["']\s*;`)
+
+type remaps map[string]string
+
+func (r remaps) String() string {
+	return fmt.Sprint(map[string]string(r))
+}
+
+func (r remaps) Set(v string) error {
+	prefix, dir, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("remap %s is not prefix=dir", v)
+	}
+	r[prefix] = dir
+	return nil
+}
+
+type source struct {
+	Content string `json:"content"`
+}
+
+type artifact struct {
+	Format       string          `json:"_format"`
+	ContractName string          `json:"contractName"`
+	SourceName   string          `json:"sourceName"`
+	ABI          json.RawMessage `json:"abi"`
+	Bytecode     string          `json:"bytecode"`
+}
+
+func main() {
+	root := flag.String("root", ".", "the dir the files and their relative imports are resolved in")
+	outDir := flag.String("o", ".", "the dir to write the artifacts to")
+	runs := flag.Int("runs", 200, "the runs of the optimizer")
+	viaIR := flag.Bool("via-ir", false, "compile through the IR pipeline")
+	remap := remaps{}
+	flag.Var(remap, "remap", "prefix=dir to resolve the imports that start with prefix in dir instead of the root dir, can be repeated")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "no files to compile")
+		os.Exit(2)
+	}
+	if err := run(*root, *outDir, *runs, *viaIR, remap, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(root, outDir string, runs int, viaIR bool, remap remaps, files []string) error {
+	sources := map[string]source{}
+	for _, f := range files {
+		if err := addSource(root, remap, path.Clean(filepath.ToSlash(f)), sources); err != nil {
+			return err
+		}
+	}
+	input := map[string]interface{}{
+		"language": "Solidity",
+		"sources":  sources,
+		"settings": map[string]interface{}{
+			"optimizer":       map[string]interface{}{"enabled": true, "runs": runs},
+			"viaIR":           viaIR,
+			"outputSelection": map[string]interface{}{"*": map[string]interface{}{"*": []string{"abi", "evm.bytecode.object"}}},
+		},
+	}
+	output, err := compile(input)
+	if err != nil {
+		return err
+	}
+
+	var compiled struct {
+		Errors []struct {
+			Severity         string `json:"severity"`
+			FormattedMessage string `json:"formattedMessage"`
+		} `json:"errors"`
+		Contracts map[string]map[string]struct {
+			ABI json.RawMessage `json:"abi"`
+			EVM struct {
+				Bytecode struct {
+					Object string `json:"object"`
+				} `json:"bytecode"`
+			} `json:"evm"`
+		} `json:"contracts"`
+	}
+	if err = json.Unmarshal(output, &compiled); err != nil {
+		return fmt.Errorf("invalid solc output: %s", err.Error())
+	}
+	var errs []string
+	for _, e := range compiled.Errors {
+		if e.Severity == "error" {
+			errs = append(errs, e.FormattedMessage)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to compile:\n%s", strings.Join(errs, "\n"))
+	}
+
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		unit := path.Clean(filepath.ToSlash(f))
+		contracts := compiled.Contracts[unit]
+		names := make([]string, 0, len(contracts))
+		for name := range contracts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c := contracts[name]
+			if c.EVM.Bytecode.Object == "" {
+				continue
+			}
+			b, err := json.MarshalIndent(artifact{
+				Format:       artifactType,
+				ContractName: name,
+				SourceName:   unit,
+				ABI:          c.ABI,
+				Bytecode:     "0x" + c.EVM.Bytecode.Object,
+			}, "", "  ")
+			if err != nil {
+				return err
+			}
+			p := filepath.Join(outDir, name+".json")
+			if err = os.WriteFile(p, append(b, '\n'), 0644); err != nil {
+				return err
+			}
+			fmt.Printf("%s compiled with solc %s to %s\n", name, solcVersion, p)
+		}
+	}
+	return nil
+}
+
+// addSource reads the source unit and its imports into sources
+func addSource(root string, remap remaps, unit string, sources map[string]source) error {
+	if _, ok := sources[unit]; ok {
+		return nil
+	}
+	file := filepath.Join(root, filepath.FromSlash(unit))
+	for prefix, dir := range remap {
+		if strings.HasPrefix(unit, prefix) {
+			file = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(unit, prefix)))
+			break
+		}
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %s", unit, err.Error())
+	}
+	sources[unit] = source{Content: string(b)}
+	for _, m := range importRegexp.FindAllStringSubmatch(string(b), -1) {
+		imported := m[1]
+		if strings.HasPrefix(imported, "./") || strings.HasPrefix(imported, "../") {
+			imported = path.Join(path.Dir(unit), imported)
+		}
+		if err = addSource(root, remap, imported, sources); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compile runs soljson with node on the standard JSON input
+func compile(input interface{}) ([]byte, error) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		return nil, fmt.Errorf("node is required to run solc: %s", err.Error())
+	}
+	soljson, err := soljsonPath()
+	if err != nil {
+		return nil, err
+	}
+	script, err := os.CreateTemp("", "solcjs-*.js")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(script.Name())
+	if _, err = script.WriteString(compileJS); err != nil {
+		return nil, err
+	}
+	if err = script.Close(); err != nil {
+		return nil, err
+	}
+	in, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(node, script.Name(), soljson)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run solc: %s", err.Error())
+	}
+	return out, nil
+}
+
+// soljsonPath downloads the module of soljson into the module cache and returns
+// the path of soljson in it
+func soljsonPath() (string, error) {
+	out, err := exec.Command("go", "mod", "download", "-json", solcModule).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %s", solcModule, err.Error())
+	}
+	var m struct {
+		Dir   string
+		Error string
+	}
+	if err = json.Unmarshal(out, &m); err != nil {
+		return "", err
+	}
+	if m.Error != "" {
+		return "", fmt.Errorf("failed to download %s: %s", solcModule, m.Error)
+	}
+	return filepath.Join(m.Dir, soljsonFile), nil
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OutputList adds a variable-length list of records to the output. `encode` is
+// called on every element of the data stream and should add the fields of the
+// record using the OutputXXX APIs. Every record must consist of the same output
+// types. Only the records of the toggled on elements are included in the
+// output. The records are prefixed with the number of records as a uint32, so
+// the output of a list looks like
+//
+//	abi.encodePacked(uint32(count), record0..., record1..., ...)
+//
+// Example:
+//
+//	sdk.OutputList(trades, func(cur Trade) {
+//		api.OutputAddress(cur.User)
+//		api.OutputUint(248, cur.Amount)
+//	})
+//
+// Circuits with output lists commit to the output with a compacted encoding,
+// which costs extra constraints on top of the output commitment of circuits
+// without lists.
+func OutputList[T CircuitVariable](ds *DataStream[T], encode func(cur T)) {
+	api := ds.api
+	if api.outputToggle != nil {
+		panic("OutputList cannot be nested")
+	}
+	if len(ds.underlying) == 0 {
+		panic("OutputList requires a data stream with at least one element")
+	}
+	g := api.g
+	var count variable = 0
+	for _, t := range ds.toggles {
+		g.AssertIsBoolean(t)
+		count = g.Add(count, t)
+	}
+	api.addOutput(g.ToBinary(count, 32), "uint32")
+	typesStart := len(api.outputTypes)
+
+	var recordTypes []string
+	var recordSize int
+	for i, v := range ds.underlying {
+		start := len(api.output)
+		api.outputToggle = ds.toggles[i]
+		encode(v)
+		api.outputToggle = nil
+
+		types := append([]string{}, api.outputTypes[typesStart:]...)
+		size := len(api.output) - start
+		api.outputTypes = api.outputTypes[:typesStart]
+		if i == 0 {
+			if size == 0 {
+				panic("OutputList record must not be empty")
+			}
+			recordTypes, recordSize = types, size
+			continue
+		}
+		if size != recordSize || strings.Join(types, ",") != strings.Join(recordTypes, ",") {
+			panic(fmt.Sprintf("OutputList record #%d (%s) does not match record #0 (%s)",
+				i, strings.Join(types, ","), strings.Join(recordTypes, ",")))
+		}
+	}
+	// the count and the records are recorded as a single list type for checking
+	// against the output schema
+	api.outputTypes[typesStart-1] = listType(recordTypes)
+	api.hasOutputList = true
+}
+
+func listType(recordTypes []string) string {
+	return "list(" + strings.Join(recordTypes, ",") + ")"
+}
+
+// parseListType returns the record types of a type produced by listType
+func parseListType(typ string) ([]string, bool) {
+	inner, ok := strings.CutPrefix(typ, "list(")
+	if !ok {
+		return nil, false
+	}
+	inner, ok = strings.CutSuffix(inner, ")")
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(inner, ","), true
+}
@@ -0,0 +1,177 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testOutputListLen = 16
+
+func TestOutputList(t *testing.T) {
+	cases := map[string]func(i int) bool{
+		"none": func(i int) bool { return false },
+		"some": func(i int) bool { return i%3 != 1 },
+		"all":  func(i int) bool { return true },
+	}
+	for name, enabled := range cases {
+		t.Run(name, func(t *testing.T) {
+			toggles := make([]frontend.Variable, testOutputListLen)
+			expected := binary.BigEndian.AppendUint64(nil, 7)
+			count := 0
+			for i := range toggles {
+				toggles[i] = 0
+				if enabled(i) {
+					toggles[i] = 1
+					count++
+				}
+			}
+			expected = binary.BigEndian.AppendUint32(expected, uint32(count))
+			for i := range toggles {
+				if enabled(i) {
+					expected = append(expected, testOutputListUser(i)...)
+					expected = append(expected, byte(i%2))
+				}
+			}
+			expected = append(expected, make([]byte, 31)...)
+			expected = append(expected, 0xff)
+
+			h := crypto.Keccak256(expected)
+			c := &TestOutputListCircuit{
+				Toggles: toggles,
+				Commit:  [2]frontend.Variable{new(big.Int).SetBytes(h[:16]), new(big.Int).SetBytes(h[16:])},
+				All:     name == "all",
+			}
+
+			dryRunLock.Lock()
+			defer dryRunLock.Unlock()
+			dryRunOutput = nil
+			err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+			check(err)
+			if !bytes.Equal(dryRunOutput, expected) {
+				t.Errorf("expected dry run output %x, got %x", expected, dryRunOutput)
+			}
+		})
+	}
+}
+
+func TestOutputListSchema(t *testing.T) {
+	schema := OutputSchema{
+		{Name: "total", Type: "uint64"},
+		{Name: "users", Type: "list", Fields: []OutputField{
+			{Name: "user", Type: "address"},
+			{Name: "active", Type: "bool"},
+		}},
+		{Name: "salt", Type: "uint256"},
+	}
+	c := &TestOutputListTypesCircuit{Schema: schema}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+
+	schema[1].Fields[1].Type = "uint8"
+	err = test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil || !strings.Contains(err.Error(), "declared as list(address,uint8)") {
+		t.Errorf("expected list mismatch error, got %v", err)
+	}
+
+	schema[1].Fields[1] = OutputField{Name: "nested", Type: "list"}
+	if err = schema.Validate(); err == nil {
+		t.Error("expected error on nested list")
+	}
+
+	schema[1].Fields[1] = OutputField{Name: "active", Type: "bool"}
+	code, err := GenerateSolidityDecoder("UsersOutput", schema)
+	check(err)
+	expected := []string{
+		"struct UsersItem {",
+		"UsersItem[] users;",
+		"out.total = uint64(bytes8(o[0:8]));",
+		"uint256 p = 8;",
+		"n = uint32(bytes4(o[p:p + 4]));",
+		"out.users = new UsersItem[](n);",
+		"out.users[i].user = address(bytes20(o[p:p + 20]));",
+		"out.users[i].active = o[p + 20] != 0;",
+		"p += 21;",
+		"out.salt = uint256(bytes32(o[p:p + 32]));",
+		"require(o.length == p, \"invalid output length\");",
+	}
+	for _, line := range expected {
+		if !strings.Contains(code, line) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", line, code)
+		}
+	}
+}
+
+func testOutputListUser(i int) []byte {
+	b := make([]byte, 20)
+	b[19] = byte(i + 1)
+	b[0] = 0xaa
+	return b
+}
+
+type TestOutputListCircuit struct {
+	Toggles []frontend.Variable
+	Commit  [2]frontend.Variable
+	All     bool `gnark:"-"`
+}
+
+func (c *TestOutputListCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	users := make([]Uint248, len(c.Toggles))
+	for i := range users {
+		users[i] = newU248(new(big.Int).SetBytes(testOutputListUser(i)))
+	}
+	ds := NewDataStream(api, DataPoints[Uint248]{Raw: users, Toggles: c.Toggles})
+
+	api.OutputUint64(64, newU64(7))
+	i := 0
+	OutputList(ds, func(cur Uint248) {
+		api.OutputAddress(cur)
+		api.OutputBool(newU248(i % 2))
+		i++
+	})
+	api.OutputBytes32(ConstFromBigEndianBytes([]byte{0xff}))
+
+	h := &HostCircuit{api: g}
+	commit := h.commitCompactedOutput(api.output, api.outputToggles)
+	g.AssertIsEqual(commit[0], c.Commit[0])
+	g.AssertIsEqual(commit[1], c.Commit[1])
+	if c.All {
+		// without any disabled records, the compacted commitment is the same as
+		// the commitment of the full output
+		full := h.commitOutput(api.output)
+		g.AssertIsEqual(full[0], c.Commit[0])
+		g.AssertIsEqual(full[1], c.Commit[1])
+	}
+	return nil
+}
+
+type TestOutputListTypesCircuit struct {
+	Schema OutputSchema `gnark:"-"`
+}
+
+func (c *TestOutputListTypesCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	ds := NewDataStream(api, DataPoints[Uint248]{
+		Raw:     newU248s(1, 2, 3),
+		Toggles: []frontend.Variable{1, 0, 1},
+	})
+	api.OutputUint64(64, newU64(7))
+	OutputList(ds, func(cur Uint248) {
+		api.OutputAddress(cur)
+		api.OutputBool(newU248(1))
+	})
+	api.OutputBytes32(ConstFromBigEndianBytes([]byte{0xff}))
+	if !api.hasOutputList {
+		return fmt.Errorf("expected hasOutputList to be set")
+	}
+	return c.Schema.checkOutputs(api.outputTypes)
+}
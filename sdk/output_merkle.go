@@ -0,0 +1,233 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// OutputMerkleRoot builds a keccak256 Merkle tree over (key, value) leaves
+// computed from each element of the data stream, and adds the root to the
+// output as a bytes32. This allows a circuit to commit to a large result set
+// (e.g. rewards for thousands of users) while only delivering 32 bytes to the
+// app contract. The contract stores the root, and users can later claim their
+// values with Merkle proofs.
+//
+// The tree has the size of the data stream rounded up to the next power of two.
+// The leaf at index i is
+//
+//	keccak256(abi.encodePacked(uint8(0x00), key, value))
+//
+// if the i-th element is toggled on, or bytes32(0) otherwise. The parent of
+// two nodes is keccak256(abi.encodePacked(uint8(0x01), left, right)). The
+// prefixes separate leaves from internal nodes, so that an internal node cannot
+// be passed off as a leaf with a shorter proof.
+//
+// When the circuit is dry-run in BrevisApp.BuildCircuitInput, the full tree is
+// computed and can be saved along with a proof for every leaf using
+// CircuitInput.WriteOutputMerkleTrees.
+//
+// The tree is hashed with keccak256 in circuit so that the contract can verify
+// the proofs cheaply, which is expensive: every element costs about 577k PLONK
+// constraints, a leaf hash and an internal node hash, and the padding up to the
+// tree size costs another 288k per padded leaf. The data stream can therefore
+// have at most MaxOutputMerkleLeaves elements.
+func OutputMerkleRoot[T CircuitVariable](ds *DataStream[T], leaf func(cur T) (key, value Bytes32)) {
+	api := ds.api
+	if len(ds.underlying) == 0 {
+		panic("OutputMerkleRoot requires a data stream with at least one element")
+	}
+	if len(ds.underlying) > MaxOutputMerkleLeaves {
+		panic(fmt.Errorf("OutputMerkleRoot supports at most %d leaves, but the data stream has %d elements: "+
+			"the keccak256 tree costs about 577k constraints per leaf", MaxOutputMerkleLeaves, len(ds.underlying)))
+	}
+	size := 1
+	for size < len(ds.underlying) {
+		size *= 2
+	}
+	zero := ConstFromBigEndianBytes(nil)
+	leafPrefix := ConstFromBigEndianBytes([]byte{outputMerkleLeafPrefix})
+	nodePrefix := ConstFromBigEndianBytes([]byte{outputMerkleNodePrefix})
+	nodes := make([]Bytes32, size)
+	keys := make([]Bytes32, len(ds.underlying))
+	values := make([]Bytes32, len(ds.underlying))
+	for i := range nodes {
+		if i >= len(ds.underlying) {
+			nodes[i] = zero
+			continue
+		}
+		keys[i], values[i] = leaf(ds.underlying[i])
+		hash := api.Keccak256([]Bytes32{leafPrefix, keys[i], values[i]}, []int32{8, 256, 256})
+		nodes[i] = api.Bytes32.Select(newU248(ds.toggles[i]), hash, zero)
+	}
+	for len(nodes) > 1 {
+		parents := make([]Bytes32, len(nodes)/2)
+		for i := range parents {
+			parents[i] = api.Keccak256([]Bytes32{nodePrefix, nodes[2*i], nodes[2*i+1]}, []int32{8, 256, 256})
+		}
+		nodes = parents
+	}
+	root := nodes[0]
+	api.OutputBytes32(root)
+
+	if !frontend.IsCanonical(root.Val[0]) /*only record the tree when dryRun*/ {
+		var leaves []OutputMerkleLeaf
+		for i := range ds.underlying {
+			if fromInterface(ds.toggles[i]).Sign() == 0 {
+				continue
+			}
+			leaves = append(leaves, OutputMerkleLeaf{
+				Index: i,
+				Key:   common.HexToHash(keys[i].String()),
+				Value: common.HexToHash(values[i].String()),
+			})
+		}
+		dryRunOutputTrees = append(dryRunOutputTrees, NewOutputMerkleTree(size, leaves))
+	}
+}
+
+// MaxOutputMerkleLeaves is the maximum number of elements of the data stream
+// of OutputMerkleRoot. The tree of 64 leaves costs about 37M constraints, the
+// next power of two would exceed the 2^26 constraints supported by the SRS.
+const MaxOutputMerkleLeaves = 64
+
+const (
+	outputMerkleLeafPrefix byte = 0x00
+	outputMerkleNodePrefix byte = 0x01
+)
+
+// OutputMerkleLeaf is a (key, value) pair at an index of an OutputMerkleTree
+type OutputMerkleLeaf struct {
+	Index int         `json:"index"`
+	Key   common.Hash `json:"key"`
+	Value common.Hash `json:"value"`
+}
+
+// Hash returns keccak256(abi.encodePacked(uint8(0x00), key, value))
+func (l OutputMerkleLeaf) Hash() common.Hash {
+	return crypto.Keccak256Hash([]byte{outputMerkleLeafPrefix}, l.Key[:], l.Value[:])
+}
+
+// outputMerkleNodeHash returns keccak256(abi.encodePacked(uint8(0x01), left, right))
+func outputMerkleNodeHash(left, right common.Hash) common.Hash {
+	return crypto.Keccak256Hash([]byte{outputMerkleNodePrefix}, left[:], right[:])
+}
+
+// OutputMerkleTree is the off-circuit counterpart of the tree built by
+// OutputMerkleRoot
+type OutputMerkleTree struct {
+	Leaves []OutputMerkleLeaf
+	// Layers[0] are the leaf hashes, the last layer only contains the root
+	Layers [][]common.Hash
+}
+
+// NewOutputMerkleTree builds a tree of the given size (must be a power of two)
+// where every index not present in leaves holds bytes32(0)
+func NewOutputMerkleTree(size int, leaves []OutputMerkleLeaf) *OutputMerkleTree {
+	if !CheckNumberPowerOfTwo(size) {
+		panic(fmt.Errorf("tree size %d is not a power of two", size))
+	}
+	layer := make([]common.Hash, size)
+	for _, l := range leaves {
+		if l.Index < 0 || l.Index >= size {
+			panic(fmt.Errorf("leaf index %d out of range [0, %d)", l.Index, size))
+		}
+		layer[l.Index] = l.Hash()
+	}
+	t := &OutputMerkleTree{Leaves: leaves, Layers: [][]common.Hash{layer}}
+	for len(layer) > 1 {
+		parents := make([]common.Hash, len(layer)/2)
+		for i := range parents {
+			parents[i] = outputMerkleNodeHash(layer[2*i], layer[2*i+1])
+		}
+		t.Layers = append(t.Layers, parents)
+		layer = parents
+	}
+	return t
+}
+
+// Root returns the Merkle root of the tree
+func (t *OutputMerkleTree) Root() common.Hash {
+	return t.Layers[len(t.Layers)-1][0]
+}
+
+// Depth returns the number of layers above the leaves, i.e. the length of the
+// proofs of the tree
+func (t *OutputMerkleTree) Depth() int {
+	return len(t.Layers) - 1
+}
+
+// Proof returns the sibling hashes from the leaf at index up to the root
+func (t *OutputMerkleTree) Proof(index int) []common.Hash {
+	var proof []common.Hash
+	for _, layer := range t.Layers[:len(t.Layers)-1] {
+		proof = append(proof, layer[index^1])
+		index /= 2
+	}
+	return proof
+}
+
+// VerifyOutputMerkleProof checks that leaf is at index of the tree with the
+// given root and depth. Proofs of any other length than depth are rejected.
+func VerifyOutputMerkleProof(root common.Hash, depth int, leaf OutputMerkleLeaf, proof []common.Hash) bool {
+	if len(proof) != depth || leaf.Index < 0 {
+		return false
+	}
+	hash, index := leaf.Hash(), leaf.Index
+	for _, sibling := range proof {
+		if index%2 == 0 {
+			hash = outputMerkleNodeHash(hash, sibling)
+		} else {
+			hash = outputMerkleNodeHash(sibling, hash)
+		}
+		index /= 2
+	}
+	return index == 0 && hash == root
+}
+
+type outputMerkleTreeJson struct {
+	Root   common.Hash     `json:"root"`
+	Depth  int             `json:"depth"`
+	Layers [][]common.Hash `json:"layers"`
+}
+
+type outputMerkleProofJson struct {
+	OutputMerkleLeaf
+	Leaf  common.Hash   `json:"leaf"`
+	Proof []common.Hash `json:"proof"`
+}
+
+// Save writes the full tree to dir/tree.json and the proofs of all leaves to
+// dir/proofs.json
+func (t *OutputMerkleTree) Save(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create dir %s: %s", dir, err.Error())
+	}
+	proofs := make([]outputMerkleProofJson, len(t.Leaves))
+	for i, l := range t.Leaves {
+		proofs[i] = outputMerkleProofJson{OutputMerkleLeaf: l, Leaf: l.Hash(), Proof: t.Proof(l.Index)}
+	}
+	files := map[string]interface{}{
+		"tree.json":   outputMerkleTreeJson{Root: t.Root(), Depth: t.Depth(), Layers: t.Layers},
+		"proofs.json": proofs,
+	}
+	for name, content := range files {
+		b, err := json.MarshalIndent(content, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %s", name, err.Error())
+		}
+		path := filepath.Join(dir, name)
+		err = os.WriteFile(path, b, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %s", path, err.Error())
+		}
+	}
+	fmt.Printf("output merkle tree with root %s saved to %s\n", t.Root(), dir)
+	return nil
+}
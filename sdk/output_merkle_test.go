@@ -0,0 +1,156 @@
+package sdk
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestOutputMerkleRoot(t *testing.T) {
+	toggles := []frontend.Variable{1, 0, 1, 1, 1}
+	var leaves []OutputMerkleLeaf
+	for i := range toggles {
+		if toggles[i] == 1 {
+			leaves = append(leaves, OutputMerkleLeaf{
+				Index: i,
+				Key:   common.BigToHash(big.NewInt(int64(0xabc0 + i))),
+				Value: common.BigToHash(big.NewInt(int64(1000 * i))),
+			})
+		}
+	}
+	expected := NewOutputMerkleTree(8, leaves)
+	if len(expected.Layers) != 4 {
+		t.Fatalf("expected 4 layers, got %d", len(expected.Layers))
+	}
+
+	c := &TestOutputMerkleRootCircuit{Toggles: toggles, Root: ConstFromBigEndianBytes(expected.Root().Bytes())}
+	dryRunLock.Lock()
+	dryRunOutputTrees = nil
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	trees := dryRunOutputTrees
+	dryRunLock.Unlock()
+	check(err)
+
+	if len(trees) != 1 || trees[0].Root() != expected.Root() || len(trees[0].Leaves) != 4 {
+		t.Fatalf("unexpected dry run trees %v", trees)
+	}
+	for _, l := range trees[0].Leaves {
+		if !VerifyOutputMerkleProof(expected.Root(), 3, l, trees[0].Proof(l.Index)) {
+			t.Errorf("failed to verify proof of leaf %d", l.Index)
+		}
+		wrongIndex := l
+		wrongIndex.Index ^= 1
+		if VerifyOutputMerkleProof(expected.Root(), 3, wrongIndex, trees[0].Proof(l.Index)) {
+			t.Errorf("proof of leaf %d verified at the wrong index", l.Index)
+		}
+	}
+
+	dir := t.TempDir()
+	in := CircuitInput{dryRunOutputTrees: trees}
+	check(in.WriteOutputMerkleTrees(dir))
+	b, err := os.ReadFile(filepath.Join(dir, "tree0", "proofs.json"))
+	check(err)
+	var proofs []outputMerkleProofJson
+	check(json.Unmarshal(b, &proofs))
+	if len(proofs) != 4 || proofs[1].Index != 2 || len(proofs[1].Proof) != 3 ||
+		!VerifyOutputMerkleProof(expected.Root(), 3, proofs[1].OutputMerkleLeaf, proofs[1].Proof) {
+		t.Errorf("unexpected proofs %+v", proofs)
+	}
+	b, err = os.ReadFile(filepath.Join(dir, "tree0", "tree.json"))
+	check(err)
+	var tree outputMerkleTreeJson
+	check(json.Unmarshal(b, &tree))
+	if tree.Root != expected.Root() || tree.Depth != 3 {
+		t.Errorf("unexpected tree root %s and depth %d", tree.Root, tree.Depth)
+	}
+
+	// the root is a part of the output
+	c.Root = ConstFromBigEndianBytes([]byte{1})
+	err = test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil {
+		t.Error("expected wrong root to fail")
+	}
+}
+
+func TestOutputMerkleRootMaxLeaves(t *testing.T) {
+	c := &TestOutputMerkleRootCircuit{Toggles: make([]frontend.Variable, MaxOutputMerkleLeaves+1)}
+	for i := range c.Toggles {
+		c.Toggles[i] = 1
+	}
+	c.Root = ConstFromBigEndianBytes(nil)
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	if err == nil || !strings.Contains(err.Error(), "OutputMerkleRoot supports at most 64 leaves") {
+		t.Errorf("expected max leaves error, got %v", err)
+	}
+}
+
+func TestOutputMerkleProofForgery(t *testing.T) {
+	var leaves []OutputMerkleLeaf
+	for i := 0; i < 4; i++ {
+		leaves = append(leaves, OutputMerkleLeaf{
+			Index: i,
+			Key:   common.BigToHash(big.NewInt(int64(i))),
+			Value: common.BigToHash(big.NewInt(int64(1000 * i))),
+		})
+	}
+	tree := NewOutputMerkleTree(4, leaves)
+	root, depth := tree.Root(), tree.Depth()
+	if !VerifyOutputMerkleProof(root, depth, leaves[2], tree.Proof(2)) {
+		t.Fatal("failed to verify proof of leaf 2")
+	}
+
+	// the parent of leaves 2 and 3 passed off as a leaf at index 1, with the
+	// proof of the parent
+	forged := OutputMerkleLeaf{Index: 1, Key: tree.Layers[0][2], Value: tree.Layers[0][3]}
+	parentProof := tree.Proof(2)[1:]
+	if VerifyOutputMerkleProof(root, depth, forged, parentProof) {
+		t.Error("proofs shorter than the depth should not verify")
+	}
+	if VerifyOutputMerkleProof(root, depth-1, forged, parentProof) {
+		t.Error("an internal node should not verify as a leaf")
+	}
+	if forged.Hash() == tree.Layers[1][1] {
+		t.Error("leaf and node hashes are not domain separated")
+	}
+
+	// proofs padded to a longer length do not verify either
+	if VerifyOutputMerkleProof(root, depth, leaves[2], append(tree.Proof(2), common.Hash{})) {
+		t.Error("proofs longer than the depth should not verify")
+	}
+	negative := leaves[1]
+	negative.Index = -1
+	if VerifyOutputMerkleProof(root, depth, negative, tree.Proof(1)) {
+		t.Error("negative indexes should not verify")
+	}
+}
+
+type TestOutputMerkleRootCircuit struct {
+	Toggles []frontend.Variable
+	Root    Bytes32
+}
+
+func (c *TestOutputMerkleRootCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	keys := make([]Uint248, len(c.Toggles))
+	for i := range keys {
+		keys[i] = newU248(0xabc0 + i)
+	}
+	ds := NewDataStream(api, DataPoints[Uint248]{Raw: keys, Toggles: c.Toggles})
+	i := 0
+	OutputMerkleRoot(ds, func(cur Uint248) (Bytes32, Bytes32) {
+		value := api.ToBytes32(newU248(1000 * i))
+		i++
+		return api.ToBytes32(cur), value
+	})
+	root := api.Bytes32.FromBinary(newU248s(flipByGroups(api.output, 8)...)...)
+	api.Bytes32.AssertIsEqual(root, c.Root)
+	return nil
+}
@@ -0,0 +1,371 @@
+package sdk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OutputField describes one value added through the OutputXXX APIs, or a
+// list added through OutputList
+type OutputField struct {
+	// Name is the name of the field in the generated Solidity struct. It must be
+	// a valid Solidity identifier and not a reserved word, e.g. address or
+	// return
+	Name string
+	// Type is the Solidity type of the field. Supported types are bytes32,
+	// uint256, bool, address and uint8, uint16, ..., uint248. Fields added
+	// through OutputList have the type "list"
+	Type string
+	// Fields are the fields of each record of a "list" field. Lists cannot be
+	// nested
+	Fields []OutputField
+}
+
+// OutputSchema is the ordered list of outputs a circuit produces. The
+// abi.encodePacked layout of the circuit output is fully determined by it
+type OutputSchema []OutputField
+
+// AppCircuitWithOutputSchema can be optionally implemented by an AppCircuit to
+// declare the outputs it produces. When implemented, the outputs added in
+// Define are checked against the schema when the circuit is compiled, and the
+// schema can be used to generate a Solidity decoder for the circuit output with
+// GenerateSolidityDecoder.
+//
+// Example:
+//
+//	func (c *AppCircuit) OutputSchema() sdk.OutputSchema {
+//		return sdk.OutputSchema{
+//			{Name: "salt", Type: "bytes32"},
+//			{Name: "sumVolume", Type: "uint248"},
+//			{Name: "minBlockNum", Type: "uint64"},
+//			{Name: "user", Type: "address"},
+//		}
+//	}
+type AppCircuitWithOutputSchema interface {
+	AppCircuit
+	OutputSchema() OutputSchema
+}
+
+var solidityIdentifier = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*$`)
+
+// solidityElementaryType matches the names of the elementary types, which
+// cannot be used as identifiers
+var solidityElementaryType = regexp.MustCompile(`^(address|bool|string|byte|bytes[0-9]*|u?int[0-9]*|u?fixed([0-9]+x[0-9]+)?)$`)
+
+// solidityKeywords are the keywords, the reserved keywords and the units of
+// Solidity, which cannot be used as identifiers
+var solidityKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		abstract after alias anonymous apply as assembly auto break calldata case
+		catch constant constructor continue contract copyof days default define
+		delete do else emit enum ether event external fallback false final for
+		function gwei hex hours if immutable implements import in indexed inline
+		interface internal is let library macro mapping match memory minutes
+		modifier mutable new null of override partial payable pragma private
+		promise public pure receive reference relocatable return returns sealed
+		seconds sizeof static storage struct supports switch throw true try type
+		typedef typeof unchecked unicode using var view virtual weeks wei while
+		years`) {
+		solidityKeywords[k] = true
+	}
+}
+
+// checkSolidityIdentifier checks that name can be used as a Solidity identifier
+func checkSolidityIdentifier(name string) error {
+	if !solidityIdentifier.MatchString(name) {
+		return fmt.Errorf("%q is not a valid identifier", name)
+	}
+	if solidityKeywords[name] || solidityElementaryType.MatchString(name) {
+		return fmt.Errorf("%q is a reserved word of Solidity", name)
+	}
+	return nil
+}
+
+// Validate checks that the field names are unique valid identifiers, that are
+// not reserved words of Solidity, and that all types are supported
+func (s OutputSchema) Validate() error {
+	return s.validate(true)
+}
+
+func (s OutputSchema) validate(allowLists bool) error {
+	names := make(map[string]bool)
+	for i, f := range s {
+		if err := checkSolidityIdentifier(f.Name); err != nil {
+			return fmt.Errorf("output #%d: invalid field name: %s", i, err.Error())
+		}
+		if names[f.Name] {
+			return fmt.Errorf("output #%d: duplicate field name %q", i, f.Name)
+		}
+		names[f.Name] = true
+		if f.Type == "list" {
+			if !allowLists {
+				return fmt.Errorf("output #%d (%s): lists cannot be nested", i, f.Name)
+			}
+			if len(f.Fields) == 0 {
+				return fmt.Errorf("output #%d (%s): list has no fields", i, f.Name)
+			}
+			if err := OutputSchema(f.Fields).validate(false); err != nil {
+				return fmt.Errorf("output #%d (%s): %s", i, f.Name, err.Error())
+			}
+			continue
+		}
+		if len(f.Fields) > 0 {
+			return fmt.Errorf("output #%d (%s): only lists can have fields", i, f.Name)
+		}
+		if _, err := outputTypeSize(f.Type); err != nil {
+			return fmt.Errorf("output #%d (%s): %s", i, f.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Size returns the length in bytes of the packed output described by the
+// schema. Lists are counted as empty, i.e. only their uint32 count prefix is
+// counted
+func (s OutputSchema) Size() int {
+	size := 0
+	for _, f := range s {
+		if f.Type == "list" {
+			size += 4
+			continue
+		}
+		n, err := outputTypeSize(f.Type)
+		if err != nil {
+			panic(err)
+		}
+		size += n
+	}
+	return size
+}
+
+// checkOutputs checks that the types recorded by the OutputXXX calls match the
+// schema in order and size
+func (s OutputSchema) checkOutputs(types []string) error {
+	if err := s.Validate(); err != nil {
+		return fmt.Errorf("invalid output schema: %s", err.Error())
+	}
+	for i := 0; i < len(s) || i < len(types); i++ {
+		switch {
+		case i >= len(types):
+			return fmt.Errorf("output #%d (%s %s) is declared in the output schema but never added", i, s[i].Type, s[i].Name)
+		case i >= len(s):
+			return fmt.Errorf("output #%d (%s) is added but not declared in the output schema", i, types[i])
+		case !s[i].matches(types[i]):
+			return fmt.Errorf("output #%d (%s): declared as %s in the output schema, but added as %s",
+				i, s[i].Name, s[i].typ(), types[i])
+		}
+	}
+	return nil
+}
+
+// typ returns the type of the field in the form recorded by the OutputXXX APIs
+func (f OutputField) typ() string {
+	if f.Type != "list" {
+		return f.Type
+	}
+	types := make([]string, len(f.Fields))
+	for i, field := range f.Fields {
+		types[i] = field.Type
+	}
+	return listType(types)
+}
+
+// matches returns whether an output recorded as the `added` type can be
+// decoded as this field
+func (f OutputField) matches(added string) bool {
+	if f.Type != "list" {
+		return outputTypeMatches(f.Type, added)
+	}
+	recordTypes, ok := parseListType(added)
+	if !ok || len(recordTypes) != len(f.Fields) {
+		return false
+	}
+	for i, field := range f.Fields {
+		if !outputTypeMatches(field.Type, recordTypes[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// outputTypeMatches returns whether an output added as the `added` type can be
+// decoded as the `declared` type. OutputBytes32 is used for both bytes32 and
+// uint256
+func outputTypeMatches(declared, added string) bool {
+	return declared == added || declared == "uint256" && added == "bytes32"
+}
+
+func outputTypeSize(typ string) (int, error) {
+	switch typ {
+	case "bytes32", "uint256":
+		return 32, nil
+	case "bool":
+		return 1, nil
+	case "address":
+		return 20, nil
+	}
+	if bitSize, ok := strings.CutPrefix(typ, "uint"); ok {
+		n, err := strconv.Atoi(bitSize)
+		if err == nil && n > 0 && n%8 == 0 && n <= 248 && strconv.Itoa(n) == bitSize {
+			return n / 8, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported output type %q", typ)
+}
+
+// decodeExpr returns the Solidity expression that decodes a value of typ from
+// the bytes o[start:start+size]. start is a Solidity expression
+func decodeExpr(typ string, start string, size int) string {
+	end := start + " + " + strconv.Itoa(size)
+	if n, err := strconv.Atoi(start); err == nil {
+		end = strconv.Itoa(n + size)
+	}
+	slice := fmt.Sprintf("o[%s:%s]", start, end)
+	switch typ {
+	case "bytes32":
+		return fmt.Sprintf("bytes32(%s)", slice)
+	case "uint256":
+		return fmt.Sprintf("uint256(bytes32(%s))", slice)
+	case "bool":
+		return fmt.Sprintf("o[%s] != 0", start)
+	case "address":
+		return fmt.Sprintf("address(bytes20(%s))", slice)
+	}
+	return fmt.Sprintf("%s(bytes%d(%s))", typ, size, slice)
+}
+
+// offsetExpr returns the Solidity expression of the offset `base + offset`
+func offsetExpr(base string, offset int) string {
+	if base == "" {
+		return strconv.Itoa(offset)
+	}
+	if offset == 0 {
+		return base
+	}
+	return fmt.Sprintf("%s + %d", base, offset)
+}
+
+func listItemName(f OutputField) string {
+	return strings.ToUpper(f.Name[:1]) + f.Name[1:] + "Item"
+}
+
+// GenerateSolidityDecoder generates a Solidity library named libName that
+// decodes the circuit output described by schema. The library contains an
+// `Output` struct with one member per schema field and a
+// `decodeOutput(bytes calldata)` function that checks the output length and
+// returns the decoded struct. List fields are decoded into arrays of
+// `<Name>Item` structs. The result can be used in handleProofResult:
+//
+//	MyAppOutput.Output memory out = MyAppOutput.decodeOutput(_appCircuitOutput);
+func GenerateSolidityDecoder(libName string, schema OutputSchema) (string, error) {
+	if err := checkSolidityIdentifier(libName); err != nil {
+		return "", fmt.Errorf("invalid library name: %s", err.Error())
+	}
+	if len(schema) == 0 {
+		return "", fmt.Errorf("output schema is empty")
+	}
+	if err := schema.Validate(); err != nil {
+		return "", fmt.Errorf("invalid output schema: %s", err.Error())
+	}
+	hasList := false
+	for _, f := range schema {
+		hasList = hasList || f.Type == "list"
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "// SPDX-License-Identifier: MIT\n")
+	fmt.Fprintf(b, "pragma solidity ^0.8.18;\n\n")
+	fmt.Fprintf(b, "// Code generated by brevis-sdk. DO NOT EDIT.\n")
+	fmt.Fprintf(b, "library %s {\n", libName)
+	if !hasList {
+		fmt.Fprintf(b, "    uint256 internal constant OUTPUT_LENGTH = %d;\n\n", schema.Size())
+	}
+	for _, f := range schema {
+		if f.Type != "list" {
+			continue
+		}
+		fmt.Fprintf(b, "    struct %s {\n", listItemName(f))
+		for _, field := range f.Fields {
+			fmt.Fprintf(b, "        %s %s;\n", field.Type, field.Name)
+		}
+		fmt.Fprintf(b, "    }\n\n")
+	}
+	fmt.Fprintf(b, "    struct Output {\n")
+	for _, f := range schema {
+		typ := f.Type
+		if f.Type == "list" {
+			typ = listItemName(f) + "[]"
+		}
+		fmt.Fprintf(b, "        %s %s;\n", typ, f.Name)
+	}
+	fmt.Fprintf(b, "    }\n\n")
+	fmt.Fprintf(b, "    function decodeOutput(bytes calldata o) internal pure returns (Output memory out) {\n")
+	if !hasList {
+		fmt.Fprintf(b, "        require(o.length == OUTPUT_LENGTH, \"invalid output length\");\n")
+	}
+	// offsets are static until the first list, after which they are relative
+	// to the running offset p
+	base, offset := "", 0
+	for _, f := range schema {
+		if f.Type != "list" {
+			size, _ := outputTypeSize(f.Type)
+			fmt.Fprintf(b, "        out.%s = %s;\n", f.Name, decodeExpr(f.Type, offsetExpr(base, offset), size))
+			offset += size
+			continue
+		}
+		if base == "" {
+			fmt.Fprintf(b, "        uint256 p = %d;\n", offset)
+			fmt.Fprintf(b, "        uint256 n;\n")
+			base = "p"
+		} else if offset > 0 {
+			fmt.Fprintf(b, "        p += %d;\n", offset)
+		}
+		fmt.Fprintf(b, "        n = uint32(bytes4(o[p:p + 4]));\n")
+		fmt.Fprintf(b, "        p += 4;\n")
+		fmt.Fprintf(b, "        out.%s = new %s[](n);\n", f.Name, listItemName(f))
+		fmt.Fprintf(b, "        for (uint256 i = 0; i < n; i++) {\n")
+		recordOffset := 0
+		for _, field := range f.Fields {
+			size, _ := outputTypeSize(field.Type)
+			fmt.Fprintf(b, "            out.%s[i].%s = %s;\n",
+				f.Name, field.Name, decodeExpr(field.Type, offsetExpr("p", recordOffset), size))
+			recordOffset += size
+		}
+		fmt.Fprintf(b, "            p += %d;\n", recordOffset)
+		fmt.Fprintf(b, "        }\n")
+		offset = 0
+	}
+	if hasList {
+		if offset > 0 {
+			fmt.Fprintf(b, "        p += %d;\n", offset)
+		}
+		fmt.Fprintf(b, "        require(o.length == p, \"invalid output length\");\n")
+	}
+	fmt.Fprintf(b, "    }\n")
+	fmt.Fprintf(b, "}\n")
+	return b.String(), nil
+}
+
+// WriteSolidityDecoder generates the Solidity decoder library for the
+// circuit's output schema and writes it to outDir/<libName>.sol
+func WriteSolidityDecoder(app AppCircuitWithOutputSchema, libName, outDir string) error {
+	code, err := GenerateSolidityDecoder(libName, app.OutputSchema())
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("failed to create dir %s: %s", outDir, err.Error())
+	}
+	path := filepath.Join(outDir, libName+".sol")
+	if err = os.WriteFile(path, []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %s", path, err.Error())
+	}
+	fmt.Printf("solidity output decoder written to %s\n", path)
+	return nil
+}
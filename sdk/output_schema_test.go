@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testTradingVolumeSchema = OutputSchema{
+	{Name: "salt", Type: "bytes32"},
+	{Name: "sumVolume", Type: "uint248"},
+	{Name: "minBlockNum", Type: "uint64"},
+	{Name: "user", Type: "address"},
+	{Name: "ok", Type: "bool"},
+}
+
+func TestOutputSchema(t *testing.T) {
+	c := &TestOutputSchemaCircuit{Schema: testTradingVolumeSchema}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+
+	// bytes32 outputs can be declared as uint256
+	schema := append(OutputSchema{}, testTradingVolumeSchema...)
+	schema[0].Type = "uint256"
+	c = &TestOutputSchemaCircuit{Schema: schema}
+	err = test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+
+	mismatches := map[string]OutputSchema{
+		"declared as uint64 in the output schema, but added as uint248": {
+			{Name: "salt", Type: "bytes32"},
+			{Name: "sumVolume", Type: "uint64"},
+			{Name: "minBlockNum", Type: "uint248"},
+			{Name: "user", Type: "address"},
+			{Name: "ok", Type: "bool"},
+		},
+		"is added but not declared": testTradingVolumeSchema[:4],
+		"is declared in the output schema but never added": append(append(OutputSchema{}, testTradingVolumeSchema...),
+			OutputField{Name: "extra", Type: "uint8"}),
+		"duplicate field name": append(append(OutputSchema{}, testTradingVolumeSchema[:4]...),
+			OutputField{Name: "salt", Type: "bool"}),
+		`unsupported output type "uint250"`: {{Name: "salt", Type: "uint250"}},
+	}
+	for msg, schema := range mismatches {
+		c := &TestOutputSchemaCircuit{Schema: schema}
+		err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error containing %q, got %v", msg, err)
+		}
+	}
+}
+
+func TestGenerateSolidityDecoder(t *testing.T) {
+	if size := testTradingVolumeSchema.Size(); size != 92 {
+		t.Fatalf("expected output size 92, got %d", size)
+	}
+	code, err := GenerateSolidityDecoder("TradingVolumeOutput", testTradingVolumeSchema)
+	check(err)
+	expected := []string{
+		"library TradingVolumeOutput {",
+		"uint256 internal constant OUTPUT_LENGTH = 92;",
+		"uint248 sumVolume;",
+		"function decodeOutput(bytes calldata o) internal pure returns (Output memory out) {",
+		"out.salt = bytes32(o[0:32]);",
+		"out.sumVolume = uint248(bytes31(o[32:63]));",
+		"out.minBlockNum = uint64(bytes8(o[63:71]));",
+		"out.user = address(bytes20(o[71:91]));",
+		"out.ok = o[91] != 0;",
+	}
+	for _, line := range expected {
+		if !strings.Contains(code, line) {
+			t.Errorf("expected generated code to contain %q, got:\n%s", line, code)
+		}
+	}
+
+	_, err = GenerateSolidityDecoder("Bad-Name", testTradingVolumeSchema)
+	if err == nil {
+		t.Error("expected error on invalid library name")
+	}
+	_, err = GenerateSolidityDecoder("Lib", OutputSchema{{Name: "1st", Type: "bool"}})
+	if err == nil {
+		t.Error("expected error on invalid field name")
+	}
+	for _, name := range []string{"address", "return", "uint64", "ether"} {
+		_, err = GenerateSolidityDecoder("Lib", OutputSchema{{Name: name, Type: "bool"}})
+		if err == nil || !strings.Contains(err.Error(), "reserved word") {
+			t.Errorf("expected reserved word error on field name %s, got %v", name, err)
+		}
+	}
+	_, err = GenerateSolidityDecoder("contract", testTradingVolumeSchema)
+	if err == nil || !strings.Contains(err.Error(), "reserved word") {
+		t.Errorf("expected reserved word error on library name, got %v", err)
+	}
+}
+
+const (
+	testDecoderPath     = "testdata/TestOutput.sol"
+	testDecoderArtifact = "testdata/TestOutputDecoder.json"
+)
+
+var testDecoderSchema = OutputSchema{
+	{Name: "salt", Type: "bytes32"},
+	{Name: "sumVolume", Type: "uint248"},
+	{Name: "users", Type: "list", Fields: []OutputField{
+		{Name: "user", Type: "address"},
+		{Name: "amount", Type: "uint64"},
+	}},
+	{Name: "ok", Type: "bool"},
+}
+
+type testDecodedOutput struct {
+	Salt      [32]byte
+	SumVolume *big.Int
+	Users     []struct {
+		User   common.Address
+		Amount uint64
+	}
+	Ok bool
+}
+
+// TestSolidityDecoder decodes an output on chain with the decoder generated for
+// testDecoderSchema, compiled in testdata/TestOutputDecoder.json
+func TestSolidityDecoder(t *testing.T) {
+	code, err := GenerateSolidityDecoder("TestOutput", testDecoderSchema)
+	check(err)
+	if !checkGeneratedSolidity(t, testDecoderPath, code) {
+		return
+	}
+
+	expected := testDecodedOutput{Salt: [32]byte{1, 2, 3}, SumVolume: new(big.Int).Lsh(big.NewInt(3), 240), Ok: true}
+	output := append([]byte{}, expected.Salt[:]...)
+	output = append(output, common.LeftPadBytes(expected.SumVolume.Bytes(), 31)...)
+	output = binary.BigEndian.AppendUint32(output, 3)
+	for i := 0; i < 3; i++ {
+		user := common.BytesToAddress(testOutputListUser(i))
+		amount := uint64(1000 * (i + 1))
+		expected.Users = append(expected.Users, struct {
+			User   common.Address
+			Amount uint64
+		}{user, amount})
+		output = append(output, user.Bytes()...)
+		output = binary.BigEndian.AppendUint64(output, amount)
+	}
+	output = append(output, 1)
+
+	decoder := deployTestContract(t, testDecoderArtifact)
+	defer decoder.close()
+	decode := func(output []byte) (testDecodedOutput, error) {
+		calldata, err := decoder.abi.Pack("decode", output)
+		check(err)
+		res, err := decoder.call(calldata)
+		if err != nil {
+			return testDecodedOutput{}, err
+		}
+		values, err := decoder.abi.Unpack("decode", res)
+		check(err)
+		return *abi.ConvertType(values[0], new(testDecodedOutput)).(*testDecodedOutput), nil
+	}
+	decoded, err := decode(output)
+	check(err)
+	if decoded.Salt != expected.Salt || decoded.SumVolume.Cmp(expected.SumVolume) != 0 || decoded.Ok != expected.Ok {
+		t.Errorf("expected decoded output %+v, got %+v", expected, decoded)
+	}
+	if len(decoded.Users) != len(expected.Users) {
+		t.Fatalf("expected %d users, got %d", len(expected.Users), len(decoded.Users))
+	}
+	for i := range expected.Users {
+		if decoded.Users[i] != expected.Users[i] {
+			t.Errorf("expected user #%d %+v, got %+v", i, expected.Users[i], decoded.Users[i])
+		}
+	}
+
+	// outputs of another length revert
+	if _, err = decode(output[:len(output)-1]); err == nil {
+		t.Error("expected truncated output to revert")
+	}
+	if _, err = decode(append(bytes.Clone(output), 0)); err == nil {
+		t.Error("expected padded output to revert")
+	}
+}
+
+type TestOutputSchemaCircuit struct {
+	Schema OutputSchema `gnark:"-"`
+}
+
+func (c *TestOutputSchemaCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	api.OutputBytes32(ConstFromBigEndianBytes([]byte{1, 2, 3}))
+	api.OutputUint(248, newU248(100))
+	api.OutputUint64(64, newU64(200))
+	api.OutputAddress(newU248(300))
+	api.OutputBool(newU248(1))
+	return c.Schema.checkOutputs(api.outputTypes)
+}
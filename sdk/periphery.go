@@ -1,6 +1,7 @@
 package sdk
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"math/bits"
@@ -43,17 +44,12 @@ func CompileWithHashInfo(app AppCircuit, compileOutDir, srsDir string, hashInfo
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	err = WriteTo(ccs, filepath.Join(compileOutDir, "compiledCircuit"))
+	_, err = WriteSetupBundle(compileOutDir, AllocationOf(app), ccs, pk, vk, vkHash, hashInfo)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	err = WriteTo(pk, filepath.Join(compileOutDir, "pk"))
-	if err != nil {
-		return nil, nil, nil, nil, err
-	}
-	err = WriteTo(vk, filepath.Join(compileOutDir, "vk"))
 	fmt.Println("compilation/setup complete")
-	return ccs, pk, vk, vkHash, err
+	return ccs, pk, vk, vkHash, nil
 }
 
 func NewFullWitness(assign AppCircuit, in CircuitInput) (w, wpub witness.Witness, err error) {
@@ -88,10 +84,17 @@ func Setup(ccs constraint.ConstraintSystem, cacheDir string, maxReceipt, maxStor
 	if len(cacheDir) == 0 {
 		return nil, nil, nil, fmt.Errorf("must provide a directory to save SRS")
 	}
-	r1cs := ccs.(*cs_bn254.SparseR1CS)
+	// the SRS trimmed to the circuit size is cached next to the ignition SRS
 	srsDir := os.ExpandEnv(cacheDir)
+	provider := &srs.TrimmedCache{Dir: srsDir, Source: srs.DefaultProvider(srsDir)}
+	return SetupWithSRS(ccs, provider, maxReceipt, maxStorage, dataPoints, hashInfo)
+}
 
-	canonical, lagrange, err := srs.NewSRS(r1cs, srsDir)
+// SetupWithSRS is like Setup, but it takes the SRS from the provider instead of
+// downloading the ignition SRS, e.g. from a local mirror on machines without
+// internet access
+func SetupWithSRS(ccs constraint.ConstraintSystem, provider srs.Provider, maxReceipt, maxStorage, dataPoints int, hashInfo *BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, vkHash []byte, err error) {
+	canonical, lagrange, err := srs.NewSRSFrom(ccs, provider)
 	if err != nil {
 		return
 	}
@@ -181,7 +184,18 @@ func ReadSetupFrom(app AppCircuit, compileOutDir string, brevisApp *BrevisApp) (
 	return ReadSetupFromWithHashInfo(app, compileOutDir, brevisApp.BrevisHashInfo)
 }
 
+// ReadSetupFromWithHashInfo reads the setup bundle written by Compile to
+// compileOutDir, see ReadSetupBundle. The outputs of older versions, which have
+// no manifest, are read without validation.
 func ReadSetupFromWithHashInfo(app AppCircuit, compileOutDir string, hashInfo *BrevisHashInfo) (constraint.ConstraintSystem, plonk.ProvingKey, plonk.VerifyingKey, []byte, error) {
+	if HasSetupBundle(compileOutDir) {
+		b, err := ReadSetupBundle(compileOutDir, app, hashInfo)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		return b.CCS, b.PK, b.VK, b.VkHash, nil
+	}
+	fmt.Printf("no setup manifest found in %s, reading the setup without validation\n", compileOutDir)
 	ccs, err := ReadCircuitFrom(filepath.Join(compileOutDir, "compiledCircuit"))
 	if err != nil {
 		return nil, nil, nil, nil, err
@@ -204,7 +218,7 @@ func ReadCircuitFrom(path string) (constraint.ConstraintSystem, error) {
 		return nil, err
 	}
 	defer f.Close()
-	ccs := new(cs_bn254.R1CS)
+	ccs := new(cs_bn254.SparseR1CS)
 	d, err := ccs.ReadFrom(f)
 	if err != nil {
 		return nil, err
@@ -220,7 +234,7 @@ func ReadPkFrom(path string) (plonk.ProvingKey, error) {
 	}
 	defer f.Close()
 	pk := plonk.NewProvingKey(ecc.BN254)
-	d, err := pk.ReadFrom(f)
+	d, err := pk.ReadFrom(bufio.NewReaderSize(f, 1<<20))
 	if err != nil {
 		return nil, err
 	}
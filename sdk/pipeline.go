@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"github.com/consensys/gnark/frontend"
+)
+
+// Pipeline is a lazily evaluated, chainable view over a DataStream. Calling
+// Filter and Map on a Pipeline only records the operations. Nothing is added to
+// the circuit until a terminal operation (Collect, Count, Reduce or
+// PipeReduce) is called. At that point, all filters are fused into a single
+// product of the predicates with the source toggles, and each map function is
+// evaluated exactly once per element. Compared to chaining the eager Filter and
+// Map functions, this avoids re-normalizing the toggles and the Select
+// constraints on every filter step.
+//
+// Example:
+//
+//	sum := Sum(NewPipeline(receipts).
+//		Filter(isSwap).
+//		Filter(isFromUser).
+//		Map(volumeOf).
+//		Collect())
+//
+// Because Go methods cannot have type parameters, maps that change the element
+// type are done with PipeMap, and reductions to a different type with PipeReduce.
+type Pipeline[T CircuitVariable] struct {
+	api     *CircuitAPI
+	toggles []frontend.Variable
+	element func(i int) T
+	filters []func(i int) frontend.Variable
+	toggle  func(i int) frontend.Variable
+}
+
+// NewPipeline creates a lazily evaluated Pipeline from a DataStream
+func NewPipeline[T CircuitVariable](ds *DataStream[T]) *Pipeline[T] {
+	return newPipeline(ds.api, ds.toggles, func(i int) T { return ds.underlying[i] }, nil)
+}
+
+func newPipeline[T CircuitVariable](
+	api *CircuitAPI,
+	toggles []frontend.Variable,
+	element func(i int) T,
+	filters []func(i int) frontend.Variable,
+) *Pipeline[T] {
+	p := &Pipeline[T]{
+		api:     api,
+		toggles: toggles,
+		element: memoize(len(toggles), element),
+		filters: filters,
+	}
+	p.toggle = memoize(len(toggles), p.fusedToggle)
+	return p
+}
+
+// Filter records a filter with the given predicate. The predicate must return
+// 1/0 to include/exclude `current` in the result
+func (p *Pipeline[T]) Filter(predicate FilterFunc[T]) *Pipeline[T] {
+	filters := make([]func(i int) frontend.Variable, len(p.filters), len(p.filters)+1)
+	copy(filters, p.filters)
+	element := p.element
+	filters = append(filters, memoize(len(p.toggles), func(i int) frontend.Variable {
+		return predicate(element(i)).Val
+	}))
+	return newPipeline(p.api, p.toggles, element, filters)
+}
+
+// Map records a map function that does not change the element type. Use
+// PipeMap to map to a different type.
+func (p *Pipeline[T]) Map(mapFunc MapFunc[T, T]) *Pipeline[T] {
+	return PipeMap(p, mapFunc)
+}
+
+// PipeMap records a map function on the pipeline
+func PipeMap[T, R CircuitVariable](p *Pipeline[T], mapFunc MapFunc[T, R]) *Pipeline[R] {
+	element := p.element
+	mapped := newPipeline(p.api, p.toggles, func(i int) R { return mapFunc(element(i)) }, p.filters)
+	// maps keep the filters, so the fused toggles are shared with p
+	mapped.toggle = p.toggle
+	return mapped
+}
+
+// Collect evaluates the pipeline and materializes the result into a DataStream
+func (p *Pipeline[T]) Collect() *DataStream[T] {
+	res := make([]T, len(p.toggles))
+	toggles := make([]frontend.Variable, len(p.toggles))
+	for i := range p.toggles {
+		res[i] = p.element(i)
+		toggles[i] = p.toggle(i)
+	}
+	return newDataStream(p.api, res, toggles)
+}
+
+// Count evaluates the pipeline and returns the number of valid elements
+func (p *Pipeline[T]) Count() Uint248 {
+	var count frontend.Variable = 0
+	for i := range p.toggles {
+		count = p.api.g.Add(count, p.toggle(i))
+	}
+	return newU248(count)
+}
+
+// Reduce evaluates the pipeline and reduces it to a value of the same type as
+// the elements. Use PipeReduce to reduce to a different type.
+func (p *Pipeline[T]) Reduce(initial T, reducer ReduceFunc[T, T]) T {
+	return PipeReduce(p, initial, reducer)
+}
+
+// PipeReduce evaluates the pipeline and reduces it with the given reducer and
+// initial condition
+func PipeReduce[T, R CircuitVariable](p *Pipeline[T], initial R, reducer ReduceFunc[T, R]) R {
+	acc := initial
+	for i := range p.toggles {
+		acc = reduceStep(p.api, p.toggle(i), acc, p.element(i), reducer)
+	}
+	return acc
+}
+
+// fusedToggle computes the toggle of the i-th element as the product of the
+// source toggle and all filter predicates
+func (p *Pipeline[T]) fusedToggle(i int) frontend.Variable {
+	if len(p.filters) == 0 {
+		return p.toggles[i]
+	}
+	g := p.api.g
+	toggle := p.api.isEqual(p.toggles[i], 1)
+	for _, filter := range p.filters {
+		predicate := filter(i)
+		g.AssertIsBoolean(predicate)
+		toggle = g.Mul(toggle, predicate)
+	}
+	return toggle
+}
+
+// memoize caches the result of f for each index in [0, n) so that the circuit
+// for each element is only built once
+func memoize[V any](n int, f func(i int) V) func(i int) V {
+	cache := make([]V, n)
+	done := make([]bool, n)
+	return func(i int) V {
+		if !done[i] {
+			cache[i] = f(i)
+			done[i] = true
+		}
+		return cache[i]
+	}
+}
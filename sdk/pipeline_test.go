@@ -0,0 +1,130 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test"
+)
+
+var (
+	testPipelineContract = ConstUint248(0xc0ffee)
+	testPipelineEventID  = ConstUint248(0xe1e1)
+)
+
+func newTestPipelineReceipts() DataPoints[Receipt] {
+	in := NewDataPoints(8, defaultReceipt)
+	for i := range in.Raw {
+		r := defaultReceipt()
+		r.BlockNum = ConstUint32(100 + i)
+		r.Fields[0].Contract = testPipelineContract
+		r.Fields[0].EventID = testPipelineEventID
+		r.Fields[0].Value = ConstFromBigEndianBytes([]byte{byte(i + 1)})
+		if i%3 == 0 {
+			r.Fields[0].Contract = ConstUint248(1)
+		}
+		if i%4 == 0 {
+			r.Fields[0].EventID = ConstUint248(2)
+		}
+		in.Raw[i] = r
+		in.Toggles[i] = 1
+	}
+	in.Toggles[7] = 0
+	return in
+}
+
+func TestPipeline(t *testing.T) {
+	c := &TestPipelineCircuit{In: newTestPipelineReceipts()}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+
+	// fusing chained filters should be cheaper than chaining the eager
+	// operators, see also the tradingvolume example
+	eager := &TestPipelineCostCircuit{In: newTestPipelineReceipts()}
+	fused := &TestPipelineCostCircuit{In: newTestPipelineReceipts(), Fused: true}
+	eagerCcs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, eager)
+	check(err)
+	fusedCcs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, fused)
+	check(err)
+	t.Logf("constraints: eager %d, fused %d", eagerCcs.GetNbConstraints(), fusedCcs.GetNbConstraints())
+	if fusedCcs.GetNbConstraints() >= eagerCcs.GetNbConstraints() {
+		t.Errorf("expected fused pipeline to use less constraints than eager: %d >= %d",
+			fusedCcs.GetNbConstraints(), eagerCcs.GetNbConstraints())
+	}
+}
+
+type TestPipelineCircuit struct {
+	In DataPoints[Receipt]
+}
+
+func (c *TestPipelineCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	u248 := api.Uint248
+	receipts := NewDataStream(api, c.In)
+
+	isContract := func(r Receipt) Uint248 { return u248.IsEqual(r.Fields[0].Contract, testPipelineContract) }
+	isEvent := func(r Receipt) Uint248 { return u248.IsEqual(r.Fields[0].EventID, testPipelineEventID) }
+	value := func(r Receipt) Uint248 { return api.ToUint248(r.Fields[0].Value) }
+
+	// valid: 1,2,3,4,5,6,7 (index 7 toggled off)
+	// contract filter removes 1, 4, 7 (i = 0, 3, 6); event filter removes 1, 5 (i = 0, 4)
+	// remaining values: 2, 3, 6
+	p := NewPipeline(receipts).Filter(isContract).Filter(isEvent)
+	values := PipeMap(p, value)
+	u248.AssertIsEqual(values.Count(), newU248(3))
+	u248.AssertIsEqual(Sum(values.Collect()), newU248(11))
+	u248.AssertIsEqual(values.Map(func(v Uint248) Uint248 { return u248.Mul(v, v) }).
+		Reduce(newU248(0), func(acc, v Uint248) Uint248 { return u248.Add(acc, v) }), newU248(49))
+
+	// results are the same as the eager operators
+	eager := Map(Filter(Filter(receipts, isContract), isEvent), value)
+	collected := values.Collect()
+	for i := range eager.underlying {
+		u248.AssertIsEqual(eager.underlying[i], collected.underlying[i])
+		g.AssertIsEqual(eager.toggles[i], collected.toggles[i])
+	}
+
+	// filtering after mapping
+	large := values.Filter(func(v Uint248) Uint248 { return u248.IsGreaterThan(v, newU248(2)) })
+	u248.AssertIsEqual(Sum(large.Collect()), newU248(9))
+	blockNums := PipeReduce(large, newU248(0), func(acc Uint248, v Uint248) Uint248 { return u248.Add(acc, newU248(1)) })
+	u248.AssertIsEqual(blockNums, newU248(2))
+
+	// pipeline without filters keeps the source toggles
+	all := NewPipeline(receipts).Collect()
+	for i := range all.toggles {
+		g.AssertIsEqual(all.toggles[i], receipts.toggles[i])
+	}
+	return nil
+}
+
+// TestPipelineCostCircuit is a Filter -> Filter -> Filter -> Map -> Reduce chain
+// built either with the eager operators or a fused Pipeline
+type TestPipelineCostCircuit struct {
+	In    DataPoints[Receipt]
+	Fused bool `gnark:"-"`
+}
+
+func (c *TestPipelineCostCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	u248 := api.Uint248
+	receipts := NewDataStream(api, c.In)
+
+	isContract := func(r Receipt) Uint248 { return u248.IsEqual(r.Fields[0].Contract, testPipelineContract) }
+	isEvent := func(r Receipt) Uint248 { return u248.IsEqual(r.Fields[0].EventID, testPipelineEventID) }
+	isData := func(r Receipt) Uint248 { return u248.IsZero(r.Fields[0].IsTopic) }
+	value := func(r Receipt) Uint248 { return newU248(r.Fields[0].Value.Val[0]) }
+	sum := func(acc, v Uint248) Uint248 { return u248.Add(acc, v) }
+
+	var total Uint248
+	if c.Fused {
+		p := NewPipeline(receipts).Filter(isContract).Filter(isEvent).Filter(isData)
+		total = PipeMap(p, value).Reduce(newU248(0), sum)
+	} else {
+		total = Reduce(Map(Filter(Filter(Filter(receipts, isContract), isEvent), isData), value), newU248(0), sum)
+	}
+	u248.AssertIsEqual(total, newU248(11))
+	return nil
+}
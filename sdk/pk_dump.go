@@ -0,0 +1,399 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+// A pk dump stores the G1 points of a proving key in their in-memory layout, so
+// that MmapPk can map them from the file instead of decoding them into the heap.
+// The points are only paged in when the prover reads them, and as the pages are
+// backed by the file, the OS can evict them under memory pressure. A dump is
+// about twice the size of the compressed pk file and can only be read on
+// machines with the byte order of the machine that wrote it.
+//
+// Layout: a header of pkDumpHeaderSize bytes, the raw vk padded to
+// pkDumpAlignment, the points of the canonical KZG key, then the points of the
+// lagrange KZG key.
+const (
+	setupPkDumpFile = "pk.dump"
+
+	pkDumpMagic      = "BRVPKDMP"
+	pkDumpVersion    = 1
+	pkDumpByteOrder  = 0x01020304
+	pkDumpHeaderSize = 128
+	pkDumpAlignment  = 64
+
+	// points decoded at once when converting a pk file to a dump
+	pkDumpChunk = 1 << 16
+)
+
+const g1Size = int(unsafe.Sizeof(bn254.G1Affine{}))
+
+type pkDumpHeader struct {
+	// PkSize and PkModTime identify the pk file the dump was converted from, so
+	// that a dump is not used once its pk file has been replaced
+	PkSize     int64
+	PkModTime  int64
+	PkSha256   [32]byte
+	NbKzg      uint64
+	NbLagrange uint64
+	VkSize     uint64
+}
+
+func (h *pkDumpHeader) marshal() []byte {
+	b := make([]byte, 0, pkDumpHeaderSize)
+	b = append(b, pkDumpMagic...)
+	b = binary.NativeEndian.AppendUint32(b, pkDumpVersion)
+	b = binary.NativeEndian.AppendUint32(b, pkDumpByteOrder)
+	b = binary.NativeEndian.AppendUint64(b, uint64(h.PkSize))
+	b = binary.NativeEndian.AppendUint64(b, uint64(h.PkModTime))
+	b = append(b, h.PkSha256[:]...)
+	b = binary.NativeEndian.AppendUint64(b, h.NbKzg)
+	b = binary.NativeEndian.AppendUint64(b, h.NbLagrange)
+	b = binary.NativeEndian.AppendUint64(b, h.VkSize)
+	return append(b, make([]byte, pkDumpHeaderSize-len(b))...)
+}
+
+func (h *pkDumpHeader) unmarshal(b []byte) error {
+	if len(b) < pkDumpHeaderSize || string(b[:8]) != pkDumpMagic {
+		return fmt.Errorf("not a pk dump")
+	}
+	if binary.NativeEndian.Uint32(b[12:]) != pkDumpByteOrder {
+		return fmt.Errorf("pk dump was written on a machine of another byte order")
+	}
+	if v := binary.NativeEndian.Uint32(b[8:]); v != pkDumpVersion {
+		return fmt.Errorf("pk dump version %d is not supported, expected version %d", v, pkDumpVersion)
+	}
+	h.PkSize = int64(binary.NativeEndian.Uint64(b[16:]))
+	h.PkModTime = int64(binary.NativeEndian.Uint64(b[24:]))
+	copy(h.PkSha256[:], b[32:64])
+	h.NbKzg = binary.NativeEndian.Uint64(b[64:])
+	h.NbLagrange = binary.NativeEndian.Uint64(b[72:])
+	h.VkSize = binary.NativeEndian.Uint64(b[80:])
+	return nil
+}
+
+func (h *pkDumpHeader) size() int64 {
+	return int64(pkDumpHeaderSize + alignUp(h.VkSize) + (h.NbKzg+h.NbLagrange)*uint64(g1Size))
+}
+
+func alignUp(n uint64) uint64 {
+	return (n + pkDumpAlignment - 1) / pkDumpAlignment * pkDumpAlignment
+}
+
+// MappedPk is a proving key whose points are mapped from a pk dump, see MmapPk
+type MappedPk struct {
+	PK plonk.ProvingKey
+
+	header *pkDumpHeader
+	unmap  func() error
+}
+
+// Close unmaps the points of the pk. The pk must not be used afterwards.
+func (m *MappedPk) Close() error {
+	if m.unmap == nil {
+		return nil
+	}
+	err := m.unmap()
+	m.PK, m.unmap = nil, nil
+	return err
+}
+
+// WritePkDump writes the pk to path in the layout MmapPk maps
+func WritePkDump(pk plonk.ProvingKey, path string) error {
+	bn254Pk, ok := pk.(*plonk_bn254.ProvingKey)
+	if !ok {
+		return fmt.Errorf("pk dumps are only supported for BN254")
+	}
+	var vk bytes.Buffer
+	if _, err := bn254Pk.Vk.WriteRawTo(&vk); err != nil {
+		return err
+	}
+	h := &pkDumpHeader{
+		NbKzg:      uint64(len(bn254Pk.Kzg.G1)),
+		NbLagrange: uint64(len(bn254Pk.KzgLagrange.G1)),
+		VkSize:     uint64(vk.Len()),
+	}
+	return writePkDump(os.ExpandEnv(path), h, func(w io.Writer) error {
+		if _, err := w.Write(vk.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.Write(make([]byte, alignUp(h.VkSize)-h.VkSize)); err != nil {
+			return err
+		}
+		if _, err := w.Write(g1Bytes(bn254Pk.Kzg.G1)); err != nil {
+			return err
+		}
+		_, err := w.Write(g1Bytes(bn254Pk.KzgLagrange.G1))
+		return err
+	})
+}
+
+// writePkDump writes the dump through a temp file, so that a dump is either
+// complete or absent
+func writePkDump(path string, h *pkDumpHeader, writeBody func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	// the header is written last, when the sizes are known
+	if _, err = f.Seek(pkDumpHeaderSize, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+	if err = writeBody(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.WriteAt(h.marshal(), 0); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Printf("pk dump written to %s\n", path)
+	return nil
+}
+
+// MmapPk maps the pk dump at path into memory. Only the vk is decoded, the
+// points of the KZG keys are read straight from the mapped file when the prover
+// needs them, which makes loading the pk almost instant. The pages are mapped
+// copy-on-write, so the dump is never modified. On platforms without mmap, the
+// dump is read into memory, which is still much faster than decoding a pk file.
+func MmapPk(path string) (*MappedPk, error) {
+	path = os.ExpandEnv(path)
+	data, unmap, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pk, h, err := pkFromDump(data)
+	if err != nil {
+		unmap()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &MappedPk{PK: pk, header: h, unmap: unmap}, nil
+}
+
+// ReadPkDump reads the pk dump at path into memory
+func ReadPkDump(path string) (plonk.ProvingKey, error) {
+	data, err := os.ReadFile(os.ExpandEnv(path))
+	if err != nil {
+		return nil, err
+	}
+	pk, _, err := pkFromDump(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pk, nil
+}
+
+func pkFromDump(data []byte) (*plonk_bn254.ProvingKey, *pkDumpHeader, error) {
+	h := &pkDumpHeader{}
+	if err := h.unmarshal(data); err != nil {
+		return nil, nil, err
+	}
+	if h.size() != int64(len(data)) {
+		return nil, nil, fmt.Errorf("pk dump has %d bytes, expected %d, the file may be truncated", len(data), h.size())
+	}
+	pk := &plonk_bn254.ProvingKey{Vk: &plonk_bn254.VerifyingKey{}}
+	vk := data[pkDumpHeaderSize : pkDumpHeaderSize+h.VkSize]
+	if _, err := pk.Vk.ReadFrom(bytes.NewReader(vk)); err != nil {
+		return nil, nil, fmt.Errorf("cannot read vk of pk dump: %w", err)
+	}
+	offset := pkDumpHeaderSize + alignUp(h.VkSize)
+	pk.Kzg.G1 = g1Slice(data[offset:], h.NbKzg)
+	offset += h.NbKzg * uint64(g1Size)
+	pk.KzgLagrange.G1 = g1Slice(data[offset:], h.NbLagrange)
+	return pk, h, nil
+}
+
+func g1Bytes(points []bn254.G1Affine) []byte {
+	if len(points) == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(&points[0])), len(points)*g1Size)
+}
+
+func g1Slice(data []byte, n uint64) []bn254.G1Affine {
+	if n == 0 {
+		return []bn254.G1Affine{}
+	}
+	return unsafe.Slice((*bn254.G1Affine)(unsafe.Pointer(&data[0])), n)
+}
+
+// MmapSetupPk maps the pk of a setup dir, i.e. a setup bundle or the output dir
+// of an older Compile, from the pk dump in the dir. If there is no dump yet, or
+// if the pk file has changed since the dump was written, the pk file is
+// converted to a dump first. The conversion streams the pk file, so the pk is
+// never fully loaded in memory. The pk file of a setup bundle is verified
+// against its checksum in the manifest while it is converted.
+func MmapSetupPk(dir string) (*MappedPk, error) {
+	dir = os.ExpandEnv(dir)
+	pkPath := filepath.Join(dir, setupPkFile)
+	dumpPath := filepath.Join(dir, setupPkDumpFile)
+	var checksum string
+	if HasSetupBundle(dir) {
+		manifest, err := ReadSetupManifest(dir)
+		if err != nil {
+			return nil, err
+		}
+		checksum = manifest.Checksums[setupPkFile]
+	}
+	pkInfo, pkErr := os.Stat(pkPath)
+
+	m, err := MmapPk(dumpPath)
+	if err == nil {
+		h := m.header
+		var upToDate bool
+		if checksum != "" {
+			upToDate = hex.EncodeToString(h.PkSha256[:]) == checksum
+		} else {
+			// the dump can be used on its own once the pk file has been removed
+			upToDate = pkErr != nil || (h.PkSize == pkInfo.Size() && h.PkModTime == pkInfo.ModTime().UnixNano())
+		}
+		if upToDate {
+			fmt.Printf("pk mapped from %s\n", dumpPath)
+			return m, nil
+		}
+		m.Close()
+		fmt.Printf("%s has changed since %s was written, converting it again\n", pkPath, dumpPath)
+	} else if !os.IsNotExist(err) {
+		fmt.Printf("cannot map %s, converting %s again: %s\n", dumpPath, pkPath, err.Error())
+	}
+	if pkErr != nil {
+		return nil, pkErr
+	}
+	if err = convertPkFile(pkPath, dumpPath, checksum); err != nil {
+		return nil, err
+	}
+	return MmapPk(dumpPath)
+}
+
+// convertPkFile converts a pk file written by pk.WriteTo or pk.WriteRawTo to a
+// pk dump, decoding pkDumpChunk points at a time
+func convertPkFile(pkPath, dumpPath, checksum string) error {
+	before := time.Now()
+	f, err := os.Open(pkPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	digest := sha256.New()
+	r := bufio.NewReaderSize(io.TeeReader(f, digest), 1<<20)
+
+	vk := &plonk_bn254.VerifyingKey{}
+	if _, err = vk.ReadFrom(r); err != nil {
+		return fmt.Errorf("cannot read vk of %s: %w", pkPath, err)
+	}
+	var vkBytes bytes.Buffer
+	if _, err = vk.WriteRawTo(&vkBytes); err != nil {
+		return err
+	}
+	h := &pkDumpHeader{PkSize: info.Size(), PkModTime: info.ModTime().UnixNano(), VkSize: uint64(vkBytes.Len())}
+	err = writePkDump(dumpPath, h, func(w io.Writer) error {
+		if _, err := w.Write(vkBytes.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.Write(make([]byte, alignUp(h.VkSize)-h.VkSize)); err != nil {
+			return err
+		}
+		var err error
+		if h.NbKzg, err = convertPoints(r, w); err != nil {
+			return fmt.Errorf("cannot read kzg key of %s: %w", pkPath, err)
+		}
+		if h.NbLagrange, err = convertPoints(r, w); err != nil {
+			return fmt.Errorf("cannot read lagrange kzg key of %s: %w", pkPath, err)
+		}
+		if _, err = r.Peek(1); err != io.EOF {
+			return fmt.Errorf("%s has trailing bytes", pkPath)
+		}
+		copy(h.PkSha256[:], digest.Sum(nil))
+		if checksum != "" && hex.EncodeToString(h.PkSha256[:]) != checksum {
+			return fmt.Errorf("checksum of %s does not match the setup manifest, the file may be corrupted", pkPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s converted to a pk dump in %s\n", pkPath, time.Since(before))
+	return nil
+}
+
+// convertPoints reads a slice of G1 points encoded by a bn254.Encoder, without
+// subgroup checks like UnsafeReadFrom, and writes them in their in-memory layout
+func convertPoints(r io.Reader, w io.Writer) (uint64, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, err
+	}
+	total := uint64(binary.BigEndian.Uint32(header[:]))
+	if total == 0 {
+		return 0, nil
+	}
+	// all the points of a key have the same encoding, and the two most
+	// significant bits of a compressed point are never both 0
+	first := make([]byte, bn254.SizeOfG1AffineCompressed)
+	if _, err := io.ReadFull(r, first); err != nil {
+		return 0, err
+	}
+	pointSize := uint64(bn254.SizeOfG1AffineCompressed)
+	if first[0]>>6 == 0 {
+		pointSize = bn254.SizeOfG1AffineUncompressed
+	}
+	r = io.MultiReader(bytes.NewReader(first), r)
+
+	buf := make([]byte, 4+pkDumpChunk*pointSize)
+	points := make([]bn254.G1Affine, 0, pkDumpChunk)
+	for done := uint64(0); done < total; {
+		n := min(total-done, pkDumpChunk)
+		binary.BigEndian.PutUint32(buf, uint32(n))
+		chunk := buf[:4+n*pointSize]
+		if _, err := io.ReadFull(r, chunk[4:]); err != nil {
+			return 0, err
+		}
+		points = points[:0]
+		dec := bn254.NewDecoder(bytes.NewReader(chunk), bn254.NoSubgroupChecks())
+		if err := dec.Decode(&points); err != nil {
+			return 0, err
+		}
+		if _, err := w.Write(g1Bytes(points)); err != nil {
+			return 0, err
+		}
+		done += n
+	}
+	return total, nil
+}
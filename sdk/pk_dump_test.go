@@ -0,0 +1,155 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+func TestPkDump(t *testing.T) {
+	ccs, pk, vk := testPkSetup(&squareCircuit{})
+	dir := t.TempDir()
+	writeTestSetupBundle(dir, Allocation{MaxReceipts: 32}, ccs, pk, vk)
+
+	m, err := MmapSetupPk(dir)
+	check(err)
+	defer m.Close()
+	expectSamePk(t, m.PK, pk)
+	dumpPath := filepath.Join(dir, setupPkDumpFile)
+	info, err := os.Stat(dumpPath)
+	check(err)
+
+	// the mapped pk proves like the original one
+	w, err := frontend.NewWitness(&squareCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	check(err)
+	proof, err := Prove(ccs, m.PK, w)
+	check(err)
+	wpub, err := w.Public()
+	check(err)
+	check(Verify(vk, wpub, proof))
+
+	// the dump is reused
+	m2, err := ReadSetupBundleMapped(dir, nil, nil)
+	check(err)
+	defer m2.Close()
+	expectSamePk(t, m2.PK, pk)
+	if info2, err := os.Stat(dumpPath); err != nil || !info2.ModTime().Equal(info.ModTime()) {
+		t.Fatal("the pk dump should not be written again")
+	}
+
+	// dumps written directly from a pk
+	path := filepath.Join(t.TempDir(), "pk.dump")
+	check(WritePkDump(pk, path))
+	readPk, err := ReadPkDump(path)
+	check(err)
+	expectSamePk(t, readPk, pk)
+	b, err := os.ReadFile(path)
+	check(err)
+	check(os.WriteFile(path, b[:len(b)-1], 0644))
+	if _, err = MmapPk(path); err == nil {
+		t.Fatal("expected an error for a truncated dump")
+	}
+
+	// the pk file of a bundle is verified while it is converted
+	dir = t.TempDir()
+	writeTestSetupBundle(dir, Allocation{MaxReceipts: 32}, ccs, pk, vk)
+	pkPath := filepath.Join(dir, setupPkFile)
+	b, err = os.ReadFile(pkPath)
+	check(err)
+	b[len(b)-1] ^= 1
+	check(os.WriteFile(pkPath, b, 0644))
+	if _, err = MmapSetupPk(dir); err == nil {
+		t.Fatal("expected an error for a corrupted pk file")
+	}
+	if _, err = os.Stat(filepath.Join(dir, setupPkDumpFile)); !os.IsNotExist(err) {
+		t.Fatal("no dump should be written for a corrupted pk file")
+	}
+}
+
+func expectSamePk(t *testing.T, actual, expected plonk.ProvingKey) {
+	t.Helper()
+	a, e := actual.(*plonk_bn254.ProvingKey), expected.(*plonk_bn254.ProvingKey)
+	if !reflect.DeepEqual(a.Kzg, e.Kzg) || !reflect.DeepEqual(a.KzgLagrange, e.KzgLagrange) || !reflect.DeepEqual(a.Vk, e.Vk) {
+		t.Fatal("pk does not match")
+	}
+}
+
+func testPkSetup(circuit frontend.Circuit) (constraint.ConstraintSystem, plonk.ProvingKey, plonk.VerifyingKey) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	check(err)
+	canonical, lagrange, err := unsafekzg.NewSRS(ccs)
+	check(err)
+	pk, vk, err := plonk.Setup(ccs, canonical, lagrange)
+	check(err)
+	return ccs, pk, vk
+}
+
+type mulChainCircuit struct {
+	X frontend.Variable
+}
+
+func (c *mulChainCircuit) Define(api frontend.API) error {
+	x := c.X
+	for i := 0; i < 1<<16; i++ {
+		x = api.Mul(x, x)
+	}
+	api.AssertIsDifferent(x, 0)
+	return nil
+}
+
+// BenchmarkPkLoading compares loading the pk of a circuit of 2^16 constraints
+// from the pk file with loading it from its dump. B/op is the heap taken by
+// the pk.
+func BenchmarkPkLoading(b *testing.B) {
+	dir := b.TempDir()
+	ccs, pk, vk := testPkSetup(&mulChainCircuit{})
+	writeTestSetupBundle(dir, Allocation{MaxReceipts: 32}, ccs, pk, vk)
+	m, err := MmapSetupPk(dir)
+	check(err)
+	check(m.Close())
+	b.ResetTimer()
+
+	b.Run("ReadPkFrom", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := ReadPkFrom(filepath.Join(dir, setupPkFile))
+			check(err)
+		}
+	})
+	b.Run("UnsafeReadFrom", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f, err := os.Open(filepath.Join(dir, setupPkFile))
+			check(err)
+			pk := &plonk_bn254.ProvingKey{}
+			_, err = pk.UnsafeReadFrom(f)
+			check(err)
+			f.Close()
+		}
+	})
+	b.Run("ReadPkDump", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := ReadPkDump(filepath.Join(dir, setupPkDumpFile))
+			check(err)
+		}
+	})
+	b.Run("MmapPk", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			m, err := MmapPk(filepath.Join(dir, setupPkDumpFile))
+			check(err)
+			check(m.Close())
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package sdk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/profile"
+	pprof "github.com/google/pprof/profile"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// ConstraintProfile attributes the constraints of a compiled circuit to the
+// call stacks that added them, as recorded by the gnark profiler
+type ConstraintProfile struct {
+	// Total is the number of constraints recorded
+	Total int
+	// Stacks maps call stacks to the number of constraints they added. A stack
+	// lists the functions from the circuit's Define down to the gnark API call,
+	// separated by ";". The range checks and commitments added after Define
+	// have the stacks of the deferred callbacks of gnark.
+	Stacks map[string]int
+
+	samples []*profileSample
+}
+
+type profileSample struct {
+	count int
+	// from the outermost to the innermost
+	frames []runtime.Frame
+}
+
+// constraintsPprofFile is the name of the pprof profile written by
+// CompileWithProfile
+const constraintsPprofFile = "constraints.pprof"
+
+// CompileWithProfile is like CompileOnly, but it also attributes the constraints
+// to the SDK API calls and the user functions that added them. The constraints
+// are recorded by the gnark profiler, which keeps up to 20 frames per
+// constraint, so the stacks of deeply nested gadgets are cut short. The
+// profile is saved to profileOutDir (see ConstraintProfile.Save) and a summary
+// is printed.
+func CompileWithProfile(app AppCircuit, profileOutDir string) (constraint.ConstraintSystem, *ConstraintProfile, error) {
+	err := os.MkdirAll(profileOutDir, 0755)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create dir %s: %s", profileOutDir, err.Error())
+	}
+	pprofPath := filepath.Join(profileOutDir, constraintsPprofFile)
+	before := time.Now()
+	ccs, err := compileWithProfile(DefaultHostCircuit(app), pprofPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compile: %s", err.Error())
+	}
+	fmt.Printf("circuit compiled in %s, number constraints %d\n", time.Since(before), ccs.GetNbConstraints())
+
+	p, err := ReadConstraintProfile(pprofPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Println(p.Summary(10))
+	err = p.Save(profileOutDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ccs, p, nil
+}
+
+// compileWithProfile compiles the circuit in a gnark profiling session that
+// writes the pprof profile to pprofPath
+func compileWithProfile(circuit frontend.Circuit, pprofPath string) (constraint.ConstraintSystem, error) {
+	p := profile.Start(profile.WithPath(pprofPath))
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	p.Stop()
+	return ccs, err
+}
+
+// ReadConstraintProfile reads the pprof profile written by CompileWithProfile
+func ReadConstraintProfile(pprofPath string) (*ConstraintProfile, error) {
+	f, err := os.Open(pprofPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", pprofPath, err.Error())
+	}
+	defer f.Close()
+	pp, err := pprof.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", pprofPath, err.Error())
+	}
+
+	p := &ConstraintProfile{Stacks: map[string]int{}}
+	samples := map[string]*profileSample{}
+	for _, s := range pp.Sample {
+		var frames []runtime.Frame
+		// locations are listed from the innermost to the outermost
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			lines := s.Location[i].Line
+			for j := len(lines) - 1; j >= 0; j-- {
+				fn := lines[j].Function
+				frames = append(frames, runtime.Frame{Function: fn.SystemName, File: fn.Filename, Line: int(lines[j].Line)})
+			}
+		}
+		names := make([]string, len(frames))
+		for i, f := range frames {
+			names[i] = frameName(f)
+		}
+		stack := strings.Join(names, ";")
+		count := int(s.Value[0])
+		sample, ok := samples[stack]
+		if !ok {
+			sample = &profileSample{frames: frames}
+			samples[stack] = sample
+			p.samples = append(p.samples, sample)
+		}
+		sample.count += count
+		p.Stacks[stack] += count
+		p.Total += count
+	}
+	return p, nil
+}
+
+// Save writes the profile to dir as
+//
+//   - constraints.folded: the call stacks in the folded format of flamegraph.pl,
+//     which can also be opened in https://www.speedscope.app
+//   - constraints.txt: the summary of the profile, see Summary
+//
+// The pprof profile of CompileWithProfile is written to the same dir as
+// constraints.pprof, and can be viewed with `go tool pprof -http=:8080`.
+func (p *ConstraintProfile) Save(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create dir %s: %s", dir, err.Error())
+	}
+	files := map[string]string{
+		"constraints.folded": p.Folded(),
+		"constraints.txt":    p.Summary(0),
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %s", path, err.Error())
+		}
+	}
+	fmt.Printf("constraint profile saved to %s\n", dir)
+	return nil
+}
+
+// Folded returns the call stacks in the folded format, one stack per line
+// followed by its number of constraints
+func (p *ConstraintProfile) Folded() string {
+	stacks := make([]string, 0, len(p.Stacks))
+	for s := range p.Stacks {
+		stacks = append(stacks, s)
+	}
+	sort.Strings(stacks)
+	b := &strings.Builder{}
+	for _, s := range stacks {
+		fmt.Fprintf(b, "%s %d\n", s, p.Stacks[s])
+	}
+	return b.String()
+}
+
+// Summary returns the constraint counts by SDK API call and by user function.
+// The self count of an SDK API call is the number of constraints it added
+// outside the user functions it calls back, e.g. the lambda of a Map. The self
+// count of a user function excludes the SDK API calls it makes. Only the top
+// entries of each table are listed, or all if top is 0.
+func (p *ConstraintProfile) Summary(top int) string {
+	apiCalls := map[string]*profileCount{}
+	userFuncs := map[string]*profileCount{}
+	for _, s := range p.samples {
+		seen := map[string]bool{}
+		entry, user := -1, -1
+		for i, f := range s.frames {
+			name := frameName(f)
+			if isSDKFrame(f) {
+				if i == 0 || !isSDKFrame(s.frames[i-1]) || strings.HasSuffix(s.frames[i-1].Function, "(*HostCircuit).Define") {
+					entry = i
+					if !seen[name] {
+						countOf(apiCalls, name).total += s.count
+					}
+				}
+			} else if isUserFrame(f) {
+				user = i
+				if !seen[name] {
+					countOf(userFuncs, name).total += s.count
+				}
+			}
+			seen[name] = true
+		}
+		if entry >= 0 {
+			countOf(apiCalls, frameName(s.frames[entry])).self += s.count
+		}
+		if user >= 0 && user > entry {
+			countOf(userFuncs, frameName(s.frames[user])).self += s.count
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "total constraints: %d\n\n", p.Total)
+	fmt.Fprintln(b, "by SDK API call:")
+	writeProfileTable(b, apiCalls, p.Total, top)
+	fmt.Fprintln(b, "\nby user function:")
+	writeProfileTable(b, userFuncs, p.Total, top)
+	return b.String()
+}
+
+type profileCount struct {
+	name        string
+	self, total int
+}
+
+func countOf(counts map[string]*profileCount, name string) *profileCount {
+	c, ok := counts[name]
+	if !ok {
+		c = &profileCount{name: name}
+		counts[name] = c
+	}
+	return c
+}
+
+func writeProfileTable(b *strings.Builder, counts map[string]*profileCount, total, top int) {
+	rows := make([]*profileCount, 0, len(counts))
+	for _, c := range counts {
+		rows = append(rows, c)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].total != rows[j].total {
+			return rows[i].total > rows[j].total
+		}
+		return rows[i].name < rows[j].name
+	})
+	if top > 0 && len(rows) > top {
+		rows = rows[:top]
+	}
+	t := table.NewWriter()
+	t.SetStyle(table.StyleLight)
+	t.AppendHeader(table.Row{"function", "self", "total", "total %"})
+	for _, r := range rows {
+		percent := 0.0
+		if total > 0 {
+			percent = float64(r.total) * 100 / float64(total)
+		}
+		t.AppendRow(table.Row{r.name, r.self, r.total, fmt.Sprintf("%.2f%%", percent)})
+	}
+	b.WriteString(t.Render())
+	b.WriteString("\n")
+}
+
+const sdkPackagePrefix = "github.com/brevis-network/brevis-sdk/sdk."
+
+// isSDKFrame tells whether f is in the sdk package. Tests of the sdk package
+// are considered user code.
+func isSDKFrame(f runtime.Frame) bool {
+	return strings.HasPrefix(f.Function, sdkPackagePrefix) && !strings.HasSuffix(f.File, "_test.go")
+}
+
+// isUserFrame tells whether f is neither in the sdk package nor in gnark, the
+// hash gadgets or the Go runtime
+func isUserFrame(f runtime.Frame) bool {
+	for _, prefix := range []string{"github.com/consensys/", "github.com/brevis-network/zk-hash/", "runtime."} {
+		if strings.HasPrefix(f.Function, prefix) {
+			return false
+		}
+	}
+	return !isSDKFrame(f)
+}
+
+// frameName returns the function name of f without the package path and the
+// type parameters, e.g. sdk.(*Uint248API).Div
+func frameName(f runtime.Frame) string {
+	name := f.Function[strings.LastIndex(f.Function, "/")+1:]
+	// the gnark profiler writes the type parameters as [T]
+	return strings.ReplaceAll(strings.ReplaceAll(name, "[...]", ""), "[T]", "")
+}
@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileWithProfile(t *testing.T) {
+	dir := t.TempDir()
+	ccs, p, err := CompileWithProfile(&TestProfileCircuit{}, dir)
+	check(err)
+	if p.Total != ccs.GetNbConstraints() {
+		t.Fatalf("profile has %d constraints, expected %d", p.Total, ccs.GetNbConstraints())
+	}
+	sum := 0
+	for _, n := range p.Stacks {
+		sum += n
+	}
+	if sum != p.Total {
+		t.Errorf("stacks add up to %d, expected %d", sum, p.Total)
+	}
+
+	folded := p.Folded()
+	for _, stack := range []string{
+		"sdk.(*HostCircuit).Define;sdk.(*TestProfileCircuit).Define;sdk.Map;sdk.(*TestProfileCircuit).Define.func2;sdk.(*Uint248API).Div;",
+		"sdk.(*HostCircuit).Define;sdk.(*TestProfileCircuit).Define;sdk.(*Uint248API).IsLessThan;",
+		"sdk.(*HostCircuit).Define;sdk.(*HostCircuit).commitInput;",
+		// range checks are added by the deferred callbacks of gnark
+		"frontend.callDeferred;",
+	} {
+		if !strings.Contains(folded, stack) {
+			t.Errorf("expected stack %s", stack)
+		}
+	}
+
+	summary := p.Summary(0)
+	t.Log(summary)
+	for _, row := range []string{"sdk.Map ", "sdk.(*Uint248API).Div ", "sdk.(*TestProfileCircuit).Define "} {
+		if !strings.Contains(summary, row) {
+			t.Errorf("expected summary row %s", row)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "constraints.folded"))
+	check(err)
+	if string(b) != folded {
+		t.Errorf("unexpected folded file %s", b)
+	}
+	_, err = os.Stat(filepath.Join(dir, "constraints.txt"))
+	check(err)
+	// the pprof profile of gnark is kept for go tool pprof
+	read, err := ReadConstraintProfile(filepath.Join(dir, constraintsPprofFile))
+	check(err)
+	if read.Total != p.Total || read.Folded() != folded {
+		t.Error("the pprof profile does not match the returned profile")
+	}
+}
+
+type TestProfileCircuit struct{}
+
+func (c *TestProfileCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 4, 0, 0
+}
+
+func (c *TestProfileCircuit) Define(api *CircuitAPI, in DataInput) error {
+	values := Map(NewDataStream(api, in.Receipts), func(r Receipt) Uint248 {
+		return api.ToUint248(r.Fields[0].Value)
+	})
+	quotients := Map(values, func(v Uint248) Uint248 {
+		q, _ := api.Uint248.Div(v, newU248(3))
+		return q
+	})
+	api.Uint248.AssertIsEqual(api.Uint248.IsLessThan(Sum(quotients), newU248(100)), newU248(1))
+	return nil
+}
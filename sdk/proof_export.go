@@ -0,0 +1,481 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/brevis-network/brevis-sdk/sdk/proto/commonproto"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+// ProofFormat is a format a PLONK proof and its public witness are exported to
+type ProofFormat string
+
+const (
+	// ProofFormatGnark is the binary encoding of gnark, i.e. proof.WriteTo
+	// followed by publicWitness.WriteTo. The proof part is what SubmitProof
+	// sends to the gateway.
+	ProofFormatGnark ProofFormat = "gnark"
+	// ProofFormatSolidity is the ABI encoded calldata of
+	// Verify(bytes proof, uint256[] public_inputs) of the verifier contract
+	// exported by gnark. Note that the Solidity verifier derives the challenges
+	// with SHA-256, so it only accepts proofs generated with gnark's default
+	// prover options, not the recursion friendly options of Prove.
+	ProofFormatSolidity ProofFormat = "solidity"
+	// ProofFormatJSON is a JSON object with every element of the proof and the
+	// public inputs as 0x-prefixed big-endian hex strings
+	ProofFormatJSON ProofFormat = "json"
+	// ProofFormatTypeScript is a TypeScript module that exports the JSON format
+	// as a typed constant
+	ProofFormatTypeScript ProofFormat = "ts"
+)
+
+// solidityVerifierABI is the ABI of the Verify function of the Solidity
+// verifier exported by gnark
+const solidityVerifierABI = `[{"type":"function","name":"Verify","stateMutability":"view",
+"inputs":[{"name":"proof","type":"bytes"},{"name":"public_inputs","type":"uint256[]"}],
+"outputs":[{"name":"success","type":"bool"}]}]`
+
+// ExportedProof is a PLONK proof of BN254 along with the public witness it
+// proves, which can be exported to and imported from the formats of ProofFormat
+type ExportedProof struct {
+	Proof         plonk.Proof
+	PublicWitness witness.Witness
+}
+
+// Export encodes the proof and its public witness in the format
+func (p *ExportedProof) Export(format ProofFormat) ([]byte, error) {
+	proof, ok := p.Proof.(*plonk_bn254.Proof)
+	if !ok {
+		return nil, fmt.Errorf("only proofs of BN254 can be exported")
+	}
+	switch format {
+	case ProofFormatGnark:
+		var buf bytes.Buffer
+		if _, err := proof.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+		if _, err := p.PublicWitness.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case ProofFormatSolidity:
+		return p.SolidityCalldata()
+	case ProofFormatJSON:
+		j, err := p.toJSON()
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(j, "", "  ")
+	case ProofFormatTypeScript:
+		j, err := p.toJSON()
+		if err != nil {
+			return nil, err
+		}
+		b, err := json.MarshalIndent(j, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return []byte(typeScriptHeader + typeScriptExport + string(b) + ";\n"), nil
+	}
+	return nil, fmt.Errorf("unknown proof format %q", format)
+}
+
+// ImportProof decodes a proof and its public witness exported in the format
+func ImportProof(data []byte, format ProofFormat) (*ExportedProof, error) {
+	switch format {
+	case ProofFormatGnark:
+		r := bytes.NewReader(data)
+		proof := plonk.NewProof(ecc.BN254)
+		if _, err := proof.ReadFrom(r); err != nil {
+			return nil, fmt.Errorf("invalid proof: %s", err.Error())
+		}
+		wpub, err := witness.New(ecc.BN254.ScalarField())
+		if err != nil {
+			return nil, err
+		}
+		if _, err = wpub.ReadFrom(r); err != nil {
+			return nil, fmt.Errorf("invalid public witness: %s", err.Error())
+		}
+		return &ExportedProof{Proof: proof, PublicWitness: wpub}, nil
+	case ProofFormatSolidity:
+		return ImportSolidityCalldata(data)
+	case ProofFormatJSON:
+		return importProofJSON(data)
+	case ProofFormatTypeScript:
+		s := string(data)
+		i := strings.Index(s, typeScriptExport)
+		if i < 0 {
+			return nil, fmt.Errorf("no exported proof found in TypeScript module")
+		}
+		// the decoder stops at the end of the object literal
+		j := &proofJSON{}
+		if err := json.NewDecoder(strings.NewReader(s[i+len(typeScriptExport):])).Decode(j); err != nil {
+			return nil, fmt.Errorf("invalid exported proof: %s", err.Error())
+		}
+		return j.toProof()
+	}
+	return nil, fmt.Errorf("unknown proof format %q", format)
+}
+
+// ImportServiceProof decodes a proof returned by the prover service, along
+// with the public witness in its app circuit info, e.g. to verify it locally
+// with the vk in the app circuit info (see Verify) or to export it
+func ImportServiceProof(proof string, info *commonproto.AppCircuitInfo) (*ExportedProof, error) {
+	proofBytes, err := hexutil.Decode(proof)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proof: %s", err.Error())
+	}
+	witnessBytes, err := hexutil.Decode(info.GetWitness())
+	if err != nil {
+		return nil, fmt.Errorf("invalid witness in app circuit info: %s", err.Error())
+	}
+	return ImportProof(append(proofBytes, witnessBytes...), ProofFormatGnark)
+}
+
+// Verify verifies the proof with the vk, like the package level Verify
+func (p *ExportedProof) Verify(vk plonk.VerifyingKey) error {
+	return Verify(vk, p.PublicWitness, p.Proof)
+}
+
+// PublicInputs returns the public inputs of the proof, in the order the
+// verifiers take them
+func (p *ExportedProof) PublicInputs() ([]*big.Int, error) {
+	v, ok := p.PublicWitness.Vector().(fr.Vector)
+	if !ok {
+		return nil, fmt.Errorf("public witness is not of BN254")
+	}
+	inputs := make([]*big.Int, len(v))
+	for i := range v {
+		inputs[i] = v[i].BigInt(new(big.Int))
+	}
+	return inputs, nil
+}
+
+// SolidityCalldata returns the calldata that calls the Verify function of the
+// Solidity verifier exported by gnark with the proof, see ProofFormatSolidity
+func (p *ExportedProof) SolidityCalldata() ([]byte, error) {
+	proof, ok := p.Proof.(*plonk_bn254.Proof)
+	if !ok {
+		return nil, fmt.Errorf("only proofs of BN254 can be exported")
+	}
+	inputs, err := p.PublicInputs()
+	if err != nil {
+		return nil, err
+	}
+	verifierABI, err := abi.JSON(strings.NewReader(solidityVerifierABI))
+	if err != nil {
+		return nil, err
+	}
+	return verifierABI.Pack("Verify", proof.MarshalSolidity(), inputs)
+}
+
+// ImportSolidityCalldata decodes the calldata of a call to the Verify function
+// of the Solidity verifier exported by gnark
+func ImportSolidityCalldata(calldata []byte) (*ExportedProof, error) {
+	verifierABI, err := abi.JSON(strings.NewReader(solidityVerifierABI))
+	if err != nil {
+		return nil, err
+	}
+	method := verifierABI.Methods["Verify"]
+	if len(calldata) < 4 || !bytes.Equal(calldata[:4], method.ID) {
+		return nil, fmt.Errorf("calldata is not a call to Verify(bytes,uint256[])")
+	}
+	args, err := method.Inputs.Unpack(calldata[4:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid calldata: %s", err.Error())
+	}
+	proof, err := ParseSolidityProof(args[0].([]byte))
+	if err != nil {
+		return nil, err
+	}
+	wpub, err := publicWitnessOf(args[1].([]*big.Int))
+	if err != nil {
+		return nil, err
+	}
+	return &ExportedProof{Proof: proof, PublicWitness: wpub}, nil
+}
+
+// ParseSolidityProof decodes a proof encoded by MarshalSolidity of gnark, i.e.
+// the proof argument of the Solidity verifier
+func ParseSolidityProof(b []byte) (plonk.Proof, error) {
+	// the layout of MarshalSolidity: 9 points and 8 scalars, then a scalar and a
+	// point per BSB22 commitment
+	const fixedSize = 9*bn254.SizeOfG1AffineUncompressed + 8*fr.Bytes
+	const commitmentSize = fr.Bytes + bn254.SizeOfG1AffineUncompressed
+	if len(b) < fixedSize || (len(b)-fixedSize)%commitmentSize != 0 {
+		return nil, fmt.Errorf("invalid solidity proof length %d", len(b))
+	}
+	nbCommitments := (len(b) - fixedSize) / commitmentSize
+	d := &solidityDecoder{b: b}
+	proof := &plonk_bn254.Proof{}
+	proof.BatchedProof.ClaimedValues = make([]fr.Element, 7+nbCommitments)
+	proof.Bsb22Commitments = make([]kzg_bn254.Digest, nbCommitments)
+	for i := range proof.LRO {
+		d.point(&proof.LRO[i])
+	}
+	for i := range proof.H {
+		d.point(&proof.H[i])
+	}
+	for i := 2; i < 7; i++ {
+		d.scalar(&proof.BatchedProof.ClaimedValues[i])
+	}
+	d.point(&proof.Z)
+	d.scalar(&proof.ZShiftedOpening.ClaimedValue)
+	d.scalar(&proof.BatchedProof.ClaimedValues[0])
+	d.scalar(&proof.BatchedProof.ClaimedValues[1])
+	d.point(&proof.BatchedProof.H)
+	d.point(&proof.ZShiftedOpening.H)
+	for i := 0; i < nbCommitments; i++ {
+		d.scalar(&proof.BatchedProof.ClaimedValues[7+i])
+	}
+	for i := range proof.Bsb22Commitments {
+		d.point(&proof.Bsb22Commitments[i])
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid solidity proof: %s", d.err.Error())
+	}
+	return proof, nil
+}
+
+type solidityDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *solidityDecoder) point(p *bn254.G1Affine) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = p.SetBytes(d.b[:bn254.SizeOfG1AffineUncompressed])
+	d.b = d.b[bn254.SizeOfG1AffineUncompressed:]
+}
+
+func (d *solidityDecoder) scalar(e *fr.Element) {
+	if d.err != nil {
+		return
+	}
+	d.err = e.SetBytesCanonical(d.b[:fr.Bytes])
+	d.b = d.b[fr.Bytes:]
+}
+
+func publicWitnessOf(inputs []*big.Int) (witness.Witness, error) {
+	wpub, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	values := make(chan any, len(inputs))
+	for _, v := range inputs {
+		if v.Sign() < 0 || v.Cmp(ecc.BN254.ScalarField()) >= 0 {
+			return nil, fmt.Errorf("public input %s is not in the scalar field", v)
+		}
+		values <- v
+	}
+	close(values)
+	if err = wpub.Fill(len(inputs), 0, values); err != nil {
+		return nil, err
+	}
+	return wpub, nil
+}
+
+const typeScriptHeader = `// Code generated by brevis-sdk. DO NOT EDIT.
+//
+// A PLONK proof of BN254 and its public inputs. The numbers are 0x-prefixed
+// big-endian hex strings, which BigInt() converts. solidity_proof is the proof
+// argument of Verify(bytes proof, uint256[] public_inputs) of the Solidity
+// verifier exported by gnark.
+
+export interface G1Point {
+  x: string;
+  y: string;
+}
+
+export interface PlonkProof {
+  lro: [G1Point, G1Point, G1Point];
+  z: G1Point;
+  h: [G1Point, G1Point, G1Point];
+  bsb22_commitments: G1Point[];
+  batched_proof: { h: G1Point; claimed_values: string[] };
+  z_shifted_opening: { h: G1Point; claimed_value: string };
+}
+
+export interface ExportedProof {
+  curve: "bn254";
+  backend: "plonk";
+  proof: PlonkProof;
+  public_inputs: string[];
+  solidity_proof: string;
+}
+
+`
+
+const typeScriptExport = "export const exportedProof: ExportedProof = "
+
+type g1JSON struct {
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+type proofJSON struct {
+	Curve   string `json:"curve"`
+	Backend string `json:"backend"`
+	Proof   struct {
+		LRO              [3]g1JSON `json:"lro"`
+		Z                g1JSON    `json:"z"`
+		H                [3]g1JSON `json:"h"`
+		Bsb22Commitments []g1JSON  `json:"bsb22_commitments"`
+		BatchedProof     struct {
+			H             g1JSON   `json:"h"`
+			ClaimedValues []string `json:"claimed_values"`
+		} `json:"batched_proof"`
+		ZShiftedOpening struct {
+			H            g1JSON `json:"h"`
+			ClaimedValue string `json:"claimed_value"`
+		} `json:"z_shifted_opening"`
+	} `json:"proof"`
+	PublicInputs  []string `json:"public_inputs"`
+	SolidityProof string   `json:"solidity_proof"`
+}
+
+func (p *ExportedProof) toJSON() (*proofJSON, error) {
+	proof := p.Proof.(*plonk_bn254.Proof)
+	inputs, err := p.PublicInputs()
+	if err != nil {
+		return nil, err
+	}
+	j := &proofJSON{Curve: "bn254", Backend: "plonk"}
+	for i := range proof.LRO {
+		j.Proof.LRO[i] = g1ToJSON(&proof.LRO[i])
+	}
+	j.Proof.Z = g1ToJSON(&proof.Z)
+	for i := range proof.H {
+		j.Proof.H[i] = g1ToJSON(&proof.H[i])
+	}
+	j.Proof.Bsb22Commitments = make([]g1JSON, len(proof.Bsb22Commitments))
+	for i := range proof.Bsb22Commitments {
+		j.Proof.Bsb22Commitments[i] = g1ToJSON(&proof.Bsb22Commitments[i])
+	}
+	j.Proof.BatchedProof.H = g1ToJSON(&proof.BatchedProof.H)
+	j.Proof.BatchedProof.ClaimedValues = make([]string, len(proof.BatchedProof.ClaimedValues))
+	for i := range proof.BatchedProof.ClaimedValues {
+		b := proof.BatchedProof.ClaimedValues[i].Bytes()
+		j.Proof.BatchedProof.ClaimedValues[i] = hexutil.Encode(b[:])
+	}
+	j.Proof.ZShiftedOpening.H = g1ToJSON(&proof.ZShiftedOpening.H)
+	b := proof.ZShiftedOpening.ClaimedValue.Bytes()
+	j.Proof.ZShiftedOpening.ClaimedValue = hexutil.Encode(b[:])
+	j.PublicInputs = make([]string, len(inputs))
+	for i, v := range inputs {
+		j.PublicInputs[i] = hexutil.Encode(v.FillBytes(make([]byte, fr.Bytes)))
+	}
+	j.SolidityProof = hexutil.Encode(proof.MarshalSolidity())
+	return j, nil
+}
+
+func importProofJSON(data []byte) (*ExportedProof, error) {
+	j := &proofJSON{}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("invalid exported proof: %s", err.Error())
+	}
+	return j.toProof()
+}
+
+func (j *proofJSON) toProof() (*ExportedProof, error) {
+	if j.Curve != "bn254" || j.Backend != "plonk" {
+		return nil, fmt.Errorf("exported proof is a %s proof of %s, only plonk proofs of bn254 are supported", j.Backend, j.Curve)
+	}
+	d := &jsonDecoder{}
+	proof := &plonk_bn254.Proof{}
+	for i := range proof.LRO {
+		d.point(&proof.LRO[i], j.Proof.LRO[i])
+	}
+	d.point(&proof.Z, j.Proof.Z)
+	for i := range proof.H {
+		d.point(&proof.H[i], j.Proof.H[i])
+	}
+	proof.Bsb22Commitments = make([]kzg_bn254.Digest, len(j.Proof.Bsb22Commitments))
+	for i := range proof.Bsb22Commitments {
+		d.point(&proof.Bsb22Commitments[i], j.Proof.Bsb22Commitments[i])
+	}
+	d.point(&proof.BatchedProof.H, j.Proof.BatchedProof.H)
+	proof.BatchedProof.ClaimedValues = make([]fr.Element, len(j.Proof.BatchedProof.ClaimedValues))
+	for i := range proof.BatchedProof.ClaimedValues {
+		d.scalar(&proof.BatchedProof.ClaimedValues[i], j.Proof.BatchedProof.ClaimedValues[i])
+	}
+	d.point(&proof.ZShiftedOpening.H, j.Proof.ZShiftedOpening.H)
+	d.scalar(&proof.ZShiftedOpening.ClaimedValue, j.Proof.ZShiftedOpening.ClaimedValue)
+	inputs := make([]*big.Int, len(j.PublicInputs))
+	for i, s := range j.PublicInputs {
+		var e fr.Element
+		d.scalar(&e, s)
+		inputs[i] = e.BigInt(new(big.Int))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid exported proof: %s", d.err.Error())
+	}
+	wpub, err := publicWitnessOf(inputs)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportedProof{Proof: proof, PublicWitness: wpub}, nil
+}
+
+func g1ToJSON(p *bn254.G1Affine) g1JSON {
+	x, y := p.X.Bytes(), p.Y.Bytes()
+	return g1JSON{X: hexutil.Encode(x[:]), Y: hexutil.Encode(y[:])}
+}
+
+type jsonDecoder struct {
+	err error
+}
+
+func (d *jsonDecoder) point(p *bn254.G1Affine, j g1JSON) {
+	d.field(&p.X, j.X)
+	d.field(&p.Y, j.Y)
+	if d.err == nil && !p.IsInfinity() && (!p.IsOnCurve() || !p.IsInSubGroup()) {
+		d.err = fmt.Errorf("point (%s, %s) is not on the curve", j.X, j.Y)
+	}
+}
+
+func (d *jsonDecoder) field(e *fp.Element, s string) {
+	b := d.bytes(s, fp.Bytes)
+	if d.err == nil {
+		d.err = e.SetBytesCanonical(b)
+	}
+}
+
+func (d *jsonDecoder) scalar(e *fr.Element, s string) {
+	b := d.bytes(s, fr.Bytes)
+	if d.err == nil {
+		d.err = e.SetBytesCanonical(b)
+	}
+}
+
+func (d *jsonDecoder) bytes(s string, size int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	b, err := hexutil.Decode(s)
+	if err != nil {
+		d.err = fmt.Errorf("invalid number %q: %s", s, err.Error())
+		return nil
+	}
+	if len(b) > size {
+		d.err = fmt.Errorf("number %s is longer than %d bytes", s, size)
+		return nil
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk/proto/commonproto"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/frontend"
+
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+)
+
+func TestProofExport(t *testing.T) {
+	ccs, pk, vk := testPkSetup(&exportCircuit{})
+	w, err := frontend.NewWitness(&exportCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	check(err)
+	wpub, err := w.Public()
+	check(err)
+
+	// proofs generated with the options of Prove, as the prover service does
+	proof, err := Prove(ccs, pk, w)
+	check(err)
+	if len(proof.(*plonk_bn254.Proof).Bsb22Commitments) != 1 {
+		t.Fatal("the proof should have a commitment")
+	}
+	exported := &ExportedProof{Proof: proof, PublicWitness: wpub}
+	for _, format := range []ProofFormat{ProofFormatGnark, ProofFormatSolidity, ProofFormatJSON, ProofFormatTypeScript} {
+		b, err := exported.Export(format)
+		check(err)
+		imported, err := ImportProof(b, format)
+		check(err)
+		if err = imported.Verify(vk); err != nil {
+			t.Fatalf("imported %s proof does not verify: %s", format, err.Error())
+		}
+		b2, err := imported.Export(format)
+		check(err)
+		if !bytes.Equal(b, b2) {
+			t.Fatalf("%s export is not stable", format)
+		}
+	}
+
+	// proofs returned by the prover service
+	var proofBuf, witnessBuf bytes.Buffer
+	_, err = proof.WriteTo(&proofBuf)
+	check(err)
+	_, err = wpub.WriteTo(&witnessBuf)
+	check(err)
+	info := &commonproto.AppCircuitInfo{Witness: "0x" + hex.EncodeToString(witnessBuf.Bytes())}
+	imported, err := ImportServiceProof("0x"+hex.EncodeToString(proofBuf.Bytes()), info)
+	check(err)
+	check(imported.Verify(vk))
+	inputs, err := imported.PublicInputs()
+	check(err)
+	if len(inputs) != 1 || inputs[0].Int64() != 9 {
+		t.Fatalf("unexpected public inputs %v", inputs)
+	}
+
+	// proofs for the Solidity verifier use the default options of gnark
+	proof, err = plonk.Prove(ccs, pk, w)
+	check(err)
+	b, err := (&ExportedProof{Proof: proof, PublicWitness: wpub}).SolidityCalldata()
+	check(err)
+	imported, err = ImportProof(b, ProofFormatSolidity)
+	check(err)
+	check(plonk.Verify(imported.Proof, vk, imported.PublicWitness))
+
+	// invalid encodings
+	b[len(b)-1] ^= 1
+	if _, err = ImportProof(b[:len(b)-32], ProofFormatSolidity); err == nil {
+		t.Fatal("expected an error for truncated calldata")
+	}
+	if _, err = ImportProof([]byte(`{"curve":"bls12-381","backend":"plonk"}`), ProofFormatJSON); err == nil {
+		t.Fatal("expected an error for another curve")
+	}
+	if _, err = ImportProof([]byte("export const x = 1;"), ProofFormatTypeScript); err == nil {
+		t.Fatal("expected an error for a module without a proof")
+	}
+}
+
+type exportCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *exportCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	// a commitment, so that the proof has a BSB22 commitment
+	commitment, err := api.(frontend.Committer).Commit(c.X)
+	if err != nil {
+		return err
+	}
+	api.AssertIsDifferent(commitment, 0)
+	return nil
+}
@@ -1,6 +1,14 @@
 package prover
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
+	"github.com/consensys/gnark/constraint"
+)
 
 type ServiceConfig struct {
 	// ProverId is a unique identifier for this prover, defaults to hostname
@@ -12,16 +20,58 @@ type ServiceConfig struct {
 
 	// SrsDir saves the SRS files that will be automatically downloaded. These files
 	// can be shared across different circuits. So the best practice is to have them
-	// in a shared directory for all projects. Default to use the same dir as
-	// SetupDir if not specified
+	// in a shared directory for all projects. The SRS trimmed to the size of each
+	// circuit is cached there too. Default to use the same dir as SetupDir if not
+	// specified
 	SrsDir string `mapstructure:"srs_dir" json:"srs_dir"`
 
+	// SrsSource is where the SRS is loaded from: "http" downloads it from SrsUrl
+	// to SrsDir, and "file" reads it from SrsPath, e.g. a local mirror or a trimmed
+	// SRS. Defaults to downloading the ignition SRS from the Brevis mirror to
+	// SrsDir.
+	SrsSource string `mapstructure:"srs_source" json:"srs_source"`
+
+	// SrsUrl is the URL of the SRS with the "http" source
+	SrsUrl string `mapstructure:"srs_url" json:"srs_url"`
+
+	// SrsPath is the SRS file with the "file" source
+	SrsPath string `mapstructure:"srs_path" json:"srs_path"`
+
+	// SrsSha256 is the hex sha256 checksum of the SRS file. Required with the
+	// "http" source, optional with the "file" source.
+	SrsSha256 string `mapstructure:"srs_sha256" json:"srs_sha256"`
+
+	// SrsProvider overrides the SRS source settings above, e.g. with an
+	// srs.MemoryProvider. It can only be set in code, and the SRS it provides is
+	// not trimmed.
+	SrsProvider srs.Provider `mapstructure:"-" json:"-"`
+
 	// GatewayUrl is the Brevis gateway URL, defaults to appsdkv3.brevis.network:443
 	GatewayUrl string `mapstructure:"gateway_url" json:"gateway_url"`
 
 	// DirectLoad indicates whether the setup process will parallel compile ccs and load pk vk, without digest check
 	DirectLoad bool `mapstructure:"direct_load" json:"direct_load"`
 
+	// MmapPk maps the proving key from a pk dump next to it instead of reading it
+	// into memory, see sdk.MmapSetupPk. This lowers the startup time and the
+	// resident memory of the service, at the cost of the disk space of the dump,
+	// which is about twice the size of the proving key.
+	MmapPk bool `mapstructure:"mmap_pk" json:"mmap_pk"`
+
+	// CompileCacheDir caches the compiled circuit under the fingerprint of the app
+	// circuit, see sdk.CompileCached, so that the service does not compile the
+	// circuit again on every start. Defaults to {SetupDir}/compile_cache.
+	//
+	// The fingerprint includes the digest of the service executable, because the
+	// code of Define cannot be inspected. This never reuses a circuit compiled
+	// from other code, but any rebuild of the service compiles the circuit again
+	// on its first start, even if the circuit has not changed. The log tells why
+	// the cache missed.
+	CompileCacheDir string `mapstructure:"compile_cache_dir" json:"compile_cache_dir"`
+
+	// DisableCompileCache compiles the circuit on every start
+	DisableCompileCache bool `mapstructure:"disable_compile_cache" json:"disable_compile_cache"`
+
 	// ProofPersistenceType currently supporting "syncmap", "file", "badgerdb" and "s3".
 	// Defaults to "syncmap".
 	ProofPersistenceType string `mapstructure:"proof_persistence_type" json:"proof_persistence_type"`
@@ -41,12 +91,33 @@ type ServiceConfig struct {
 
 	// ConcurrentProveLimit limits the number of concurrent prove actions, defaults to 1
 	ConcurrentProveLimit int `mapstructure:"concurrent_prove_limit" json:"concurrent_prove_limit"`
+
+	// ProveForSolidity proves with the default options of gnark, see
+	// sdk.ProveForSolidity, so that the proofs verify on the Solidity verifier of
+	// sdk.ExportSolidityVerifier, e.g. to test an app contract without the Brevis
+	// gateway. The gateway does not accept these proofs.
+	ProveForSolidity bool `mapstructure:"prove_for_solidity" json:"prove_for_solidity"`
 }
 
 func (c ServiceConfig) GetSetupDir() string {
 	return os.ExpandEnv(c.SetupDir)
 }
 
+func (c ServiceConfig) GetCompileCacheDir() string {
+	if c.CompileCacheDir == "" {
+		return filepath.Join(c.GetSetupDir(), "compile_cache")
+	}
+	return os.ExpandEnv(c.CompileCacheDir)
+}
+
+// compile compiles the app circuit, or reads it from the compile cache
+func (c ServiceConfig) compile(circuit sdk.AppCircuit) (constraint.ConstraintSystem, error) {
+	if c.DisableCompileCache {
+		return sdk.CompileOnly(circuit)
+	}
+	return sdk.CompileCached(circuit, c.GetCompileCacheDir())
+}
+
 func (c ServiceConfig) GetSrsDir() string {
 	if len(c.SrsDir) == 0 {
 		return c.GetSetupDir()
@@ -54,6 +125,33 @@ func (c ServiceConfig) GetSrsDir() string {
 	return os.ExpandEnv(c.SrsDir)
 }
 
+// GetSrsProvider returns the provider of the SRS used to set up circuits. The
+// SRS trimmed to the size of each circuit is cached in SrsDir, see
+// srs.TrimmedCache.
+func (c ServiceConfig) GetSrsProvider() (srs.Provider, error) {
+	if c.SrsProvider != nil {
+		return c.SrsProvider, nil
+	}
+	var source srs.Provider
+	switch c.SrsSource {
+	case "":
+		source = srs.DefaultProvider(c.GetSrsDir())
+	case "http":
+		if c.SrsUrl == "" || c.SrsSha256 == "" {
+			return nil, fmt.Errorf("srs_url and srs_sha256 are required with the http srs source")
+		}
+		source = &srs.HTTPProvider{URL: c.SrsUrl, CacheDir: c.GetSrsDir(), Sha256: c.SrsSha256}
+	case "file":
+		if c.SrsPath == "" {
+			return nil, fmt.Errorf("srs_path is required with the file srs source")
+		}
+		source = &srs.FileProvider{Path: os.ExpandEnv(c.SrsPath), Sha256: c.SrsSha256}
+	default:
+		return nil, fmt.Errorf("unknown srs source %s, expected http or file", c.SrsSource)
+	}
+	return &srs.TrimmedCache{Dir: c.GetSrsDir(), Source: source}, nil
+}
+
 type SourceChainConfig struct {
 	// ChainId the chain ID
 	ChainId uint64 `mapstructure:"chain_id" json:"chain_id"`
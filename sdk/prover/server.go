@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	goruntime "runtime"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -35,7 +36,6 @@ import (
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/backend/witness"
-	"github.com/consensys/gnark/constraint"
 	"github.com/philippgille/gokv"
 	"google.golang.org/grpc"
 )
@@ -62,6 +62,9 @@ type ProveRequest struct {
 	Proof          string `json:"proof"`
 	AppCircuitInfo []byte `json:"app_circuit_info"`
 	Err            string `json:"err"`
+	// VkHash is the vk hash of the circuit tier the proof is for, which keys
+	// the proof job
+	VkHash string `json:"vk_hash"`
 }
 
 type server struct {
@@ -75,12 +78,14 @@ type server struct {
 	// Placeholder for common dependencies shared by all BrevisApp instances
 	appTemplates map[uint64]*sdk.BrevisApp
 
-	pk  plonk.ProvingKey
-	vk  plonk.VerifyingKey
-	ccs constraint.ConstraintSystem
-
-	vkString string
-	vkHash   string
+	// the allocation tiers of appCircuit from the smallest to the largest, or
+	// appCircuit alone if it has no tiers
+	tiers []*circuitTier
+	// setupId identifies the setup in proof IDs, which are computed before the
+	// tier of the request is known: the vk hash of the circuit, or the vk hashes
+	// of all tiers joined by commas. The proof jobs are kept per tier, keyed by
+	// the vk hash of the tier
+	setupId string
 
 	grpcServer *grpc.Server
 
@@ -91,6 +96,9 @@ type server struct {
 
 	// rate limiter to ensure only a limited number of prove actions can run at a time
 	proveRateLimiter chan struct{}
+
+	// proveForSolidity proves with sdk.ProveForSolidity instead of sdk.Prove
+	proveForSolidity bool
 }
 
 // NewService creates a new prover server instance that automatically manages
@@ -185,23 +193,13 @@ func newServer(appCircuit sdk.AppCircuit, config ServiceConfig, srcChainConfigs
 	if err != nil {
 		return nil, fmt.Errorf("NewBrevisHashInfo err: %w", err)
 	}
-	var pk plonk.ProvingKey
-	var vk plonk.VerifyingKey
-	var ccs constraint.ConstraintSystem
-	var vkHash []byte
-	if config.DirectLoad {
-		pk, vk, ccs, vkHash, err = readOnly(appCircuit, config.GetSetupDir(), hashInfo)
-	} else {
-		pk, vk, ccs, vkHash, err = readOrSetup(appCircuit, config.GetSetupDir(), config.GetSrsDir(), hashInfo)
-	}
+	tiers, err := loadTiers(appCircuit, config, hashInfo)
 	if err != nil {
-		return nil, fmt.Errorf("readOrSetup err: %w", err)
+		return nil, fmt.Errorf("loadTiers err: %w", err)
 	}
-
-	var buf bytes.Buffer
-	_, err = vk.WriteRawTo(&buf)
-	if err != nil {
-		return nil, fmt.Errorf("vk.WriteTo err: %w", err)
+	vkHashes := make([]string, len(tiers))
+	for i, tier := range tiers {
+		vkHashes[i] = tier.vkHash
 	}
 	persistenceType := config.ProofPersistenceType
 	if persistenceType == "" {
@@ -241,15 +239,13 @@ func newServer(appCircuit sdk.AppCircuit, config ServiceConfig, srcChainConfigs
 		proverId:               proverId,
 		appCircuit:             appCircuit,
 		appTemplates:           appTemplates,
-		pk:                     pk,
-		vk:                     vk,
-		ccs:                    ccs,
-		vkString:               hexutil.Encode(buf.Bytes()),
-		vkHash:                 hexutil.Encode(vkHash),
+		tiers:                  tiers,
+		setupId:                strings.Join(vkHashes, ","),
 		proofStore:             proofStore,
 		proveAsyncSingleFlight: singleflight.Group{},
 		jobsLock:               sync.Mutex{},
 		proveRateLimiter:       make(chan struct{}, concurrentProveLimit),
+		proveForSolidity:       config.ProveForSolidity,
 	}, nil
 }
 
@@ -277,19 +273,24 @@ func (s *server) Prove(ctx context.Context, req *sdkproto.ProveRequest) (*sdkpro
 		return errRes(protoErr)
 	}
 
+	tier, err := s.tierOf(input)
+	if err != nil {
+		return errRes(newErr(sdkproto.ErrCode_ERROR_INVALID_INPUT, "failed to find circuit tier: %s", err.Error()))
+	}
+
 	witness, _, err := s.genWitness(input, guest)
 	if err != nil {
 		return errRes(newErr(sdkproto.ErrCode_ERROR_INVALID_INPUT, "failed to generate witness: %s", err.Error()))
 	}
 
-	proof, err := s.prove(witness)
+	proof, err := s.prove(tier, witness)
 	if err != nil {
 		return errRes(newErr(sdkproto.ErrCode_ERROR_FAILED_TO_PROVE, "failed to prove: %s", err.Error()))
 	}
 
 	return &sdkproto.ProveResponse{
 		Proof:       proof,
-		CircuitInfo: buildFullAppCircuitInfo(s.appCircuit, *input, s.vkString, s.vkHash, witnessStr),
+		CircuitInfo: buildFullAppCircuitInfo(tier.circuit, *input, tier.vkString, tier.vkHash, witnessStr),
 	}, nil
 }
 
@@ -337,7 +338,11 @@ func (s *server) ProveAsync(ctx context.Context, req *sdkproto.ProveRequest) (*s
 		if err != nil {
 			return errRes(newErr(sdkproto.ErrCode_ERROR_DEFAULT, "failed to build circuit input stage 1: %s", err.Error()))
 		}
-		appCircuitInfo := buildPartialAppCircuitInfoForGatewayRequest(s.appCircuit, inputStage1, s.vkHash)
+		tier, err := s.tierOf(inputStage1)
+		if err != nil {
+			return errRes(newErr(sdkproto.ErrCode_ERROR_DEFAULT, "failed to find circuit tier: %s", err.Error()))
+		}
+		appCircuitInfo := buildPartialAppCircuitInfoForGatewayRequest(tier.circuit, inputStage1, tier.vkHash)
 		resp.CircuitInfo = appCircuitInfo
 
 		requestBytes, err := proto.Marshal(req)
@@ -357,12 +362,13 @@ func (s *server) ProveAsync(ctx context.Context, req *sdkproto.ProveRequest) (*s
 			SrcChainId:     req.SrcChainId,
 			Request:        requestBytes,
 			AppCircuitInfo: appCircuitInfoBytes,
+			VkHash:         tier.vkHash,
 		}
 		err = s.setProveRequest(proofId, proveRequest)
 		if err != nil {
 			return errRes(newErr(sdkproto.ErrCode_ERROR_DEFAULT, "proof ID %s, failed to save proof request: %s", proofId, err.Error()))
 		}
-		err = s.addJob(proofId)
+		err = s.addJob(proofId, tier.vkHash)
 		if err != nil {
 			return errRes(newErr(sdkproto.ErrCode_ERROR_DEFAULT, "proof ID %s, failed to add proof job: %s", proofId, err.Error()))
 		}
@@ -528,9 +534,15 @@ func (s *server) genWitness(input *sdk.CircuitInput, guest sdk.AppCircuit) (witn
 	return witness, publicWitness, nil
 }
 
-func (s *server) prove(witness witness.Witness) (string, error) {
+func (s *server) prove(tier *circuitTier, witness witness.Witness) (string, error) {
 	s.proveRateLimiter <- struct{}{}
-	proof, err := sdk.Prove(s.ccs, s.pk, witness)
+	var proof plonk.Proof
+	var err error
+	if s.proveForSolidity {
+		proof, err = sdk.ProveForSolidity(tier.ccs, tier.pk, witness)
+	} else {
+		proof, err = sdk.Prove(tier.ccs, tier.pk, witness)
+	}
 	goruntime.GC()
 	<-s.proveRateLimiter
 	if err != nil {
@@ -572,6 +584,11 @@ func (s *server) buildInputStage2AndProve(proofId string, brevisApp *sdk.BrevisA
 		s.markProofFailed(proofId, proveRequest, err)
 		return
 	}
+	tier, err := s.tierOf(input)
+	if err != nil {
+		s.markProofFailed(proofId, proveRequest, err)
+		return
+	}
 	witness, _, err := s.genWitness(input, guest)
 	if err != nil {
 		s.markProofFailed(proofId, proveRequest, err)
@@ -583,7 +600,7 @@ func (s *server) buildInputStage2AndProve(proofId string, brevisApp *sdk.BrevisA
 		return
 	}
 	proveRequest.Witness = witnessBytes
-	appCircuitInfo := buildFullAppCircuitInfo(s.appCircuit, *input, s.vkString, s.vkHash, witnessStr)
+	appCircuitInfo := buildFullAppCircuitInfo(tier.circuit, *input, tier.vkString, tier.vkHash, witnessStr)
 	appCircuitInfoBytes, err := proto.Marshal(appCircuitInfo)
 	if err != nil {
 		s.markProofFailed(proofId, proveRequest, err)
@@ -596,7 +613,7 @@ func (s *server) buildInputStage2AndProve(proofId string, brevisApp *sdk.BrevisA
 	if setProofErr != nil {
 		log.Errorln("failed to set proof:", setProofErr.Error())
 	}
-	s.doProveHelper(proofId, proveRequest, witness)
+	s.doProveHelper(proofId, proveRequest, tier, witness)
 }
 
 func (s *server) continueProve(proofId string, proveRequest *ProveRequest) {
@@ -612,6 +629,22 @@ func (s *server) continueProve(proofId string, proveRequest *ProveRequest) {
 		}
 	}()
 
+	appCircuitInfo := &commonproto.AppCircuitInfo{}
+	err = proto.Unmarshal(proveRequest.AppCircuitInfo, appCircuitInfo)
+	if err != nil {
+		s.markProofFailed(proofId, proveRequest, err)
+		return
+	}
+	tier, err := s.tierFor(sdk.Allocation{
+		MaxReceipts:     int(appCircuitInfo.MaxReceipts),
+		MaxStorage:      int(appCircuitInfo.MaxStorage),
+		MaxTransactions: int(appCircuitInfo.MaxTx),
+	})
+	if err != nil {
+		s.markProofFailed(proofId, proveRequest, err)
+		return
+	}
+
 	// Unmarshal witnesses
 	witness, err := witness.New(ecc.BN254.ScalarField())
 	if err != nil {
@@ -624,11 +657,11 @@ func (s *server) continueProve(proofId string, proveRequest *ProveRequest) {
 		return
 	}
 
-	s.doProveHelper(proofId, proveRequest, witness)
+	s.doProveHelper(proofId, proveRequest, tier, witness)
 }
 
-func (s *server) doProveHelper(proofId string, proveRequest *ProveRequest, witness witness.Witness) {
-	proof, err := s.prove(witness)
+func (s *server) doProveHelper(proofId string, proveRequest *ProveRequest, tier *circuitTier, witness witness.Witness) {
+	proof, err := s.prove(tier, witness)
 	if err != nil {
 		s.markProofFailed(proofId, proveRequest, err)
 		return
@@ -639,13 +672,31 @@ func (s *server) doProveHelper(proofId string, proveRequest *ProveRequest, witne
 	if err != nil {
 		log.Errorln("failed to set proof:", err.Error())
 	}
-	err = s.removeJob(proofId)
+	err = s.removeJob(proofId, proveRequest.VkHash)
 	if err != nil {
 		log.Errorln("removeJob err:", err.Error())
 	}
 	log.Infof("prove success, proof ID: %s\n", proofId)
 }
 
+// tierOf returns the tier of the app circuit that the input is built for
+func (s *server) tierOf(in *sdk.CircuitInput) (*circuitTier, error) {
+	return s.tierFor(sdk.Allocation{
+		MaxReceipts:     len(in.Receipts.Raw),
+		MaxStorage:      len(in.StorageSlots.Raw),
+		MaxTransactions: len(in.Transactions.Raw),
+	})
+}
+
+func (s *server) tierFor(a sdk.Allocation) (*circuitTier, error) {
+	for _, tier := range s.tiers {
+		if tier.allocation == a {
+			return tier, nil
+		}
+	}
+	return nil, fmt.Errorf("no circuit tier with allocation %s (receipts/storage/transactions)", a)
+}
+
 func (s *server) newBrevisApp(srcChainId uint64) (*sdk.BrevisApp, error) {
 	appTemplate, ok := s.appTemplates[srcChainId]
 	if !ok {
@@ -659,8 +710,19 @@ func (s *server) newBrevisApp(srcChainId uint64) (*sdk.BrevisApp, error) {
 }
 
 func (s *server) resumeJobs() error {
+	for _, tier := range s.tiers {
+		err := s.resumeTierJobs(tier)
+		if err != nil {
+			return fmt.Errorf("tier %s: %w", tier.allocation, err)
+		}
+	}
+	return nil
+}
+
+// resumeTierJobs resumes the proof jobs of a circuit tier
+func (s *server) resumeTierJobs(tier *circuitTier) error {
 	var jobs []string
-	ok, err := s.proofStore.Get(s.getJobsKey(), &jobs)
+	ok, err := s.proofStore.Get(s.getJobsKey(tier.vkHash), &jobs)
 	if err != nil {
 		return fmt.Errorf("store.Get err: %w", err)
 	}
@@ -675,6 +737,10 @@ func (s *server) resumeJobs() error {
 		if !found {
 			return fmt.Errorf("proof request not found for proof ID: %s", proofId)
 		}
+		// requests stored before the jobs were kept per tier
+		if proveRequest.VkHash == "" {
+			proveRequest.VkHash = tier.vkHash
+		}
 		switch proveRequest.Status {
 		case ProveStatusInit:
 			requestProto := &sdkproto.ProveRequest{}
@@ -687,7 +753,7 @@ func (s *server) resumeJobs() error {
 			go s.continueProve(proofId, proveRequest)
 		case ProveStatusSuccess, ProveStatusFailed:
 			// Cleanup
-			err = s.removeJob(proofId)
+			err = s.removeJob(proofId, tier.vkHash)
 			if err != nil {
 				return fmt.Errorf("removeJob err: %w", err)
 			}
@@ -698,12 +764,12 @@ func (s *server) resumeJobs() error {
 	return nil
 }
 
-// addJob adds a proof job
-func (s *server) addJob(proofId string) error {
+// addJob adds a proof job to the jobs of the tier with the vk hash
+func (s *server) addJob(proofId, vkHash string) error {
 	s.jobsLock.Lock()
 	defer s.jobsLock.Unlock()
 
-	jobsKey := s.getJobsKey()
+	jobsKey := s.getJobsKey(vkHash)
 	var jobs []string
 	_, err := s.proofStore.Get(jobsKey, &jobs)
 	if err != nil {
@@ -717,12 +783,13 @@ func (s *server) addJob(proofId string) error {
 	return nil
 }
 
-// removeJob removes a proof job, no-op if nonexistent
-func (s *server) removeJob(proofId string) error {
+// removeJob removes a proof job from the jobs of the tier with the vk hash,
+// no-op if nonexistent
+func (s *server) removeJob(proofId, vkHash string) error {
 	s.jobsLock.Lock()
 	defer s.jobsLock.Unlock()
 
-	jobsKey := s.getJobsKey()
+	jobsKey := s.getJobsKey(vkHash)
 	var jobs []string
 	ok, err := s.proofStore.Get(jobsKey, &jobs)
 	if err != nil {
@@ -767,12 +834,19 @@ func (s *server) getProveRequest(id string) (bool, *ProveRequest, error) {
 func (s *server) deleteProveRequest(id string) error {
 	log.Debugf("delete prove request, ID: %s\n", id)
 
-	err := s.proofStore.Delete(id)
+	found, request, err := s.getProveRequest(id)
+	if err != nil {
+		return fmt.Errorf("getProveRequest err: %w", err)
+	}
+	if !found {
+		return nil
+	}
+	err = s.proofStore.Delete(id)
 	if err != nil {
 		return fmt.Errorf("store.Delete err: %w", err)
 	}
 
-	err = s.removeJob(id)
+	err = s.removeJob(id, request.VkHash)
 	if err != nil {
 		return fmt.Errorf("removeJob err: %w", err)
 	}
@@ -790,7 +864,7 @@ func (s *server) markProofFailed(proofId string, request *ProveRequest, err erro
 		log.Errorf("failed to set proof, err: %s, original err: %s", setProofErr.Error(), errStr)
 	}
 
-	removeJobErr := s.removeJob(proofId)
+	removeJobErr := s.removeJob(proofId, request.VkHash)
 	if removeJobErr != nil {
 		log.Errorf("removeJob err: %s", removeJobErr.Error())
 	}
@@ -805,9 +879,10 @@ func (s *server) getProofId(req *sdkproto.ProveRequest) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("jcs.Transform err: %w", err)
 	}
-	return crypto.Keccak256Hash(append([]byte(s.vkHash), canonJsonBytes...)).Hex(), nil
+	return crypto.Keccak256Hash(append([]byte(s.setupId), canonJsonBytes...)).Hex(), nil
 }
 
-func (s *server) getJobsKey() string {
-	return fmt.Sprintf("%s-%s-%s", jobsKeyPrefix, s.proverId, s.vkHash)
+// getJobsKey returns the key of the proof jobs of the tier with the vk hash
+func (s *server) getJobsKey(vkHash string) string {
+	return fmt.Sprintf("%s-%s-%s", jobsKeyPrefix, s.proverId, vkHash)
 }
@@ -0,0 +1,230 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/proto/commonproto"
+	"github.com/brevis-network/brevis-sdk/store"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+func TestTierJobs(t *testing.T) {
+	proofStore, err := store.InitStore("syncmap", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := &circuitTier{allocation: sdk.Allocation{MaxReceipts: 32}, vkHash: "0x01"}
+	large := &circuitTier{allocation: sdk.Allocation{MaxReceipts: 64}, vkHash: "0x02"}
+	s := &server{proverId: "test", tiers: []*circuitTier{small, large}, setupId: "0x01,0x02", proofStore: proofStore}
+
+	jobs := func(tier *circuitTier) []string {
+		var ret []string
+		_, err := s.proofStore.Get(s.getJobsKey(tier.vkHash), &ret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ret
+	}
+	for id, tier := range map[string]*circuitTier{"a": small, "b": large} {
+		err = s.setProveRequest(id, &ProveRequest{Status: ProveStatusInProgress, VkHash: tier.vkHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.addJob(id, tier.vkHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the jobs are keyed by the vk hash of their tier
+	if s.getJobsKey(small.vkHash) != "j-test-0x01" {
+		t.Errorf("unexpected jobs key %s", s.getJobsKey(small.vkHash))
+	}
+	if j := jobs(small); len(j) != 1 || j[0] != "a" {
+		t.Errorf("unexpected jobs of the small tier %v", j)
+	}
+	if j := jobs(large); len(j) != 1 || j[0] != "b" {
+		t.Errorf("unexpected jobs of the large tier %v", j)
+	}
+
+	err = s.deleteProveRequest("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := jobs(large); len(j) != 0 {
+		t.Errorf("expected the job of the deleted request to be removed, got %v", j)
+	}
+	if j := jobs(small); len(j) != 1 {
+		t.Errorf("expected the job of the small tier to be kept, got %v", j)
+	}
+}
+
+// solidityTestCircuit is the exportCircuit of the sdk tests, whose Solidity
+// verifier for the SRS of tau 42 is committed in sdk/testdata
+type solidityTestCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *solidityTestCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	commitment, err := api.(frontend.Committer).Commit(c.X)
+	if err != nil {
+		return err
+	}
+	api.AssertIsDifferent(commitment, 0)
+	return nil
+}
+
+func TestProveForSolidity(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &solidityTestCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints() + ccs.GetNbPublicVariables()))
+	canonical, err := kzg.NewSRS(size+3, big.NewInt(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lagrangeG1, err := kzg.ToLagrangeG1(canonical.Pk.G1[:size])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := plonk.Setup(ccs, canonical, &kzg.SRS{Pk: kzg.ProvingKey{G1: lagrangeG1}, Vk: canonical.Vk})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifierPath := filepath.Join(t.TempDir(), "PlonkVerifier.sol")
+	if err = sdk.ExportSolidityVerifier(vk, verifierPath); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := os.ReadFile(verifierPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := os.ReadFile("../testdata/PlonkVerifier.sol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(exported, committed) {
+		t.Fatal("the verifier does not match sdk/testdata/PlonkVerifier.sol, the circuit has diverged from the sdk tests")
+	}
+
+	w, err := frontend.NewWitness(&solidityTestCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wpub, err := w.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wpubBytes, err := wpub.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &commonproto.AppCircuitInfo{Witness: hexutil.Encode(wpubBytes)}
+
+	verifier := deployVerifier(t, "../testdata/PlonkVerifier.json")
+	defer verifier.backend.Close()
+	tier := &circuitTier{ccs: ccs, pk: pk, vk: vk}
+	verify := func(proveForSolidity bool) bool {
+		s := &server{proveRateLimiter: make(chan struct{}, 1), proveForSolidity: proveForSolidity}
+		proof, err := s.prove(tier, w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		imported, err := sdk.ImportServiceProof(proof, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proveForSolidity {
+			if err = plonk.Verify(imported.Proof, vk, imported.PublicWitness); err != nil {
+				t.Fatal(err)
+			}
+		}
+		calldata, err := imported.SolidityCalldata()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := verifier.backend.Client().CallContract(context.Background(), ethereum.CallMsg{From: verifier.from, To: &verifier.addr, Data: calldata}, nil)
+		if err != nil {
+			// the verifier reverts on malformed proofs
+			return false
+		}
+		res, err := verifier.abi.Unpack("Verify", out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res[0].(bool)
+	}
+	if !verify(true) {
+		t.Fatal("the proof of the service should verify on chain with ProveForSolidity")
+	}
+	if verify(false) {
+		t.Fatal("the proof of the service should not verify on chain without ProveForSolidity")
+	}
+}
+
+type testVerifier struct {
+	backend *simulated.Backend
+	from    common.Address
+	addr    common.Address
+	abi     abi.ABI
+}
+
+// deployVerifier deploys the contract of a Solidity artifact of the sdk tests on
+// a new simulated backend
+func deployVerifier(t *testing.T, artifactPath string) *testVerifier {
+	t.Helper()
+	b, err := os.ReadFile(artifactPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var artifact struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+	}
+	if err = json.Unmarshal(b, &artifact); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := abi.JSON(bytes.NewReader(artifact.ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &testVerifier{from: crypto.PubkeyToAddress(key.PublicKey), abi: parsed}
+	v.backend = simulated.NewBackend(types.GenesisAlloc{v.from: {Balance: big.NewInt(1e18)}})
+	// calls on the genesis block run before the merge and reject PUSH0
+	v.backend.Commit()
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.addr, _, _, err = bind.DeployContract(opts, parsed, common.FromHex(artifact.Bytecode), v.backend.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.backend.Commit()
+	return v
+}
@@ -8,21 +8,27 @@ import (
 
 	"github.com/celer-network/goutils/log"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
 	"github.com/consensys/gnark/backend/plonk"
 	"github.com/consensys/gnark/constraint"
 )
 
-func readOnly(circuit sdk.AppCircuit, setupDir string, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, ccs constraint.ConstraintSystem, vkHash []byte, err error) {
+// readOnly compiles the circuit and reads its setup from setupDir. If the setup
+// dir holds a setup bundle, the digest of the compiled circuit is checked
+// against the circuit digest of the bundle manifest.
+func readOnly(circuit sdk.AppCircuit, setupDir string, config ServiceConfig, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, ccs constraint.ConstraintSystem, vkHash []byte, err error) {
+	var ccsDigest, manifestDigest string
 	errG := errgroup.Group{}
 	errG.Go(func() error {
 		log.Debugln(">> compiling circuit")
-		ccs, err = sdk.CompileOnly(circuit)
+		ccs, err = config.compile(circuit)
 		if err != nil {
-			return fmt.Errorf("sdk.CompileOnly err: %w", err)
+			return fmt.Errorf("compile err: %w", err)
 		}
 
 		ccsBytes := bytes.NewBuffer(nil)
@@ -31,15 +37,31 @@ func readOnly(circuit sdk.AppCircuit, setupDir string, hashInfo *sdk.BrevisHashI
 			return fmt.Errorf("ccs.WriteTo err: %w", err)
 		}
 
-		ccsDigest := crypto.Keccak256(ccsBytes.Bytes())
-		log.Debugf("circuit digest 0x%x\n", ccsDigest)
+		ccsDigest = hexutil.Encode(crypto.Keccak256(ccsBytes.Bytes()))
+		log.Debugf("circuit digest %s\n", ccsDigest)
 		return nil
 	})
 	errG.Go(func() error {
 		log.Debugln(">> load vk pk")
+		if sdk.HasSetupBundle(setupDir) {
+			readBundle := sdk.ReadSetupBundle
+			if config.MmapPk {
+				readBundle = sdk.ReadSetupBundleMapped
+			}
+			bundle, err := readBundle(setupDir, circuit, hashInfo)
+			if err != nil {
+				return fmt.Errorf("fail to read setup bundle, err: %w", err)
+			}
+			pk, vk, vkHash = bundle.PK, bundle.VK, bundle.VkHash
+			manifestDigest = bundle.Manifest.CircuitDigest
+			log.Debugf("load setup bundle success, vk hash: %x\n", vkHash)
+			return nil
+		}
+		// setups of older versions have no manifest
 		maxReceipts, maxStorage, maxTxs := circuit.Allocate()
 		dataPoints := sdk.DataPointsNextPowerOf2(maxReceipts + maxStorage + maxTxs)
-		pk, vk, vkHash, err = readSetup(filepath.Join(setupDir, "pk"), filepath.Join(setupDir, "vk"), maxReceipts, maxStorage, dataPoints, hashInfo)
+		var err error
+		pk, vk, vkHash, err = readSetup(setupDir, config.MmapPk, maxReceipts, maxStorage, dataPoints, hashInfo)
 		if err != nil {
 			return fmt.Errorf("fail to find pk vk, err: %w", err)
 		}
@@ -51,6 +73,10 @@ func readOnly(circuit sdk.AppCircuit, setupDir string, hashInfo *sdk.BrevisHashI
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
+	if manifestDigest != "" && manifestDigest != ccsDigest {
+		return nil, nil, nil, nil, fmt.Errorf("circuit digest %s of the compiled circuit does not match the setup manifest %s in %s, "+
+			"the circuit has changed since it was set up", ccsDigest, manifestDigest, setupDir)
+	}
 
 	log.Debugf("load ccs, pk, vk success from %s \n", setupDir)
 
@@ -59,9 +85,9 @@ func readOnly(circuit sdk.AppCircuit, setupDir string, hashInfo *sdk.BrevisHashI
 	return pk, vk, ccs, vkHash, nil
 }
 
-func readOrSetup(circuit sdk.AppCircuit, setupDir, srsDir string, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, ccs constraint.ConstraintSystem, vkHash []byte, err error) {
+func readOrSetup(circuit sdk.AppCircuit, config ServiceConfig, srsProvider srs.Provider, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, ccs constraint.ConstraintSystem, vkHash []byte, err error) {
 	log.Debugln(">> compiling circuit")
-	ccs, err = sdk.CompileOnly(circuit)
+	ccs, err = config.compile(circuit)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
@@ -78,41 +104,70 @@ func readOrSetup(circuit sdk.AppCircuit, setupDir, srsDir string, hashInfo *sdk.
 	maxReceipts, maxStorage, maxTxs := circuit.Allocate()
 	dataPoints := sdk.DataPointsNextPowerOf2(maxReceipts + maxStorage + maxTxs)
 
-	pkFilepath := filepath.Join(setupDir, fmt.Sprintf("0x%x", ccsDigest), "pk")
-	vkFilepath := filepath.Join(setupDir, fmt.Sprintf("0x%x", ccsDigest), "vk")
+	setupDir := config.GetSetupDir()
+	bundleDir := filepath.Join(setupDir, fmt.Sprintf("0x%x", ccsDigest))
 
 	log.Debugln("trying to read setup from cache...")
-	pk, vk, vkHash, err = readSetup(pkFilepath, vkFilepath, maxReceipts, maxStorage, dataPoints, hashInfo)
-	if err == nil {
-		return pk, vk, ccs, vkHash, nil
+	if sdk.HasSetupBundle(bundleDir) {
+		readBundle := sdk.ReadSetupBundle
+		if config.MmapPk {
+			readBundle = sdk.ReadSetupBundleMapped
+		}
+		bundle, err := readBundle(bundleDir, circuit, hashInfo)
+		if err == nil {
+			return bundle.PK, bundle.VK, ccs, bundle.VkHash, nil
+		}
+		log.Errorf("cannot use the setup bundle in %s, setting up again: %s", bundleDir, err.Error())
+	} else {
+		// setups of older versions have no manifest
+		pk, vk, vkHash, err = readSetup(bundleDir, config.MmapPk, maxReceipts, maxStorage, dataPoints, hashInfo)
+		if err == nil {
+			return pk, vk, ccs, vkHash, nil
+		}
+		log.Debugf("no setup matching circuit digest 0x%x is found in %s\n", ccsDigest, setupDir)
 	}
 
-	log.Debugf("no setup matching circuit digest 0x%x is found in %s\n", ccsDigest, setupDir)
 	log.Debugln(">> setup")
 
-	pk, vk, vkHash, err = sdk.Setup(ccs, srsDir, maxReceipts, maxStorage, dataPoints, hashInfo)
+	pk, vk, vkHash, err = sdk.SetupWithSRS(ccs, srsProvider, maxReceipts, maxStorage, dataPoints, hashInfo)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 
-	err = sdk.WriteTo(pk, pkFilepath)
+	_, err = sdk.WriteSetupBundle(bundleDir, sdk.AllocationOf(circuit), ccs, pk, vk, vkHash, hashInfo)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-	err = sdk.WriteTo(vk, vkFilepath)
-	if err != nil {
-		return nil, nil, nil, nil, err
+	if config.MmapPk {
+		// swap the pk in memory for the mapped one
+		mapped, err := sdk.MmapSetupPk(bundleDir)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		pk = mapped.PK
+		goruntime.GC()
 	}
 
 	return pk, vk, ccs, vkHash, nil
 }
 
-func readSetup(pkFilepath, vkFilepath string, maxReceipt, maxStorage, numMaxDataPoints int, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, vkHash []byte, err error) {
-	log.Debugf("load pk from %s\n", pkFilepath)
-	pk, err = sdk.ReadPkFrom(pkFilepath)
-	if err != nil {
-		return nil, nil, nil, err
+func readSetup(dir string, mmapPk bool, maxReceipt, maxStorage, numMaxDataPoints int, hashInfo *sdk.BrevisHashInfo) (pk plonk.ProvingKey, vk plonk.VerifyingKey, vkHash []byte, err error) {
+	if mmapPk {
+		log.Debugf("map pk from %s\n", dir)
+		mapped, err := sdk.MmapSetupPk(dir)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pk = mapped.PK
+	} else {
+		pkFilepath := filepath.Join(dir, "pk")
+		log.Debugf("load pk from %s\n", pkFilepath)
+		pk, err = sdk.ReadPkFrom(pkFilepath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 	}
+	vkFilepath := filepath.Join(dir, "vk")
 	log.Debugf("load vk from %s\n", vkFilepath)
 	vk, vkHash, err = sdk.ReadVkFrom(vkFilepath, maxReceipt, maxStorage, numMaxDataPoints, hashInfo)
 	if err != nil {
@@ -121,3 +176,66 @@ func readSetup(pkFilepath, vkFilepath string, maxReceipt, maxStorage, numMaxData
 	log.Debugf("load vk done, and vk hash is %x\n", vkHash)
 	return pk, vk, vkHash, nil
 }
+
+// circuitTier is the circuit and keys of an allocation tier of the app circuit,
+// see sdk.AppCircuitWithTiers
+type circuitTier struct {
+	allocation sdk.Allocation
+	circuit    sdk.AppCircuit
+
+	pk  plonk.ProvingKey
+	vk  plonk.VerifyingKey
+	ccs constraint.ConstraintSystem
+
+	vkString string
+	vkHash   string
+}
+
+// loadTiers reads or sets up the circuit of each allocation tier of the app
+// circuit. With DirectLoad, the keys of a tier are read from its sdk.TierDir
+// under the setup dir, unless the app circuit has no tiers.
+func loadTiers(appCircuit sdk.AppCircuit, config ServiceConfig, hashInfo *sdk.BrevisHashInfo) ([]*circuitTier, error) {
+	srsProvider, err := config.GetSrsProvider()
+	if err != nil {
+		return nil, err
+	}
+	circuits := sdk.Tiers(appCircuit)
+	var tiers []*circuitTier
+	for _, circuit := range circuits {
+		allocation := sdk.AllocationOf(circuit)
+		var pk plonk.ProvingKey
+		var vk plonk.VerifyingKey
+		var ccs constraint.ConstraintSystem
+		var vkHash []byte
+		if config.DirectLoad {
+			setupDir := config.GetSetupDir()
+			if len(circuits) > 1 {
+				setupDir = sdk.TierDir(setupDir, allocation)
+			}
+			pk, vk, ccs, vkHash, err = readOnly(circuit, setupDir, config, hashInfo)
+		} else {
+			pk, vk, ccs, vkHash, err = readOrSetup(circuit, config, srsProvider, hashInfo)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("readOrSetup err for tier %s: %w", allocation, err)
+		}
+
+		var buf bytes.Buffer
+		_, err = vk.WriteRawTo(&buf)
+		if err != nil {
+			return nil, fmt.Errorf("vk.WriteTo err: %w", err)
+		}
+		tier := &circuitTier{
+			allocation: allocation,
+			circuit:    circuit,
+			pk:         pk,
+			vk:         vk,
+			ccs:        ccs,
+			vkString:   hexutil.Encode(buf.Bytes()),
+			vkHash:     hexutil.Encode(vkHash),
+		}
+		tiers = append(tiers, tier)
+		log.Infof("circuit tier %s (receipts/storage/transactions) vk hash: %s", allocation, tier.vkHash)
+	}
+	return tiers, nil
+}
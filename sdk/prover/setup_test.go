@@ -0,0 +1,77 @@
+package prover
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	pgoldilocks "github.com/OpenAssetStandards/poseidon-goldilocks-go"
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+func TestReadOnlyCircuitDigest(t *testing.T) {
+	app := &setupTestCircuit{}
+	config := ServiceConfig{DisableCompileCache: true}
+	hashInfo := &sdk.BrevisHashInfo{
+		P2AggRecursionLeafCircuitDigestHash:                  &pgoldilocks.HashOut256{1, 2, 3, 4},
+		P2AggRecursionMiddleFormMiddleLeafCircuitDigestHash:  &pgoldilocks.HashOut256{5, 6, 7, 8},
+		P2AggRecursionNoLeafCircuitDigestHash:                &pgoldilocks.HashOut256{9, 10, 11, 12},
+		P2Bn128WrapCircuitDigestHashForOnly2Leaf:             big.NewInt(13),
+		P2Bn128WrapCircuitDigestHashForOnlyFromLeafRecursion: big.NewInt(14),
+		P2Bn128WrapCircuitDigestHash:                         big.NewInt(15),
+		GnarkReceiptVkHash:                                   big.NewInt(16),
+		GnarkStorageVkHash:                                   big.NewInt(17),
+		GnarkTxVkHash:                                        big.NewInt(18),
+		GnarkMiddleNodeVkHash:                                big.NewInt(19),
+	}
+	// the bundle is set up for another circuit than the app circuit
+	compiled, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, _, err := unsafekzg.NewSRS(compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := sdk.AllocationOf(app)
+	pk, vk, vkHash, err := sdk.SetupWithSRS(compiled, &srs.MemoryProvider{Canonical: canonical},
+		a.MaxReceipts, a.MaxStorage, a.DataPoints(), hashInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	_, err = sdk.WriteSetupBundle(dir, a, compiled, pk, vk, vkHash, hashInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, _, err = readOnly(app, dir, config, hashInfo)
+	if err == nil || !strings.Contains(err.Error(), "does not match the setup manifest") {
+		t.Fatalf("expected circuit digest mismatch, got %v", err)
+	}
+}
+
+type setupTestCircuit struct{}
+
+func (c *setupTestCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 32, 0, 0
+}
+
+func (c *setupTestCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
+	api.OutputUint(64, sdk.Count(sdk.NewDataStream(api, in.Receipts)))
+	return nil
+}
+
+type squareCircuit struct {
+	X, Y frontend.Variable
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
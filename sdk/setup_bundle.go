@@ -0,0 +1,354 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// SetupBundleVersion is the version of the setup bundle format written by
+// WriteSetupBundle. Bundles of other versions cannot be read.
+const SetupBundleVersion = 1
+
+// The files of a setup bundle. The names of the circuit and the keys are the
+// ones Compile has always used, so that the keys of a bundle can still be read
+// with ReadPkFrom and ReadVkFrom.
+const (
+	setupManifestFile = "manifest.json"
+	setupCircuitFile  = "compiledCircuit"
+	setupPkFile       = "pk"
+	setupVkFile       = "vk"
+)
+
+const (
+	sdkModulePath   = "github.com/brevis-network/brevis-sdk"
+	gnarkModulePath = "github.com/consensys/gnark"
+)
+
+// SetupManifest describes the compilation and setup outputs in a setup bundle
+// directory. It is written to manifest.json next to the outputs.
+type SetupManifest struct {
+	Version      int    `json:"version"`
+	SdkVersion   string `json:"sdk_version"`
+	GnarkVersion string `json:"gnark_version"`
+	// CircuitDigest is the keccak256 of the serialized constraint system
+	CircuitDigest   string `json:"circuit_digest"`
+	MaxReceipts     int    `json:"max_receipts"`
+	MaxStorage      int    `json:"max_storage"`
+	MaxTransactions int    `json:"max_transactions"`
+	DataPoints      int    `json:"data_points"`
+	VkHash          string `json:"vk_hash"`
+	// HashInfo is the Brevis hash info the vk hash is computed with
+	HashInfo *BrevisHashInfo `json:"hash_info"`
+	// Checksums is the sha256 of each file of the bundle
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Allocation returns the allocation of the circuit of the bundle
+func (m *SetupManifest) Allocation() Allocation {
+	return Allocation{m.MaxReceipts, m.MaxStorage, m.MaxTransactions}
+}
+
+// SetupBundle is the content of a setup bundle directory
+type SetupBundle struct {
+	Manifest *SetupManifest
+	CCS      constraint.ConstraintSystem
+	PK       plonk.ProvingKey
+	VK       plonk.VerifyingKey
+	VkHash   []byte
+
+	mappedPk *MappedPk
+}
+
+// Close unmaps the pk of a bundle read by ReadSetupBundleMapped
+func (b *SetupBundle) Close() error {
+	if b.mappedPk == nil {
+		return nil
+	}
+	return b.mappedPk.Close()
+}
+
+// WriteSetupBundle writes the constraint system, the keys and their manifest to
+// dir. The circuit digest and the checksums are computed while writing.
+func WriteSetupBundle(
+	dir string, allocation Allocation,
+	ccs constraint.ConstraintSystem, pk plonk.ProvingKey, vk plonk.VerifyingKey, vkHash []byte,
+	hashInfo *BrevisHashInfo,
+) (*SetupManifest, error) {
+	dir = os.ExpandEnv(dir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	m := &SetupManifest{
+		Version:         SetupBundleVersion,
+		SdkVersion:      moduleVersion(sdkModulePath),
+		GnarkVersion:    moduleVersion(gnarkModulePath),
+		MaxReceipts:     allocation.MaxReceipts,
+		MaxStorage:      allocation.MaxStorage,
+		MaxTransactions: allocation.MaxTransactions,
+		DataPoints:      allocation.DataPoints(),
+		VkHash:          hexutil.Encode(vkHash),
+		HashInfo:        hashInfo,
+		Checksums:       map[string]string{},
+	}
+	digest := crypto.NewKeccakState()
+	var err error
+	m.Checksums[setupCircuitFile], err = writeBundleFile(dir, setupCircuitFile, ccs, digest)
+	if err != nil {
+		return nil, err
+	}
+	m.CircuitDigest = hexutil.Encode(digest.Sum(nil))
+	m.Checksums[setupPkFile], err = writeBundleFile(dir, setupPkFile, pk)
+	if err != nil {
+		return nil, err
+	}
+	m.Checksums[setupVkFile], err = writeBundleFile(dir, setupVkFile, vk)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = os.WriteFile(filepath.Join(dir, setupManifestFile), b, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("setup bundle written to %s\n", dir)
+	return m, nil
+}
+
+// HasSetupBundle tells whether dir contains a setup bundle, i.e. a manifest
+func HasSetupBundle(dir string) bool {
+	_, err := os.Stat(filepath.Join(os.ExpandEnv(dir), setupManifestFile))
+	return err == nil
+}
+
+// ReadSetupManifest reads the manifest of the setup bundle in dir
+func ReadSetupManifest(dir string) (*SetupManifest, error) {
+	b, err := os.ReadFile(filepath.Join(os.ExpandEnv(dir), setupManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	m := &SetupManifest{}
+	err = json.Unmarshal(b, m)
+	if err != nil {
+		return nil, fmt.Errorf("invalid setup manifest: %s", err.Error())
+	}
+	return m, nil
+}
+
+// Check checks that the bundle was set up for the app circuit and the Brevis
+// hash info, and with the running versions of the SDK and gnark. A different
+// SDK version is only reported, since the circuit digest tells whether the
+// circuit has changed. The hash info is not checked if it is nil.
+func (m *SetupManifest) Check(app AppCircuit, hashInfo *BrevisHashInfo) error {
+	if m.Version != SetupBundleVersion {
+		return fmt.Errorf("setup bundle version %d is not supported, expected version %d", m.Version, SetupBundleVersion)
+	}
+	if gnarkVersion := moduleVersion(gnarkModulePath); !sameVersion(m.GnarkVersion, gnarkVersion) {
+		return fmt.Errorf("setup bundle was created with gnark %s, but gnark %s is used", m.GnarkVersion, gnarkVersion)
+	}
+	if sdkVersion := moduleVersion(sdkModulePath); !sameVersion(m.SdkVersion, sdkVersion) {
+		fmt.Printf("setup bundle was created with brevis-sdk %s, but brevis-sdk %s is used\n", m.SdkVersion, sdkVersion)
+	}
+	if app != nil {
+		if a := AllocationOf(app); a != m.Allocation() {
+			return fmt.Errorf("setup bundle allocation %s does not match the allocation %s of the app circuit (receipts/storage/transactions)",
+				m.Allocation(), a)
+		}
+	}
+	if m.DataPoints != m.Allocation().DataPoints() {
+		return fmt.Errorf("setup bundle data points %d does not match its allocation %s", m.DataPoints, m.Allocation())
+	}
+	if hashInfo != nil {
+		expected, err := json.Marshal(hashInfo)
+		if err != nil {
+			return err
+		}
+		actual, err := json.Marshal(m.HashInfo)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(expected, actual) {
+			return fmt.Errorf("setup bundle was created with different Brevis hash info, the Brevis circuits may have been upgraded and the circuit needs to be set up again")
+		}
+	}
+	return nil
+}
+
+// ReadSetupBundle reads the setup bundle in dir. The manifest is checked
+// against the app circuit and the hash info (see SetupManifest.Check), and the
+// files against the checksums and the circuit digest of the manifest. The
+// app circuit and the hash info can be nil to skip their checks.
+func ReadSetupBundle(dir string, app AppCircuit, hashInfo *BrevisHashInfo) (*SetupBundle, error) {
+	return readSetupBundle(dir, app, hashInfo, false)
+}
+
+// ReadSetupBundleMapped is like ReadSetupBundle, but the pk is mapped from the
+// pk dump of the bundle instead of being read into memory, see MmapSetupPk. The
+// dump is created from the pk file the first time.
+func ReadSetupBundleMapped(dir string, app AppCircuit, hashInfo *BrevisHashInfo) (*SetupBundle, error) {
+	return readSetupBundle(dir, app, hashInfo, true)
+}
+
+func readSetupBundle(dir string, app AppCircuit, hashInfo *BrevisHashInfo, mmapPk bool) (*SetupBundle, error) {
+	dir = os.ExpandEnv(dir)
+	m, err := ReadSetupManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Check(app, hashInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	ccs := new(cs_bn254.SparseR1CS)
+	digest := crypto.NewKeccakState()
+	err = readBundleFile(dir, setupCircuitFile, m.Checksums[setupCircuitFile], ccs, digest)
+	if err != nil {
+		return nil, err
+	}
+	if d := hexutil.Encode(digest.Sum(nil)); d != m.CircuitDigest {
+		return nil, fmt.Errorf("circuit digest %s does not match the setup manifest %s", d, m.CircuitDigest)
+	}
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	err = readBundleFile(dir, setupVkFile, m.Checksums[setupVkFile], vk)
+	if err != nil {
+		return nil, err
+	}
+	vkHash, err := m.checkVkHash(vk)
+	if err != nil {
+		return nil, err
+	}
+	b := &SetupBundle{Manifest: m, CCS: ccs, VK: vk, VkHash: vkHash}
+	if mmapPk {
+		// the pk file is verified when it is converted to a dump
+		b.mappedPk, err = MmapSetupPk(dir)
+		if err != nil {
+			return nil, err
+		}
+		b.PK = b.mappedPk.PK
+	} else {
+		b.PK = plonk.NewProvingKey(ecc.BN254)
+		err = readBundleFile(dir, setupPkFile, m.Checksums[setupPkFile], b.PK)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fmt.Printf("setup bundle read from %s, vk hash: %s\n", dir, m.VkHash)
+	return b, nil
+}
+
+// checkVkHash computes the vk hash from the vk, the allocation and the hash info
+// of the manifest, and checks it against the vk hash of the manifest
+func (m *SetupManifest) checkVkHash(vk plonk.VerifyingKey) ([]byte, error) {
+	if m.HashInfo == nil {
+		return nil, fmt.Errorf("no Brevis hash info in the setup manifest to compute the vk hash with")
+	}
+	if m.MaxReceipts%32 != 0 || m.MaxStorage%32 != 0 {
+		return nil, fmt.Errorf("setup bundle allocation %s is not a multiple of 32", m.Allocation())
+	}
+	expected, err := hexutil.Decode(m.VkHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vk hash in setup manifest: %s", err.Error())
+	}
+	vkHash, err := printVkHash(vk, m.MaxReceipts, m.MaxStorage, m.DataPoints, m.HashInfo)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(vkHash, expected) {
+		return nil, fmt.Errorf("vk hash %s computed from the vk does not match the setup manifest %s", hexutil.Encode(vkHash), m.VkHash)
+	}
+	return vkHash, nil
+}
+
+func writeBundleFile(dir, name string, w io.WriterTo, digests ...io.Writer) (string, error) {
+	path := filepath.Join(dir, name)
+	f, err := os.OpenFile(path, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	buf := bufio.NewWriter(f)
+	d, err := w.WriteTo(io.MultiWriter(append([]io.Writer{buf, h}, digests...)...))
+	if err != nil {
+		return "", err
+	}
+	if err = buf.Flush(); err != nil {
+		return "", err
+	}
+	fmt.Printf("%d bytes written to %s\n", d, path)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readBundleFile(dir, name, checksum string, r io.ReaderFrom, digests ...io.Writer) error {
+	path := filepath.Join(dir, name)
+	if checksum == "" {
+		return fmt.Errorf("no checksum of %s in the setup manifest", name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	tee := io.TeeReader(bufio.NewReader(f), io.MultiWriter(append([]io.Writer{h}, digests...)...))
+	_, err = r.ReadFrom(tee)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %s", path, err.Error())
+	}
+	// trailing bytes are part of the checksum too
+	if _, err = io.Copy(io.Discard, tee); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != checksum {
+		return fmt.Errorf("checksum of %s is %s but the setup manifest has %s, the file may be corrupted", path, actual, checksum)
+	}
+	return nil
+}
+
+// moduleVersion returns the version of the module the running binary is built
+// with, or the path and version of its replacement
+func moduleVersion(path string) string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, m := range info.Deps {
+		if m.Path != path {
+			continue
+		}
+		if m.Replace != nil {
+			return m.Replace.Path + "@" + m.Replace.Version
+		}
+		return m.Version
+	}
+	return ""
+}
+
+// sameVersion tells whether two module versions are the same. Unknown versions
+// and local builds match any version.
+func sameVersion(a, b string) bool {
+	unknown := func(v string) bool { return v == "" || v == "(devel)" }
+	return unknown(a) || unknown(b) || a == b
+}
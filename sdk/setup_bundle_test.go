@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+func TestSetupBundle(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &squareCircuit{})
+	check(err)
+	canonical, lagrange, err := unsafekzg.NewSRS(ccs)
+	check(err)
+	pk, vk, err := plonk.Setup(ccs, canonical, lagrange)
+	check(err)
+
+	app := &TestTiersCircuit{}
+	hashInfo := testHashInfo()
+	dir := t.TempDir()
+	m, vkHash := writeTestSetupBundle(dir, AllocationOf(app), ccs, pk, vk)
+	if m.Version != SetupBundleVersion || m.DataPoints != 128 || len(m.Checksums) != 3 || m.CircuitDigest == "" {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	b, err := ReadSetupBundle(dir, app, hashInfo)
+	check(err)
+	if b.CCS.GetNbConstraints() != ccs.GetNbConstraints() || string(b.VkHash) != string(vkHash) {
+		t.Fatalf("unexpected bundle %+v", b)
+	}
+	_, _, _, readVkHash, err := ReadSetupFromWithHashInfo(app, dir, hashInfo)
+	check(err)
+	if string(readVkHash) != string(vkHash) {
+		t.Fatalf("unexpected vk hash %x", readVkHash)
+	}
+	// the circuit can still be read on its own
+	_, err = ReadCircuitFrom(filepath.Join(dir, setupCircuitFile))
+	check(err)
+
+	expectErr := func(err error, contains string) {
+		t.Helper()
+		if err == nil || !strings.Contains(err.Error(), contains) {
+			t.Fatalf("expected error containing %q, got %v", contains, err)
+		}
+		t.Log(err)
+	}
+	_, err = ReadSetupBundle(dir, &TestHintsCircuit{}, hashInfo)
+	expectErr(err, "does not match the allocation")
+	otherHashInfo := testHashInfo()
+	otherHashInfo.GnarkMiddleNodeVkHash = big.NewInt(2)
+	_, err = ReadSetupBundle(dir, app, otherHashInfo)
+	expectErr(err, "different Brevis hash info")
+
+	// corrupt the proving key
+	pkPath := filepath.Join(dir, setupPkFile)
+	pkBytes, err := os.ReadFile(pkPath)
+	check(err)
+	check(os.WriteFile(pkPath, append(pkBytes, 0), 0644))
+	_, err = ReadSetupBundle(dir, app, hashInfo)
+	expectErr(err, "the file may be corrupted")
+	check(os.WriteFile(pkPath, pkBytes, 0644))
+
+	writeManifest := func(m SetupManifest) {
+		manifest, err := json.Marshal(m)
+		check(err)
+		check(os.WriteFile(filepath.Join(dir, setupManifestFile), manifest, 0644))
+	}
+	// the vk hash is computed from the vk, not taken from the manifest
+	tampered := *m
+	tampered.VkHash = hexutil.Encode(common.BytesToHash([]byte{1, 2, 3}).Bytes())
+	writeManifest(tampered)
+	_, err = ReadSetupBundle(dir, app, hashInfo)
+	expectErr(err, "does not match the setup manifest")
+	_, err = ReadSetupBundleMapped(dir, app, hashInfo)
+	expectErr(err, "does not match the setup manifest")
+	// so is the hash info the vk hash is computed with
+	tampered = *m
+	tampered.HashInfo = otherHashInfo
+	writeManifest(tampered)
+	_, err = ReadSetupBundle(dir, app, nil)
+	expectErr(err, "does not match the setup manifest")
+
+	tampered = *m
+	tampered.Version++
+	writeManifest(tampered)
+	_, err = ReadSetupBundle(dir, app, hashInfo)
+	expectErr(err, "is not supported")
+}
+
+// writeTestSetupBundle writes a setup bundle with the vk hash computed with
+// testHashInfo
+func writeTestSetupBundle(dir string, a Allocation, ccs constraint.ConstraintSystem, pk plonk.ProvingKey, vk plonk.VerifyingKey) (*SetupManifest, []byte) {
+	vkHash, err := printVkHash(vk, a.MaxReceipts, a.MaxStorage, a.DataPoints(), testHashInfo())
+	check(err)
+	m, err := WriteSetupBundle(dir, a, ccs, pk, vk, vkHash, testHashInfo())
+	check(err)
+	return m, vkHash
+}
+
+type squareCircuit struct {
+	X, Y frontend.Variable
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
@@ -0,0 +1,458 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+// SetupExpectation is what a setup is verified against, e.g. the published
+// outputs of Compile or the vk hash deployed on chain. At least one of VkFile
+// and VkHash must be set.
+type SetupExpectation struct {
+	// VkFile is a vk written by Compile, e.g. the vk of a setup bundle
+	VkFile string
+	// VkHash is the vk hash (circuit digest) registered on chain
+	VkHash []byte
+	// CircuitDigest is the hex keccak256 of the compiled circuit, e.g. from a
+	// setup manifest. It is not checked if empty.
+	CircuitDigest string
+}
+
+// SetupCheck is one comparison of a setup verification
+type SetupCheck struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Ok       bool   `json:"ok"`
+}
+
+// SetupReport records every intermediate hash of a setup verification, from the
+// compiled circuit to the vk hash, so that anyone can reproduce it. The report
+// of a circuit is the same on every run and machine as long as the SDK, gnark
+// and the SRS are the same.
+type SetupReport struct {
+	SdkVersion      string `json:"sdk_version"`
+	GnarkVersion    string `json:"gnark_version"`
+	MaxReceipts     int    `json:"max_receipts"`
+	MaxStorage      int    `json:"max_storage"`
+	MaxTransactions int    `json:"max_transactions"`
+	DataPoints      int    `json:"data_points"`
+	NbConstraints   int    `json:"nb_constraints"`
+	// CircuitDigest is the keccak256 of the compiled circuit, as in a setup manifest
+	CircuitDigest string `json:"circuit_digest"`
+	// SrsSize is the number of G1 powers of the SRS the setup uses, and
+	// SrsDigest the keccak256 of these powers and of the SRS vk
+	SrsSize   int    `json:"srs_size"`
+	SrsDigest string `json:"srs_digest"`
+	// VkDigest is the keccak256 of the serialized vk computed by the setup
+	VkDigest string `json:"vk_digest"`
+	// AppVkHash is the hash of the vk in the Brevis aggregation, see ComputeVkHash
+	AppVkHash string `json:"app_vk_hash"`
+	// Hash2HashDigest and Plonky2Digest are the digests of the Brevis circuits
+	// that aggregate the data of the allocation
+	Hash2HashDigest string `json:"hash2hash_digest"`
+	Plonky2Digest   string `json:"plonky2_digest"`
+	// VkHash is the circuit digest registered on chain, see CalcBrevisCircuitDigest
+	VkHash   string          `json:"vk_hash"`
+	HashInfo *BrevisHashInfo `json:"hash_info"`
+
+	Checks   []SetupCheck `json:"checks"`
+	Verified bool         `json:"verified"`
+
+	// Signer and Signature are set by Sign
+	Signer    string `json:"signer,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// VerifySetup recompiles the app circuit, runs the PLONK setup again with the
+// SRS of the provider and checks that the resulting vk and vk hash match the
+// expected ones. A tier of the app circuit is verified by passing it from Tiers.
+// The returned report is not verified, i.e. SetupReport.Verified is false, if
+// any of the checks failed. An error is only returned if the verification could
+// not be carried out.
+func VerifySetup(app AppCircuit, provider srs.Provider, expected SetupExpectation, hashInfo *BrevisHashInfo) (*SetupReport, error) {
+	fmt.Println(">> compile")
+	ccs, err := CompileOnly(app)
+	if err != nil {
+		return nil, err
+	}
+	return verifySetup(ccs, AllocationOf(app), provider, expected, hashInfo)
+}
+
+func verifySetup(ccs constraint.ConstraintSystem, a Allocation, provider srs.Provider, expected SetupExpectation, hashInfo *BrevisHashInfo) (*SetupReport, error) {
+	if expected.VkFile == "" && len(expected.VkHash) == 0 {
+		return nil, fmt.Errorf("no vk or vk hash to verify the setup against")
+	}
+	if hashInfo == nil {
+		return nil, fmt.Errorf("the Brevis hash info is required to compute the vk hash")
+	}
+	r := &SetupReport{
+		SdkVersion:      moduleVersion(sdkModulePath),
+		GnarkVersion:    moduleVersion(gnarkModulePath),
+		MaxReceipts:     a.MaxReceipts,
+		MaxStorage:      a.MaxStorage,
+		MaxTransactions: a.MaxTransactions,
+		DataPoints:      a.DataPoints(),
+		NbConstraints:   ccs.GetNbConstraints(),
+		HashInfo:        hashInfo,
+	}
+	var err error
+	r.CircuitDigest, err = keccakOf(ccs)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println(">> setup")
+	canonical, lagrange, err := srs.NewSRSFrom(ccs, provider)
+	if err != nil {
+		return nil, err
+	}
+	// only the powers the setup uses are hashed, so that the digest does not
+	// depend on whether the SRS was trimmed
+	used := &kzg_bn254.SRS{Vk: canonical.(*kzg_bn254.SRS).Vk}
+	used.Pk.G1 = canonical.(*kzg_bn254.SRS).Pk.G1[:len(lagrange.(*kzg_bn254.SRS).Pk.G1)+3]
+	r.SrsSize = len(used.Pk.G1)
+	r.SrsDigest, err = keccakOf(rawWriter{used})
+	if err != nil {
+		return nil, err
+	}
+	_, vk, err := plonk.Setup(ccs, canonical, lagrange)
+	if err != nil {
+		return nil, err
+	}
+	r.VkDigest, err = keccakOf(vk)
+	if err != nil {
+		return nil, err
+	}
+
+	appVkHash, err := ComputeVkHash(vk)
+	if err != nil {
+		return nil, err
+	}
+	r.AppVkHash = appVkHash.Hex()
+	hash2HashDigest, err := GetHash2HashCircuitDigest(a.MaxReceipts, a.MaxStorage, r.DataPoints-a.MaxReceipts-a.MaxStorage, hashInfo)
+	if err != nil {
+		return nil, err
+	}
+	r.Hash2HashDigest = common.BigToHash(hash2HashDigest).Hex()
+	plonky2Digest, _, _, err := GetPlonky2CircuitDigestFromRootNodeSelf(a.MaxReceipts, a.MaxStorage, r.DataPoints-a.MaxReceipts-a.MaxStorage, hashInfo)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]byte, 0, 32)
+	for _, w := range plonky2Digest {
+		words = binary.BigEndian.AppendUint64(words, w)
+	}
+	r.Plonky2Digest = hexutil.Encode(words)
+	vkHash, err := brevisVkHash(vk, a, hashInfo)
+	if err != nil {
+		return nil, err
+	}
+	r.VkHash = vkHash
+
+	if expected.CircuitDigest != "" {
+		r.check("circuit digest", expected.CircuitDigest, r.CircuitDigest)
+	}
+	if expected.VkFile != "" {
+		expectedVk, err := readVk(expected.VkFile)
+		if err != nil {
+			return nil, err
+		}
+		expectedVkDigest, err := keccakOf(expectedVk)
+		if err != nil {
+			return nil, err
+		}
+		r.check("vk", expectedVkDigest, r.VkDigest)
+		expectedVkHash, err := brevisVkHash(expectedVk, a, hashInfo)
+		if err != nil {
+			return nil, err
+		}
+		r.check("vk hash of the vk file", expectedVkHash, r.VkHash)
+	}
+	if len(expected.VkHash) > 0 {
+		r.check("vk hash", common.BytesToHash(expected.VkHash).Hex(), r.VkHash)
+	}
+	r.Verified = true
+	for _, c := range r.Checks {
+		r.Verified = r.Verified && c.Ok
+	}
+	r.print()
+	return r, nil
+}
+
+func (r *SetupReport) check(name, expected, actual string) {
+	r.Checks = append(r.Checks, SetupCheck{
+		Name:     name,
+		Expected: expected,
+		Actual:   actual,
+		Ok:       strings.EqualFold(expected, actual),
+	})
+}
+
+func (r *SetupReport) print() {
+	fmt.Println("///////////////////////////////////////////////////////////////////////////////")
+	fmt.Printf("// circuit digest:    %s (%d constraints)\n", r.CircuitDigest, r.NbConstraints)
+	fmt.Printf("// srs digest:        %s (%d powers)\n", r.SrsDigest, r.SrsSize)
+	fmt.Printf("// vk digest:         %s\n", r.VkDigest)
+	fmt.Printf("// app vk hash:       %s\n", r.AppVkHash)
+	fmt.Printf("// hash2hash digest:  %s\n", r.Hash2HashDigest)
+	fmt.Printf("// plonky2 digest:    %s\n", r.Plonky2Digest)
+	fmt.Printf("// vk hash:           %s\n", r.VkHash)
+	for _, c := range r.Checks {
+		result := "ok"
+		if !c.Ok {
+			result = "MISMATCH, expected " + c.Expected
+		}
+		fmt.Printf("// %s: %s\n", c.Name, result)
+	}
+	fmt.Printf("// verified: %t\n", r.Verified)
+	fmt.Println("///////////////////////////////////////////////////////////////////////////////")
+}
+
+// Digest returns the keccak256 of the report without its signature, which is
+// what Sign signs
+func (r *SetupReport) Digest() ([]byte, error) {
+	unsigned := *r
+	unsigned.Signer, unsigned.Signature = "", ""
+	b, err := json.Marshal(&unsigned)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Keccak256(b), nil
+}
+
+// Sign signs off the report with the key. The digest of the report is signed
+// as an EIP-191 personal message, so the signature can be checked with the
+// wallet tools auditors already use.
+func (r *SetupReport) Sign(key *ecdsa.PrivateKey) error {
+	digest, err := r.Digest()
+	if err != nil {
+		return err
+	}
+	sig, err := crypto.Sign(accounts.TextHash(digest), key)
+	if err != nil {
+		return err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	r.Signer = crypto.PubkeyToAddress(key.PublicKey).Hex()
+	r.Signature = hexutil.Encode(sig)
+	return nil
+}
+
+// CheckSignature checks that the report is signed by its signer and has not
+// been modified since
+func (r *SetupReport) CheckSignature() error {
+	if r.Signature == "" {
+		return fmt.Errorf("setup report is not signed")
+	}
+	sig, err := hexutil.Decode(r.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %s", err.Error())
+	}
+	if len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	sig = common.CopyBytes(sig)
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(digest), sig)
+	if err != nil {
+		return err
+	}
+	if signer := crypto.PubkeyToAddress(*pub); signer != common.HexToAddress(r.Signer) {
+		return fmt.Errorf("setup report is signed by %s, not by %s", signer.Hex(), r.Signer)
+	}
+	return nil
+}
+
+// WriteSetupReport writes the report as JSON to path
+func WriteSetupReport(r *SetupReport, path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	path = os.ExpandEnv(path)
+	if dir := filepath.Dir(path); dir != "" {
+		if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	err = os.WriteFile(path, b, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("setup report written to %s\n", path)
+	return nil
+}
+
+// ReadSetupReport reads a report written by WriteSetupReport
+func ReadSetupReport(path string) (*SetupReport, error) {
+	b, err := os.ReadFile(os.ExpandEnv(path))
+	if err != nil {
+		return nil, err
+	}
+	r := &SetupReport{}
+	err = json.Unmarshal(b, r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid setup report: %s", err.Error())
+	}
+	return r, nil
+}
+
+// VerifySetupCommand runs the setup verification of the app circuit as a
+// command line tool. Since the app circuit is Go code, the tool is built by
+// the app itself, e.g. with
+//
+//	func main() {
+//		if err := sdk.VerifySetupCommand(&AppCircuit{}, os.Args[1:]); err != nil {
+//			fmt.Println(err)
+//			os.Exit(1)
+//		}
+//	}
+//
+// Run it with -h for its flags. An error is returned if the setup does not
+// match the expected one.
+func VerifySetupCommand(app AppCircuit, args []string) error {
+	fs := flag.NewFlagSet("verifysetup", flag.ContinueOnError)
+	srsDir := fs.String("srs-dir", "$HOME/kzgsrs", "dir of the ignition SRS and of the trimmed SRS cache")
+	srsFile := fs.String("srs", "", "SRS file to use instead of the ignition SRS")
+	srsSha256 := fs.String("srs-sha256", "", "hex sha256 checksum of the SRS file")
+	bundle := fs.String("bundle", "", "setup bundle dir written by Compile, to verify its vk and circuit digest")
+	vkFile := fs.String("vk", "", "vk file written by Compile")
+	vkHash := fs.String("vk-hash", "", "hex vk hash registered on chain")
+	tier := fs.String("tier", "", "allocation tier to verify as receipts/storage/transactions, defaults to the allocation of the app circuit")
+	gatewayUrl := fs.String("gateway", "", "Brevis gateway to get the Brevis hash info from, defaults to the public gateway")
+	reportPath := fs.String("report", "setup_report.json", "file the report is written to")
+	keyFile := fs.String("sign-key", "", "file of the hex private key to sign off the report with")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	expected := SetupExpectation{VkFile: os.ExpandEnv(*vkFile)}
+	if *bundle != "" {
+		m, err := ReadSetupManifest(*bundle)
+		if err != nil {
+			return err
+		}
+		expected.CircuitDigest = m.CircuitDigest
+		if expected.VkFile == "" {
+			expected.VkFile = filepath.Join(os.ExpandEnv(*bundle), setupVkFile)
+		}
+	}
+	if *vkHash != "" {
+		h, err := hexutil.Decode(*vkHash)
+		if err != nil {
+			return fmt.Errorf("invalid vk hash: %s", err.Error())
+		}
+		expected.VkHash = h
+	}
+	if *tier != "" {
+		var found bool
+		for _, t := range Tiers(app) {
+			if AllocationOf(t).String() == *tier {
+				app, found = t, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("app circuit has no tier %s", *tier)
+		}
+	}
+	var key *ecdsa.PrivateKey
+	if *keyFile != "" {
+		var err error
+		key, err = crypto.LoadECDSA(os.ExpandEnv(*keyFile))
+		if err != nil {
+			return fmt.Errorf("cannot load sign key: %s", err.Error())
+		}
+	}
+
+	dir := os.ExpandEnv(*srsDir)
+	var source srs.Provider = srs.DefaultProvider(dir)
+	if *srsFile != "" {
+		source = &srs.FileProvider{Path: os.ExpandEnv(*srsFile), Sha256: *srsSha256}
+	}
+	hashInfo, err := NewBrevisHashInfo(*gatewayUrl)
+	if err != nil {
+		return err
+	}
+
+	r, err := VerifySetup(app, &srs.TrimmedCache{Dir: dir, Source: source}, expected, hashInfo)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		if err = r.Sign(key); err != nil {
+			return err
+		}
+		fmt.Printf("setup report signed off by %s\n", r.Signer)
+	}
+	if err = WriteSetupReport(r, *reportPath); err != nil {
+		return err
+	}
+	if !r.Verified {
+		return fmt.Errorf("setup does not match the expected one, see %s", *reportPath)
+	}
+	return nil
+}
+
+func brevisVkHash(vk plonk.VerifyingKey, a Allocation, hashInfo *BrevisHashInfo) (string, error) {
+	h, err := CalcBrevisCircuitDigest(a.MaxReceipts, a.MaxStorage, a.DataPoints()-a.MaxReceipts-a.MaxStorage, vk, hashInfo)
+	if err != nil {
+		return "", err
+	}
+	return common.BigToHash(h).Hex(), nil
+}
+
+func readVk(path string) (plonk.VerifyingKey, error) {
+	b, err := os.ReadFile(os.ExpandEnv(path))
+	if err != nil {
+		return nil, err
+	}
+	vk := plonk.NewVerifyingKey(ecc.BN254)
+	if _, err = vk.ReadFrom(bytes.NewReader(b)); err != nil {
+		return nil, fmt.Errorf("cannot read vk from %s: %s", path, err.Error())
+	}
+	return vk, nil
+}
+
+func keccakOf(w io.WriterTo) (string, error) {
+	h := crypto.NewKeccakState()
+	if _, err := w.WriteTo(h); err != nil {
+		return "", err
+	}
+	return hexutil.Encode(h.Sum(nil)), nil
+}
+
+// rawWriter writes the SRS uncompressed, which is much faster than compressing
+// the points
+type rawWriter struct {
+	s *kzg_bn254.SRS
+}
+
+func (w rawWriter) WriteTo(out io.Writer) (int64, error) {
+	return w.s.WriteRawTo(out)
+}
@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pgoldilocks "github.com/OpenAssetStandards/poseidon-goldilocks-go"
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+func TestVerifySetup(t *testing.T) {
+	// the setup of a real app circuit takes minutes, the verification is the
+	// same for any constraint system
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &squareCircuit{})
+	check(err)
+	a := Allocation{MaxReceipts: 32, MaxTransactions: 32}
+	hashInfo := testHashInfo()
+	testSRS := func(size uint64, alpha int64) srs.Provider {
+		s, err := kzg_bn254.NewSRS(size, big.NewInt(alpha))
+		check(err)
+		return &srs.MemoryProvider{Canonical: s}
+	}
+	_, vk, vkHash, err := SetupWithSRS(ccs, testSRS(8, -1), a.MaxReceipts, a.MaxStorage, a.DataPoints(), hashInfo)
+	check(err)
+	vkFile := filepath.Join(t.TempDir(), "vk")
+	check(WriteTo(vk, vkFile))
+	circuitDigest, err := keccakOf(ccs)
+	check(err)
+
+	expected := SetupExpectation{VkFile: vkFile, VkHash: vkHash, CircuitDigest: circuitDigest}
+	r, err := verifySetup(ccs, a, testSRS(8, -1), expected, hashInfo)
+	check(err)
+	if !r.Verified || len(r.Checks) != 4 || r.SrsSize != 5 || r.DataPoints != 64 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+
+	// the report does not depend on the SRS having more powers than needed
+	r2, err := verifySetup(ccs, a, testSRS(16, -1), expected, hashInfo)
+	check(err)
+	d, err := r.Digest()
+	check(err)
+	d2, err := r2.Digest()
+	check(err)
+	if !bytes.Equal(d, d2) {
+		t.Fatalf("setup report is not reproducible:\n%+v\n%+v", r, r2)
+	}
+
+	r, err = verifySetup(ccs, a, testSRS(8, 2), expected, hashInfo)
+	check(err)
+	if r.Verified || !r.Checks[0].Ok || r.Checks[1].Ok {
+		t.Fatalf("a different SRS should only fail the vk checks, got %+v", r.Checks)
+	}
+	r, err = verifySetup(ccs, a, testSRS(8, -1), SetupExpectation{VkHash: []byte{1}}, hashInfo)
+	check(err)
+	if r.Verified || len(r.Checks) != 1 {
+		t.Fatalf("a different vk hash should fail, got %+v", r.Checks)
+	}
+	_, err = verifySetup(ccs, a, testSRS(8, -1), SetupExpectation{}, hashInfo)
+	if err == nil {
+		t.Fatal("expected an error without an expected vk")
+	}
+
+	// sign off the report
+	key, err := crypto.GenerateKey()
+	check(err)
+	r = r2
+	check(r.Sign(key))
+	reportPath := filepath.Join(t.TempDir(), "report.json")
+	check(WriteSetupReport(r, reportPath))
+	r, err = ReadSetupReport(reportPath)
+	check(err)
+	check(r.CheckSignature())
+	if r.Signer != crypto.PubkeyToAddress(key.PublicKey).Hex() {
+		t.Fatalf("unexpected signer %s", r.Signer)
+	}
+	r.VkHash = "0x01"
+	if err = r.CheckSignature(); err == nil || !strings.Contains(err.Error(), "not by") {
+		t.Fatalf("a modified report should fail the signature check, got %v", err)
+	}
+}
+
+func testHashInfo() *BrevisHashInfo {
+	return &BrevisHashInfo{
+		P2AggRecursionLeafCircuitDigestHash:                  &pgoldilocks.HashOut256{1, 2, 3, 4},
+		P2AggRecursionMiddleFormMiddleLeafCircuitDigestHash:  &pgoldilocks.HashOut256{5, 6, 7, 8},
+		P2AggRecursionNoLeafCircuitDigestHash:                &pgoldilocks.HashOut256{9, 10, 11, 12},
+		P2Bn128WrapCircuitDigestHashForOnly2Leaf:             big.NewInt(13),
+		P2Bn128WrapCircuitDigestHashForOnlyFromLeafRecursion: big.NewInt(14),
+		P2Bn128WrapCircuitDigestHash:                         big.NewInt(15),
+		GnarkReceiptVkHash:                                   big.NewInt(16),
+		GnarkStorageVkHash:                                   big.NewInt(17),
+		GnarkTxVkHash:                                        big.NewInt(18),
+		GnarkMiddleNodeVkHash:                                big.NewInt(19),
+	}
+}
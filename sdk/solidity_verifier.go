@@ -0,0 +1,57 @@
+package sdk
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+)
+
+// ExportSolidityVerifier writes the Solidity verifier contract of the vk, i.e.
+// the PlonkVerifier contract of gnark, to path. The contract verifies proofs of
+// the app circuit without the Brevis gateway, e.g. for internal tooling and
+// testing. Its Verify function is called with SolidityVerifyCalldata.
+//
+// The contract derives the Fiat-Shamir challenges with SHA-256, so it only
+// accepts proofs generated by ProveForSolidity, not the proofs of Prove or of
+// the prover service, which are generated for the recursive verification of
+// Brevis, unless the service is configured with ProveForSolidity.
+func ExportSolidityVerifier(vk plonk.VerifyingKey, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if err = vk.ExportSolidity(w); err != nil {
+		return fmt.Errorf("failed to export solidity verifier: %s", err.Error())
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("solidity verifier exported to %s\n", path)
+	return nil
+}
+
+// ProveForSolidity is like Prove, but the proof is generated with the default
+// options of gnark, which the contract of ExportSolidityVerifier verifies
+func ProveForSolidity(ccs constraint.ConstraintSystem, pk plonk.ProvingKey, w witness.Witness, hints ...solver.Hint) (plonk.Proof, error) {
+	fmt.Println(">> prove for solidity")
+
+	return plonk.Prove(ccs, pk, w, backend.WithSolverOptions(solver.WithHints(hints...)))
+}
+
+// SolidityVerifyCalldata returns the calldata of the Verify function of the
+// contract of ExportSolidityVerifier for the proof and its public witness
+func SolidityVerifyCalldata(proof plonk.Proof, publicWitness witness.Witness) ([]byte, error) {
+	return (&ExportedProof{Proof: proof, PublicWitness: publicWitness}).SolidityCalldata()
+}
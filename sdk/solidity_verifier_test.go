@@ -0,0 +1,164 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// The Solidity generated by the tests, i.e. the verifier of the test vk and the
+// output decoder of the test schema, is committed in testdata along with the
+// artifacts of the contracts, compiled with the solc pinned by internal/solcjs,
+// so that the tests run the contracts without solc. Regenerate them when the
+// generated code changes.
+//go:generate go test -run TestSolidityVerifier|TestSolidityDecoder -update-solidity
+//go:generate go run ./internal/solcjs -root testdata -o testdata PlonkVerifier.sol TestOutputDecoder.sol
+
+var updateSolidity = flag.Bool("update-solidity", false, "write the Solidity generated by the tests to testdata")
+
+const (
+	testVerifierPath     = "testdata/PlonkVerifier.sol"
+	testVerifierArtifact = "testdata/PlonkVerifier.json"
+)
+
+func TestSolidityVerifier(t *testing.T) {
+	ccs, pk, vk := testFixedPkSetup(&exportCircuit{})
+	path := filepath.Join(t.TempDir(), "contracts", "PlonkVerifier.sol")
+	check(ExportSolidityVerifier(vk, path))
+	src, err := os.ReadFile(path)
+	check(err)
+	if !checkGeneratedSolidity(t, testVerifierPath, string(src)) {
+		return
+	}
+
+	w, err := frontend.NewWitness(&exportCircuit{X: 3, Y: 9}, ecc.BN254.ScalarField())
+	check(err)
+	wpub, err := w.Public()
+	check(err)
+	proof, err := ProveForSolidity(ccs, pk, w)
+	check(err)
+	check(plonk.Verify(proof, vk, wpub))
+
+	verifier := deployTestContract(t, testVerifierArtifact)
+	defer verifier.close()
+	verify := func(proof plonk.Proof) bool {
+		calldata, err := SolidityVerifyCalldata(proof, wpub)
+		check(err)
+		out, err := verifier.call(calldata)
+		if err != nil {
+			// the verifier reverts on malformed proofs
+			return false
+		}
+		res, err := verifier.abi.Unpack("Verify", out)
+		check(err)
+		return res[0].(bool)
+	}
+	if !verify(proof) {
+		t.Fatal("the proof should verify on chain")
+	}
+	// proofs of Prove use other Fiat-Shamir hashes
+	proof, err = Prove(ccs, pk, w)
+	check(err)
+	if verify(proof) {
+		t.Fatal("proofs of Prove should not verify on chain")
+	}
+}
+
+// testFixedPkSetup is like testPkSetup, but the SRS is generated from a fixed
+// tau, so that the vk and its verifier are the same on every run
+func testFixedPkSetup(circuit frontend.Circuit) (constraint.ConstraintSystem, plonk.ProvingKey, plonk.VerifyingKey) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	check(err)
+	size := ecc.NextPowerOfTwo(uint64(ccs.GetNbConstraints() + ccs.GetNbPublicVariables()))
+	canonical, err := kzg.NewSRS(size+3, big.NewInt(42))
+	check(err)
+	lagrangeG1, err := kzg.ToLagrangeG1(canonical.Pk.G1[:size])
+	check(err)
+	lagrange := &kzg.SRS{Pk: kzg.ProvingKey{G1: lagrangeG1}, Vk: canonical.Vk}
+	pk, vk, err := plonk.Setup(ccs, canonical, lagrange)
+	check(err)
+	return ccs, pk, vk
+}
+
+// checkGeneratedSolidity writes the generated code to path with
+// -update-solidity and returns false. Otherwise it checks that the code matches
+// the committed one and returns true.
+func checkGeneratedSolidity(t *testing.T, path, code string) bool {
+	t.Helper()
+	if *updateSolidity {
+		check(os.MkdirAll(filepath.Dir(path), 0755))
+		check(os.WriteFile(path, []byte(code), 0644))
+		return false
+	}
+	committed, err := os.ReadFile(path)
+	check(err)
+	if code != string(committed) {
+		t.Fatalf("the generated code does not match %s, run go generate ./sdk", path)
+	}
+	return true
+}
+
+// testContract is a contract deployed on a simulated backend
+type testContract struct {
+	backend *simulated.Backend
+	from    common.Address
+	addr    common.Address
+	abi     abi.ABI
+}
+
+// deployTestContract deploys the contract of an artifact written by
+// internal/solcjs on a new simulated backend
+func deployTestContract(t *testing.T, artifactPath string) *testContract {
+	t.Helper()
+	b, err := os.ReadFile(artifactPath)
+	check(err)
+	var artifact struct {
+		ABI      json.RawMessage `json:"abi"`
+		Bytecode string          `json:"bytecode"`
+	}
+	check(json.Unmarshal(b, &artifact))
+	parsed, err := abi.JSON(strings.NewReader(string(artifact.ABI)))
+	check(err)
+
+	key, err := crypto.GenerateKey()
+	check(err)
+	c := &testContract{from: crypto.PubkeyToAddress(key.PublicKey), abi: parsed}
+	c.backend = simulated.NewBackend(types.GenesisAlloc{c.from: {Balance: big.NewInt(1e18)}})
+	// calls on the genesis block run before the merge and reject PUSH0
+	c.backend.Commit()
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	check(err)
+	c.addr, _, _, err = bind.DeployContract(opts, parsed, common.FromHex(artifact.Bytecode), c.backend.Client())
+	check(err)
+	c.backend.Commit()
+	return c
+}
+
+// call calls the contract with the calldata and returns its return data
+func (c *testContract) call(calldata []byte) ([]byte, error) {
+	msg := ethereum.CallMsg{From: c.from, To: &c.addr, Data: calldata}
+	return c.backend.Client().CallContract(context.Background(), msg, nil)
+}
+
+func (c *testContract) close() {
+	c.backend.Close()
+}
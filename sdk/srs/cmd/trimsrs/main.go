@@ -0,0 +1,61 @@
+// Command trimsrs extracts the SRS a compiled circuit needs from the ignition
+// SRS, or from another SRS file, and saves it along with its lagrange form to a
+// trimmed SRS cache dir (see srs.TrimmedCache). The cache dir can then be used
+// as the SRS dir of sdk.Setup or of the prover service, e.g. on a machine
+// without internet access. The trimmed files are named after the checksum of
+// the SRS, so the SRS source that uses the cache dir must be given the same
+// checksum, e.g. the srs_sha256 of the prover service.
+//
+// Usage:
+//
+//	trimsrs -circuit $HOME/circuitOut/compiledCircuit -out $HOME/kzgsrs
+//	trimsrs -circuit compiledCircuit -srs /mnt/mirror/kzg_srs_100800000_bn254_MAIN_IGNITION -sha256 <hex> -out ./srs
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/brevis-network/brevis-sdk/sdk/srs"
+	cs_bn254 "github.com/consensys/gnark/constraint/bn254"
+)
+
+func main() {
+	circuit := flag.String("circuit", "", "compiled circuit file, e.g. the compiledCircuit file of a setup bundle")
+	srsFile := flag.String("srs", "", "SRS file to extract from, the ignition SRS is downloaded to the out dir if empty")
+	sha256 := flag.String("sha256", "", "hex sha256 checksum of the SRS file, optional")
+	out := flag.String("out", "", "trimmed SRS cache dir")
+	flag.Parse()
+	if *circuit == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := trim(os.ExpandEnv(*circuit), os.ExpandEnv(*srsFile), *sha256, os.ExpandEnv(*out))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func trim(circuit, srsFile, sha256, out string) error {
+	f, err := os.Open(circuit)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ccs := new(cs_bn254.SparseR1CS)
+	_, err = ccs.ReadFrom(bufio.NewReaderSize(f, 1<<20))
+	if err != nil {
+		return fmt.Errorf("cannot read compiled circuit %s: %w", circuit, err)
+	}
+
+	var source srs.Provider = srs.DefaultProvider(out)
+	if srsFile != "" {
+		source = &srs.FileProvider{Path: srsFile, Sha256: sha256}
+	}
+	_, _, err = srs.Trim(ccs, source, out)
+	return err
+}
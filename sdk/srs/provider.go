@@ -0,0 +1,271 @@
+package srs
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/kzg"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+// DefaultURL is the URL of the Aztec ignition SRS mirrored by Brevis
+const DefaultURL = "https://kzg-srs.s3.us-west-2.amazonaws.com/" + key
+
+// ignitionMD5 is the checksum of the file at DefaultURL. Its sha256 has never
+// been published along with it, so the default provider keeps checking the MD5.
+const ignitionMD5 = "2abd249241a7fe883379db93530365f8"
+
+// Provider provides the canonical KZG SRS of BN254 used in the PLONK setup
+type Provider interface {
+	// SRS returns a canonical SRS with at least size G1 points
+	SRS(size uint64) (kzg.SRS, error)
+}
+
+// DefaultProvider downloads the ignition SRS from DefaultURL to cacheDir, or
+// reads it from there if it has already been downloaded
+func DefaultProvider(cacheDir string) Provider {
+	return &HTTPProvider{URL: DefaultURL, CacheDir: cacheDir, md5: ignitionMD5}
+}
+
+// FileProvider reads the SRS from a local file, e.g. a copy of the ignition SRS
+// on a mirror or a trimmed SRS that only has the powers a circuit needs.
+type FileProvider struct {
+	Path string
+	// Sha256 is the hex sha256 checksum of the file. The file is not verified if
+	// it is empty.
+	Sha256 string
+}
+
+func (p *FileProvider) SRS(size uint64) (kzg.SRS, error) {
+	path, err := p.localFile()
+	if err != nil {
+		return nil, err
+	}
+	fsLock.RLock()
+	defer fsLock.RUnlock()
+	return readSRS(path, size)
+}
+
+func (p *FileProvider) checksum() string {
+	return normalizeChecksum(p.Sha256)
+}
+
+func (p *FileProvider) localFile() (string, error) {
+	if p.Sha256 != "" {
+		fsLock.RLock()
+		defer fsLock.RUnlock()
+		err := verifyFile(p.Path, sha256.New(), p.Sha256)
+		if err != nil {
+			return "", err
+		}
+	}
+	return p.Path, nil
+}
+
+// HTTPProvider downloads the SRS from a URL, e.g. an alternate mirror, and
+// caches it in a directory. An interrupted download is resumed from where it
+// stopped. The downloaded file is verified against its sha256 checksum before
+// it is used.
+type HTTPProvider struct {
+	URL string
+	// CacheDir is where the file is downloaded to, under the last element of the
+	// URL path
+	CacheDir string
+	// Sha256 is the hex sha256 checksum of the file
+	Sha256 string
+	// Client is used for the download, defaults to http.DefaultClient
+	Client *http.Client
+
+	md5 string
+}
+
+var downloadLock sync.Mutex
+
+func (p *HTTPProvider) SRS(size uint64) (kzg.SRS, error) {
+	filePath, err := p.localFile()
+	if err != nil {
+		return nil, err
+	}
+	fsLock.RLock()
+	defer fsLock.RUnlock()
+	return readSRS(filePath, size)
+}
+
+func (p *HTTPProvider) checksum() string {
+	if p.Sha256 != "" {
+		return normalizeChecksum(p.Sha256)
+	}
+	return p.md5
+}
+
+// localFile downloads the file unless it has already been
+func (p *HTTPProvider) localFile() (string, error) {
+	if p.Sha256 == "" && p.md5 == "" {
+		return "", fmt.Errorf("no sha256 checksum given for the SRS at %s", p.URL)
+	}
+	err := os.MkdirAll(p.CacheDir, 0700)
+	if err != nil {
+		return "", fmt.Errorf("cannot create srs cache dir: %w", err)
+	}
+	filePath := filepath.Join(p.CacheDir, path.Base(p.URL))
+
+	downloadLock.Lock()
+	defer downloadLock.Unlock()
+	if p.verify(filePath) != nil {
+		fmt.Println("srs not found in", filePath)
+		err = p.download(filePath)
+		if err != nil {
+			return "", err
+		}
+	}
+	return filePath, nil
+}
+
+func (p *HTTPProvider) verify(filePath string) error {
+	if p.Sha256 != "" {
+		return verifyFile(filePath, sha256.New(), p.Sha256)
+	}
+	return verifyFile(filePath, md5.New(), p.md5)
+}
+
+// download downloads the file to filePath through a .part file, which is kept
+// when the download fails so that the next download resumes from it
+func (p *HTTPProvider) download(filePath string) error {
+	partPath := filePath + ".part"
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", partPath, err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		fmt.Printf("resuming download of %s from byte %d\n", p.URL, offset)
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		fmt.Println("downloading file", p.URL)
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", p.URL, err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server does not support ranges, start over
+		if err = f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the part file is already complete
+	default:
+		return fmt.Errorf("failed to download %s: %s", p.URL, res.Status)
+	}
+	if res.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		if _, err = io.Copy(f, res.Body); err != nil {
+			return fmt.Errorf("failed to download %s: %w", p.URL, err)
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = p.verify(partPath); err != nil {
+		// the part file cannot be resumed from
+		os.Remove(partPath)
+		return fmt.Errorf("downloaded %s: %w", p.URL, err)
+	}
+	fsLock.Lock()
+	defer fsLock.Unlock()
+	return os.Rename(partPath, filePath)
+}
+
+// MemoryProvider provides an SRS that is already loaded, e.g. one generated for
+// tests with unsafekzg
+type MemoryProvider struct {
+	Canonical kzg.SRS
+}
+
+func (p *MemoryProvider) SRS(size uint64) (kzg.SRS, error) {
+	err := checkSize(p.Canonical, size)
+	if err != nil {
+		return nil, err
+	}
+	return p.Canonical, nil
+}
+
+func verifyFile(filePath string, h hash.Hash, checksum string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(h, bufio.NewReaderSize(f, 1<<20)); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != normalizeChecksum(checksum) {
+		return fmt.Errorf("invalid checksum of %s: %s, expected %s", filePath, actual, checksum)
+	}
+	return nil
+}
+
+func normalizeChecksum(checksum string) string {
+	return strings.TrimPrefix(strings.ToLower(checksum), "0x")
+}
+
+func readSRS(filePath string, size uint64) (kzg.SRS, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	kzgSrs := kzg.NewSRS(curveID)
+	_, err = kzgSrs.UnsafeReadFrom(bufio.NewReaderSize(f, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read srs from %s: %w", filePath, err)
+	}
+	err = checkSize(kzgSrs, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return kzgSrs, nil
+}
+
+func checkSize(s kzg.SRS, size uint64) error {
+	bn254Srs, ok := s.(*kzg_bn254.SRS)
+	if !ok {
+		return fmt.Errorf("srs is not of curve %s", curveID)
+	}
+	if uint64(len(bn254Srs.Pk.G1)) < size {
+		return fmt.Errorf("srs has %d G1 points but the circuit needs %d", len(bn254Srs.Pk.G1), size)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package srs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+const testSRSSize = 40
+
+func testSRS(t *testing.T) (*kzg_bn254.SRS, []byte, string) {
+	// alpha = -1 makes the generation fast
+	return testSRSOf(t, big.NewInt(-1))
+}
+
+func testSRSOf(t *testing.T, alpha *big.Int) (*kzg_bn254.SRS, []byte, string) {
+	s, err := kzg_bn254.NewSRS(testSRSSize, alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = s.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return s, buf.Bytes(), hex.EncodeToString(sum[:])
+}
+
+func TestHTTPProvider(t *testing.T) {
+	_, content, checksum := testSRS(t)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "srs", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// an interrupted download left half of the file
+	dir := t.TempDir()
+	partPath := filepath.Join(dir, "srs.part")
+	if err := os.WriteFile(partPath, content[:len(content)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &HTTPProvider{URL: server.URL + "/srs", CacheDir: dir, Sha256: checksum}
+	s, err := p.SRS(testSRSSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.(*kzg_bn254.SRS).Pk.G1) != testSRSSize {
+		t.Fatal("unexpected srs size")
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes="+strconv.Itoa(len(content)/2)+"-" {
+		t.Fatalf("expected the download to resume, got ranges %v", ranges)
+	}
+	if _, err = os.Stat(partPath); !os.IsNotExist(err) {
+		t.Fatal("the part file should be renamed")
+	}
+
+	// the downloaded file is reused
+	_, err = p.SRS(testSRSSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 1 {
+		t.Fatalf("expected no download, got ranges %v", ranges)
+	}
+
+	_, err = p.SRS(testSRSSize + 1)
+	expectErr(t, err, "but the circuit needs")
+
+	// the download is verified
+	p = &HTTPProvider{URL: server.URL + "/srs", CacheDir: t.TempDir(), Sha256: strings.Repeat("0", 64)}
+	_, err = p.SRS(testSRSSize)
+	expectErr(t, err, "invalid checksum")
+	if _, err = os.Stat(filepath.Join(p.CacheDir, "srs.part")); !os.IsNotExist(err) {
+		t.Fatal("the invalid part file should be removed")
+	}
+
+	_, err = (&HTTPProvider{URL: server.URL + "/srs", CacheDir: t.TempDir()}).SRS(testSRSSize)
+	expectErr(t, err, "no sha256 checksum")
+}
+
+func TestFileAndMemoryProviders(t *testing.T) {
+	s, content, checksum := testSRS(t)
+	path := filepath.Join(t.TempDir(), "srs")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []Provider{&FileProvider{Path: path, Sha256: checksum}, &FileProvider{Path: path}, &MemoryProvider{Canonical: s}} {
+		if _, err := p.SRS(testSRSSize); err != nil {
+			t.Fatal(err)
+		}
+		_, err := p.SRS(testSRSSize + 1)
+		expectErr(t, err, "but the circuit needs")
+	}
+	_, err := (&FileProvider{Path: path, Sha256: strings.Repeat("0", 64)}).SRS(testSRSSize)
+	expectErr(t, err, "invalid checksum")
+
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, lagrange, err := NewSRSFrom(ccs, &MemoryProvider{Canonical: s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canonical != s || len(lagrange.(*kzg_bn254.SRS).Pk.G1) != 2 {
+		t.Fatalf("unexpected lagrange size %d", len(lagrange.(*kzg_bn254.SRS).Pk.G1))
+	}
+}
+
+func expectErr(t *testing.T, err error, contains string) {
+	t.Helper()
+	if err == nil || !strings.Contains(err.Error(), contains) {
+		t.Fatalf("expected error containing %q, got %v", contains, err)
+	}
+}
+
+type squareCircuit struct {
+	X, Y frontend.Variable
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
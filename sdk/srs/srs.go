@@ -1,21 +1,13 @@
 package srs
 
 import (
-	"bufio"
-	"bytes"
 	"crypto/md5"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
 	"sync"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark-crypto/kzg"
 	"github.com/consensys/gnark/constraint"
-	"github.com/ethereum/go-ethereum/common/hexutil"
 
 	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
 )
@@ -27,30 +19,33 @@ var (
 	fsLock sync.RWMutex
 )
 
+// NewSRS returns the canonical and lagrange SRS of the constraint system from
+// the ignition SRS, which is downloaded to fsCacheDir if it isn't there yet
 func NewSRS(ccs constraint.ConstraintSystem, fsCacheDir string) (canonical, lagrange kzg.SRS, err error) {
-	nbConstraints := ccs.GetNbConstraints()
-	sizeSystem := nbConstraints + ccs.GetNbPublicVariables()
-	fmt.Println("size system", sizeSystem)
-	sizeLagrange := ecc.NextPowerOfTwo(uint64(sizeSystem))
-	fmt.Println("size lagrange", sizeLagrange)
+	return NewSRSFrom(ccs, DefaultProvider(fsCacheDir))
+}
 
-	initDir(fsCacheDir)
+// NewSRSFrom returns the canonical and lagrange SRS of the constraint system
+// from the SRS of the provider
+func NewSRSFrom(ccs constraint.ConstraintSystem, provider Provider) (canonical, lagrange kzg.SRS, err error) {
+	sizeLagrange := lagrangeSize(ccs)
+	fmt.Println("size lagrange", sizeLagrange)
 
-	fmt.Println("fetching srs ignition from file")
-	filePath := filepath.Join(fsCacheDir, key)
-	fsLock.RLock()
-	srsIgnition, err := ReadFile(filePath)
-	fsLock.RUnlock()
-	if err == nil {
-		return generateLagrange(srsIgnition, sizeLagrange)
+	if p, ok := provider.(LagrangeProvider); ok {
+		return p.LagrangeSRS(sizeLagrange)
 	}
-
-	fmt.Println("srs ignition not found in file")
-	srsIgnition, err = downloadSRSIgnition(filePath)
-	if err == nil {
-		return generateLagrange(srsIgnition, sizeLagrange)
+	// the PLONK setup needs 3 more powers than the domain size for blinding
+	srs, err := provider.SRS(sizeLagrange + 3)
+	if err != nil {
+		return nil, nil, err
 	}
-	return nil, nil, err
+	return generateLagrange(srs, sizeLagrange)
+}
+
+// lagrangeSize returns the size of the PLONK domain of the constraint system
+func lagrangeSize(ccs constraint.ConstraintSystem) uint64 {
+	sizeSystem := ccs.GetNbConstraints() + ccs.GetNbPublicVariables()
+	return ecc.NextPowerOfTwo(uint64(sizeSystem))
 }
 
 func generateLagrange(srsIgnition kzg.SRS, sizeLagrange uint64) (kzg.SRS, kzg.SRS, error) {
@@ -66,86 +61,13 @@ func generateLagrange(srsIgnition kzg.SRS, sizeLagrange uint64) (kzg.SRS, kzg.SR
 	return bn254Srs, lagrangeSRS, nil
 }
 
-func downloadSRSIgnition(filePath string) (kzg.SRS, error) {
-	url := fmt.Sprintf("https://kzg-srs.s3.us-west-2.amazonaws.com/%s", key)
-	fmt.Println("downloading file", url)
-
-	// Saving downloading time with curl command
-	cmd := exec.Command("curl", "-O", filePath, url)
-	err := cmd.Run()
-	if err == nil {
-		return ReadFile(filePath)
-	}
-
-	res, err := http.Get(url)
-	if err != nil {
-		return kzg.NewSRS(curveID), err
-	}
-	defer res.Body.Close()
-
-	f, err := os.Create(filePath)
-	if err != nil {
-		panic("cannot create file")
-	}
-	fmt.Println("writing srs ignition file")
-	fsLock.RLock()
-	_, err = io.Copy(f, res.Body)
-	fsLock.RUnlock()
-
-	if err != nil {
-		panic("cannot save srs ignition file")
-	}
-
-	return ReadFile(filePath)
-}
-
-func validateFile(filePath string) error {
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("file %s does not exist", filePath)
-	}
-
-	f, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	buf := bytes.NewBuffer([]byte{})
-	buf.ReadFrom(f)
-
-	md5Original := md5.Sum(buf.Bytes())
-	if hexutil.Encode(md5Original[:]) != "0x2abd249241a7fe883379db93530365f8" {
-		return fmt.Errorf("invalid checksum of local file")
-	}
-	return nil
-}
-
+// ReadFile reads the ignition SRS from a file
 func ReadFile(filePath string) (kzg.SRS, error) {
-	err := validateFile(filePath)
-	if err != nil {
-		return nil, err
-	}
-	f, err := os.Open(filePath)
+	fsLock.RLock()
+	defer fsLock.RUnlock()
+	err := verifyFile(filePath, md5.New(), ignitionMD5)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	r := bufio.NewReaderSize(f, 1<<20)
-
-	kzgSrs := kzg.NewSRS(curveID)
-	kzgSrs.UnsafeReadFrom(r)
-
-	return kzgSrs, nil
-}
-
-func initDir(cacheDir string) {
-	// populate cache from disk
-	fmt.Println("init SRS disk cache dir", cacheDir)
-
-	if _, err := os.Stat(cacheDir); os.IsNotExist(err) {
-		err := os.MkdirAll(cacheDir, 0700)
-		if err != nil {
-			panic(err)
-		}
-	}
+	return readSRS(filePath, 0)
 }
@@ -0,0 +1,328 @@
+package srs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/constraint"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+// LagrangeProvider provides the canonical SRS along with its lagrange form, so
+// that NewSRSFrom doesn't have to compute it
+type LagrangeProvider interface {
+	// LagrangeSRS returns the canonical SRS of sizeLagrange+3 G1 points and the
+	// lagrange SRS of sizeLagrange G1 points
+	LagrangeSRS(sizeLagrange uint64) (canonical, lagrange kzg.SRS, err error)
+}
+
+// TrimmedCache provides the SRS trimmed to the size of each circuit, along with
+// its lagrange form, from a file per lagrange size in Dir. The file of a size is
+// extracted from the SRS of Source the first time it is needed. Only the needed
+// powers are read if Source is a FileProvider or an HTTPProvider, so that the
+// full ignition SRS is never loaded in memory.
+//
+// The files are named after the SRS of Source and start with its checksum, so a
+// cache dir can be shared by several SRS, and a file trimmed from another SRS is
+// rejected and extracted again. The SRS is identified by the checksum the source
+// verifies its file against, or else by the sha256 of its verifying key, which
+// determines all its powers. Reading the files does not need Source to be
+// available, unless it is a FileProvider without checksum or a provider that is
+// not a file.
+type TrimmedCache struct {
+	Dir    string
+	Source Provider
+}
+
+// localSource is a provider that reads the SRS from a local file
+type localSource interface {
+	localFile() (string, error)
+}
+
+// Trim extracts the SRS the constraint system needs from the SRS of source and
+// saves it to the trimmed cache in dir, see TrimmedCache
+func Trim(ccs constraint.ConstraintSystem, source Provider, dir string) (canonical, lagrange kzg.SRS, err error) {
+	c := &TrimmedCache{Dir: dir, Source: source}
+	canonical, lagrange, err = NewSRSFrom(ccs, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	path, err := c.File(lagrangeSize(ccs))
+	if err != nil {
+		return nil, nil, err
+	}
+	// TrimmedCache carries on when the file cannot be saved
+	if _, err = os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("trimmed srs was not saved: %w", err)
+	}
+	return canonical, lagrange, nil
+}
+
+// File returns the file of the trimmed SRS of the lagrange size in Dir
+func (c *TrimmedCache) File(sizeLagrange uint64) (string, error) {
+	id, err := c.sourceID()
+	if err != nil {
+		return "", err
+	}
+	return trimmedFile(c.Dir, sizeLagrange, id), nil
+}
+
+func trimmedFile(dir string, sizeLagrange uint64, sourceID string) string {
+	return filepath.Join(dir, fmt.Sprintf("kzg_srs_lagrange_%d_bn254_%s", sizeLagrange, sourceID[:min(16, len(sourceID))]))
+}
+
+// checksummedSource is a provider that verifies its SRS file against a checksum
+type checksummedSource interface {
+	checksum() string
+}
+
+// sourceID identifies the SRS of Source in the trimmed files, see TrimmedCache
+func (c *TrimmedCache) sourceID() (string, error) {
+	if src, ok := c.Source.(checksummedSource); ok {
+		if sum := src.checksum(); sum != "" {
+			return sum, nil
+		}
+	}
+	var vk kzg_bn254.VerifyingKey
+	if src, ok := c.Source.(localSource); ok {
+		path, err := src.localFile()
+		if err != nil {
+			return "", err
+		}
+		fsLock.RLock()
+		defer fsLock.RUnlock()
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		srs, err := ReadTrimmed(f, 0)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", path, err)
+		}
+		vk = srs.Vk
+	} else {
+		srs, err := c.Source.SRS(0)
+		if err != nil {
+			return "", err
+		}
+		bn254Srs, ok := srs.(*kzg_bn254.SRS)
+		if !ok {
+			return "", fmt.Errorf("srs is not of curve %s", curveID)
+		}
+		vk = bn254Srs.Vk
+	}
+	h := sha256.New()
+	if _, err := vk.WriteRawTo(h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SRS returns the SRS of Source trimmed to size, without caching it
+func (c *TrimmedCache) SRS(size uint64) (kzg.SRS, error) {
+	return c.trimmedSource(size)
+}
+
+func (c *TrimmedCache) LagrangeSRS(sizeLagrange uint64) (kzg.SRS, kzg.SRS, error) {
+	id, err := c.sourceID()
+	if err != nil {
+		return nil, nil, err
+	}
+	path := trimmedFile(c.Dir, sizeLagrange, id)
+	fsLock.RLock()
+	canonical, lagrange, err := readTrimmedFile(path, id, sizeLagrange)
+	fsLock.RUnlock()
+	if err == nil {
+		fmt.Println("trimmed srs read from", path)
+		return canonical, lagrange, nil
+	}
+	if !os.IsNotExist(err) {
+		fmt.Printf("cannot read trimmed srs, extracting it again: %s\n", err.Error())
+	}
+
+	srs, err := c.trimmedSource(sizeLagrange + 3)
+	if err != nil {
+		return nil, nil, err
+	}
+	canonical, lagrange, err = generateLagrange(srs, sizeLagrange)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the cache only saves time, e.g. the dir may be read-only on a build machine
+	err = writeTrimmedFile(path, id, canonical.(*kzg_bn254.SRS), lagrange.(*kzg_bn254.SRS))
+	if err != nil {
+		fmt.Printf("cannot save trimmed srs to %s: %s\n", path, err.Error())
+	}
+	return canonical, lagrange, nil
+}
+
+func (c *TrimmedCache) trimmedSource(size uint64) (kzg.SRS, error) {
+	if src, ok := c.Source.(localSource); ok {
+		path, err := src.localFile()
+		if err != nil {
+			return nil, err
+		}
+		fsLock.RLock()
+		defer fsLock.RUnlock()
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		fmt.Printf("extracting %d srs powers from %s\n", size, path)
+		srs, err := ReadTrimmed(f, size)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return srs, nil
+	}
+
+	srs, err := c.Source.SRS(size)
+	if err != nil {
+		return nil, err
+	}
+	full := srs.(*kzg_bn254.SRS)
+	trimmed := &kzg_bn254.SRS{Vk: full.Vk}
+	trimmed.Pk.G1 = append([]bn254.G1Affine{}, full.Pk.G1[:size]...)
+	return trimmed, nil
+}
+
+// ReadTrimmed reads the first size G1 points and the verifying key of an SRS
+// written by kzg.SRS.WriteTo or WriteRawTo, skipping the other points. Only the
+// verifying key is read if size is 0. The
+// points are not checked to be in the subgroup, like with kzg.SRS.UnsafeReadFrom.
+func ReadTrimmed(r io.Reader, size uint64) (*kzg_bn254.SRS, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	total := uint64(binary.BigEndian.Uint32(header[:]))
+	if total < size {
+		return nil, fmt.Errorf("srs has %d G1 points but the circuit needs %d", total, size)
+	}
+	srs := &kzg_bn254.SRS{}
+	if total > 0 {
+		// all the points of an SRS file have the same encoding, and the two most
+		// significant bits of a compressed point are never both 0
+		first := make([]byte, bn254.SizeOfG1AffineCompressed)
+		if _, err := io.ReadFull(r, first); err != nil {
+			return nil, err
+		}
+		pointSize := uint64(bn254.SizeOfG1AffineCompressed)
+		if first[0]>>6 == 0 {
+			pointSize = bn254.SizeOfG1AffineUncompressed
+		}
+		read := uint64(len(first))
+		if size > 0 {
+			buf := make([]byte, 4+size*pointSize)
+			binary.BigEndian.PutUint32(buf, uint32(size))
+			copy(buf[4:], first)
+			if _, err := io.ReadFull(r, buf[4+len(first):]); err != nil {
+				return nil, err
+			}
+			dec := bn254.NewDecoder(bytes.NewReader(buf), bn254.NoSubgroupChecks())
+			if err := dec.Decode(&srs.Pk.G1); err != nil {
+				return nil, err
+			}
+			read = size * pointSize
+		}
+		if err := skip(r, int64(total*pointSize-read)); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := srs.Vk.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return srs, nil
+}
+
+func skip(r io.Reader, n int64) error {
+	if s, ok := r.(io.Seeker); ok {
+		_, err := s.Seek(n, io.SeekCurrent)
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r, n)
+	return err
+}
+
+// The trimmed file has the id of the source SRS, prefixed by its length, then
+// the canonical SRS followed by the G1 points of the lagrange SRS, both
+// uncompressed, which is much faster to read
+func writeTrimmedFile(path, sourceID string, canonical, lagrange *kzg_bn254.SRS) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if _, err = f.Write(append([]byte{byte(len(sourceID))}, sourceID...)); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = canonical.WriteRawTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = lagrange.Pk.WriteRawTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	fsLock.Lock()
+	defer fsLock.Unlock()
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	fmt.Println("trimmed srs written to", path)
+	return nil
+}
+
+func readTrimmedFile(path, sourceID string, sizeLagrange uint64) (kzg.SRS, kzg.SRS, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReaderSize(f, 1<<20)
+	n, err := br.ReadByte()
+	if err != nil {
+		return nil, nil, err
+	}
+	id := make([]byte, n)
+	if _, err = io.ReadFull(br, id); err != nil {
+		return nil, nil, err
+	}
+	if string(id) != sourceID {
+		return nil, nil, fmt.Errorf("%s was trimmed from another srs: %s, expected %s", path, id, sourceID)
+	}
+	canonical := &kzg_bn254.SRS{}
+	lagrange := &kzg_bn254.SRS{}
+	if _, err = canonical.UnsafeReadFrom(br); err != nil {
+		return nil, nil, err
+	}
+	if _, err = lagrange.Pk.UnsafeReadFrom(br); err != nil {
+		return nil, nil, err
+	}
+	lagrange.Vk = canonical.Vk
+	if uint64(len(canonical.Pk.G1)) != sizeLagrange+3 || uint64(len(lagrange.Pk.G1)) != sizeLagrange {
+		return nil, nil, fmt.Errorf("%s has %d canonical and %d lagrange points, expected %d and %d",
+			path, len(canonical.Pk.G1), len(lagrange.Pk.G1), sizeLagrange+3, sizeLagrange)
+	}
+	return canonical, lagrange, nil
+}
@@ -0,0 +1,162 @@
+package srs
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+
+	kzg_bn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+)
+
+func TestReadTrimmed(t *testing.T) {
+	s, compressed, _ := testSRS(t)
+	raw := &bytes.Buffer{}
+	if _, err := s.WriteRawTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	for name, r := range map[string]func() io.Reader{
+		"compressed":          func() io.Reader { return bytes.NewReader(compressed) },
+		"raw":                 func() io.Reader { return bytes.NewReader(raw.Bytes()) },
+		"compressed, no seek": func() io.Reader { return io.MultiReader(bytes.NewReader(compressed)) },
+	} {
+		trimmed, err := ReadTrimmed(r(), 10)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !reflect.DeepEqual(trimmed.Pk.G1, s.Pk.G1[:10]) || !reflect.DeepEqual(trimmed.Vk, s.Vk) {
+			t.Fatalf("%s: trimmed srs does not match", name)
+		}
+	}
+	vkOnly, err := ReadTrimmed(bytes.NewReader(raw.Bytes()), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vkOnly.Pk.G1) != 0 || !reflect.DeepEqual(vkOnly.Vk, s.Vk) {
+		t.Fatal("expected only the verifying key to be read")
+	}
+	_, err = ReadTrimmed(bytes.NewReader(compressed), testSRSSize+1)
+	expectErr(t, err, "but the circuit needs")
+}
+
+func TestTrimmedCache(t *testing.T) {
+	s, content, checksum := testSRS(t)
+	srsPath := filepath.Join(t.TempDir(), "srs")
+	if err := os.WriteFile(srsPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, expectedLagrange, err := generateLagrange(s, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := &TrimmedCache{Dir: dir, Source: &FileProvider{Path: srsPath, Sha256: checksum}}
+	check := func() {
+		t.Helper()
+		canonical, lagrange, err := c.LagrangeSRS(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(canonical.(*kzg_bn254.SRS).Pk.G1, s.Pk.G1[:11]) || !reflect.DeepEqual(canonical.(*kzg_bn254.SRS).Vk, s.Vk) {
+			t.Fatal("canonical srs does not match")
+		}
+		if !reflect.DeepEqual(lagrange, expectedLagrange) {
+			t.Fatal("lagrange srs does not match")
+		}
+	}
+	check()
+	path, err := c.File(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	// the trimmed srs is read from the cache from now on
+	if err = os.Remove(srsPath); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	_, _, err = c.LagrangeSRS(16)
+	if err == nil {
+		t.Fatal("expected the source to be read for a new size")
+	}
+
+	// a file trimmed from another srs is never used
+	other, otherContent, _ := testSRSOf(t, big.NewInt(-2))
+	otherPath := filepath.Join(t.TempDir(), "srs")
+	if err = os.WriteFile(otherPath, otherContent, 0644); err != nil {
+		t.Fatal(err)
+	}
+	otherCache := &TrimmedCache{Dir: dir, Source: &FileProvider{Path: otherPath}}
+	otherFile, err := otherCache.File(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherFile == path {
+		t.Fatal("expected the trimmed files of two srs to differ")
+	}
+	trimmed, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(otherFile, trimmed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = readTrimmedFile(otherFile, mustSourceID(t, otherCache), 8)
+	expectErr(t, err, "was trimmed from another srs")
+	canonical, _, err := otherCache.LagrangeSRS(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(canonical.(*kzg_bn254.SRS).Pk.G1, other.Pk.G1[:11]) {
+		t.Fatal("expected the srs to be extracted again from its source")
+	}
+	check()
+
+	// sources that are not files are trimmed in memory
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &squareCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir = t.TempDir()
+	memoryCache := &TrimmedCache{Dir: dir, Source: &MemoryProvider{Canonical: s}}
+	canonical, _, err = Trim(ccs, memoryCache.Source, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(canonical.(*kzg_bn254.SRS).Pk.G1) != 5 {
+		t.Fatalf("unexpected canonical size %d", len(canonical.(*kzg_bn254.SRS).Pk.G1))
+	}
+	path, err = memoryCache.File(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	// the srs is identified by its verifying key when it has no checksum
+	if err = os.WriteFile(srsPath, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if mustSourceID(t, memoryCache) != mustSourceID(t, &TrimmedCache{Source: &FileProvider{Path: srsPath}}) {
+		t.Fatal("expected the same id for the same srs")
+	}
+}
+
+func mustSourceID(t *testing.T, c *TrimmedCache) string {
+	t.Helper()
+	id, err := c.sourceID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.18;
+
+// Code generated by brevis-sdk. DO NOT EDIT.
+library TestOutput {
+    struct UsersItem {
+        address user;
+        uint64 amount;
+    }
+
+    struct Output {
+        bytes32 salt;
+        uint248 sumVolume;
+        UsersItem[] users;
+        bool ok;
+    }
+
+    function decodeOutput(bytes calldata o) internal pure returns (Output memory out) {
+        out.salt = bytes32(o[0:32]);
+        out.sumVolume = uint248(bytes31(o[32:63]));
+        uint256 p = 63;
+        uint256 n;
+        n = uint32(bytes4(o[p:p + 4]));
+        p += 4;
+        out.users = new UsersItem[](n);
+        for (uint256 i = 0; i < n; i++) {
+            out.users[i].user = address(bytes20(o[p:p + 20]));
+            out.users[i].amount = uint64(bytes8(o[p + 20:p + 20 + 8]));
+            p += 28;
+        }
+        out.ok = o[p] != 0;
+        p += 1;
+        require(o.length == p, "invalid output length");
+    }
+}
@@ -0,0 +1,11 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.18;
+
+import "./TestOutput.sol";
+
+// TestOutputDecoder exposes the decoder generated for the test output schema
+contract TestOutputDecoder {
+    function decode(bytes calldata o) external pure returns (TestOutput.Output memory) {
+        return TestOutput.decodeOutput(o);
+    }
+}
@@ -0,0 +1,197 @@
+package sdk
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+)
+
+// Allocation is the max number of receipts, storage slots and transactions of a
+// circuit, see AppCircuit.Allocate
+type Allocation struct {
+	MaxReceipts     int
+	MaxStorage      int
+	MaxTransactions int
+}
+
+// AllocationOf returns the allocation of the app circuit
+func AllocationOf(app AppCircuit) Allocation {
+	maxReceipts, maxStorage, maxTxs := app.Allocate()
+	return Allocation{maxReceipts, maxStorage, maxTxs}
+}
+
+// DataPoints returns the number of data points of the circuit, i.e. the number
+// of input commitments
+func (a Allocation) DataPoints() int {
+	return DataPointsNextPowerOf2(a.MaxReceipts + a.MaxStorage + a.MaxTransactions)
+}
+
+func (a Allocation) String() string {
+	return fmt.Sprintf("%d/%d/%d", a.MaxReceipts, a.MaxStorage, a.MaxTransactions)
+}
+
+func (a Allocation) fits(receipts, storage, txs int) bool {
+	return receipts <= a.MaxReceipts && storage <= a.MaxStorage && txs <= a.MaxTransactions
+}
+
+// AppCircuitWithTiers is an AppCircuit that can be compiled to circuits of
+// different sizes. Each allocation tier is a separate circuit with its own
+// proving key and vk hash, so a query with few data points doesn't pay the
+// proving cost of the largest allocation.
+//
+// BrevisApp.BuildCircuitInput picks the smallest tier that fits the added data,
+// and NewFullWitness and NewHostCircuit pick the tier of the circuit input. The
+// circuits of all tiers are compiled and set up with CompileTiers. Allocate is
+// still used by the functions that take a single circuit, e.g. Compile.
+//
+// Example:
+//
+//	func (c *AppCircuit) AllocationTiers() []sdk.Allocation {
+//		return []sdk.Allocation{{MaxReceipts: 32}, {MaxReceipts: 128}, {MaxReceipts: 1024}}
+//	}
+type AppCircuitWithTiers interface {
+	AppCircuit
+	AllocationTiers() []Allocation
+}
+
+// tierCircuit is an app circuit with the allocation of one of its tiers
+type tierCircuit struct {
+	AppCircuit
+	allocation Allocation
+}
+
+func (c *tierCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return c.allocation.MaxReceipts, c.allocation.MaxStorage, c.allocation.MaxTransactions
+}
+
+// Tiers returns the app circuit with the allocation of each of its tiers, from
+// the smallest to the largest. If the app circuit does not implement
+// AppCircuitWithTiers, the app circuit itself is the only tier.
+func Tiers(app AppCircuit) []AppCircuit {
+	tiered, ok := app.(AppCircuitWithTiers)
+	if !ok {
+		return []AppCircuit{app}
+	}
+	allocations := append([]Allocation{}, tiered.AllocationTiers()...)
+	// the proving cost is mostly determined by the number of data points
+	sort.SliceStable(allocations, func(i, j int) bool {
+		a, b := allocations[i], allocations[j]
+		if a.DataPoints() != b.DataPoints() {
+			return a.DataPoints() < b.DataPoints()
+		}
+		return a.MaxReceipts+a.MaxStorage+a.MaxTransactions < b.MaxReceipts+b.MaxStorage+b.MaxTransactions
+	})
+	ret := make([]AppCircuit, len(allocations))
+	for i, a := range allocations {
+		ret[i] = &tierCircuit{AppCircuit: tiered, allocation: a}
+	}
+	return ret
+}
+
+// TierOf returns the tier of the app circuit that the circuit input is built
+// for. The app circuit is returned as is if it does not implement
+// AppCircuitWithTiers or if none of its tiers matches the input.
+func TierOf(app AppCircuit, in CircuitInput) AppCircuit {
+	a := Allocation{len(in.Receipts.Raw), len(in.StorageSlots.Raw), len(in.Transactions.Raw)}
+	for _, tier := range Tiers(app) {
+		if AllocationOf(tier) == a {
+			return tier
+		}
+	}
+	return app
+}
+
+// smallestTier returns the smallest tier of the app circuit that fits the
+// number of data of each type, or the largest tier if none does
+func smallestTier(app AppCircuit, receipts, storage, txs int) AppCircuit {
+	tiers := Tiers(app)
+	for _, tier := range tiers {
+		if AllocationOf(tier).fits(receipts, storage, txs) {
+			return tier
+		}
+	}
+	return tiers[len(tiers)-1]
+}
+
+// untiered returns the app circuit of the user if app is a tier of it
+func untiered(app AppCircuit) AppCircuit {
+	if tier, ok := app.(*tierCircuit); ok {
+		return tier.AppCircuit
+	}
+	return app
+}
+
+// TierDir returns the directory of the compilation outputs of a tier under
+// compileOutDir
+func TierDir(compileOutDir string, a Allocation) string {
+	return filepath.Join(compileOutDir, fmt.Sprintf("tier_%d_%d_%d", a.MaxReceipts, a.MaxStorage, a.MaxTransactions))
+}
+
+// TierSetup is the compilation and setup output of an allocation tier
+type TierSetup struct {
+	Allocation Allocation
+	// Circuit is the app circuit with the allocation of the tier
+	Circuit AppCircuit
+	CCS     constraint.ConstraintSystem
+	PK      plonk.ProvingKey
+	VK      plonk.VerifyingKey
+	VkHash  []byte
+}
+
+// CompileTiers compiles and sets up the circuit of each allocation tier of the
+// app circuit (see AppCircuitWithTiers), and saves the outputs of each tier
+// under TierDir(compileOutDir, tier). The vk hashes of all tiers are printed at
+// the end: each of them must be accepted by the app contract.
+func CompileTiers(app AppCircuit, compileOutDir, srsDir string, hashInfo *BrevisHashInfo) ([]*TierSetup, error) {
+	var ret []*TierSetup
+	for _, tier := range Tiers(app) {
+		a := AllocationOf(tier)
+		fmt.Printf(">> tier %s\n", a)
+		ccs, pk, vk, vkHash, err := CompileWithHashInfo(tier, TierDir(compileOutDir, a), srsDir, hashInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile tier %s: %s", a, err.Error())
+		}
+		ret = append(ret, &TierSetup{Allocation: a, Circuit: tier, CCS: ccs, PK: pk, VK: vk, VkHash: vkHash})
+	}
+	printTierVkHashes(ret)
+	return ret, nil
+}
+
+// ReadTiersFrom reads the outputs of CompileTiers
+func ReadTiersFrom(app AppCircuit, compileOutDir string, hashInfo *BrevisHashInfo) ([]*TierSetup, error) {
+	var ret []*TierSetup
+	for _, tier := range Tiers(app) {
+		a := AllocationOf(tier)
+		ccs, pk, vk, vkHash, err := ReadSetupFromWithHashInfo(tier, TierDir(compileOutDir, a), hashInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tier %s: %s", a, err.Error())
+		}
+		ret = append(ret, &TierSetup{Allocation: a, Circuit: tier, CCS: ccs, PK: pk, VK: vk, VkHash: vkHash})
+	}
+	printTierVkHashes(ret)
+	return ret, nil
+}
+
+// SetupOf returns the setup of the tier that the circuit input is built for
+func SetupOf(setups []*TierSetup, in CircuitInput) (*TierSetup, error) {
+	a := Allocation{len(in.Receipts.Raw), len(in.StorageSlots.Raw), len(in.Transactions.Raw)}
+	for _, s := range setups {
+		if s.Allocation == a {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no tier with allocation %s (receipts/storage/transactions)", a)
+}
+
+func printTierVkHashes(setups []*TierSetup) {
+	fmt.Println("///////////////////////////////////////////////////////////////////////////////")
+	fmt.Println("// vk hashes of the allocation tiers (receipts/storage/transactions)")
+	for _, s := range setups {
+		fmt.Printf("// %s: 0x%x\n", s.Allocation, s.VkHash)
+	}
+	fmt.Println("///////////////////////////////////////////////////////////////////////////////")
+	fmt.Println()
+}
@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAllocationTiers(t *testing.T) {
+	app := &TestTiersCircuit{}
+	tiers := Tiers(app)
+	if len(tiers) != 2 || AllocationOf(tiers[0]) != (Allocation{MaxReceipts: 32}) || AllocationOf(tiers[1]) != (Allocation{MaxReceipts: 128}) {
+		t.Fatalf("unexpected tiers %v", tiers)
+	}
+	if tiers := Tiers(&TestHintsCircuit{}); len(tiers) != 1 || AllocationOf(tiers[0]) != (Allocation{4, 0, 0}) {
+		t.Fatalf("an app circuit without tiers should be its only tier, got %v", tiers)
+	}
+
+	for _, c := range []struct {
+		name     string
+		receipts int
+		pinned   int
+		expected int
+	}{
+		{"few receipts", 3, -1, 32},
+		{"all of the smallest tier", 32, -1, 32},
+		{"more than the smallest tier", 40, -1, 128},
+		{"pinned beyond the smallest tier", 3, 100, 128},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			q := NewMockBrevisApp()
+			for i := 0; i < c.receipts; i++ {
+				q.AddMockReceipt(testTiersReceipt(i))
+			}
+			if c.pinned >= 0 {
+				q.AddMockReceipt(testTiersReceipt(c.pinned), c.pinned)
+			}
+			in, err := q.BuildMockCircuitInput(app)
+			check(err)
+			if len(in.Receipts.Raw) != c.expected {
+				t.Fatalf("expected tier of %d receipts, got %d", c.expected, len(in.Receipts.Raw))
+			}
+			tier := TierOf(app, in)
+			if AllocationOf(tier).MaxReceipts != c.expected {
+				t.Fatalf("TierOf returned %s", AllocationOf(tier))
+			}
+			err = test.IsSolved(DefaultHostCircuit(tier), NewHostCircuit(in.Clone(), app), ecc.BN254.ScalarField())
+			check(err)
+		})
+	}
+
+	q := NewMockBrevisApp()
+	for i := 0; i < 129; i++ {
+		q.AddReceipt(ReceiptData{TxHash: common.BigToHash(big.NewInt(int64(i)))})
+	}
+	_, err := q.BuildCircuitInputStage1(app)
+	if err == nil {
+		t.Fatal("expected the data not to fit in any tier")
+	}
+	t.Log(err)
+}
+
+func testTiersReceipt(i int) ReceiptData {
+	return ReceiptData{
+		BlockNum:     big.NewInt(100),
+		BlockBaseFee: big.NewInt(1),
+		MptKeyPath:   big.NewInt(int64(i)),
+		Fields:       []LogFieldData{{LogPos: 1, Value: common.BigToHash(big.NewInt(int64(i)))}},
+	}
+}
+
+type TestTiersCircuit struct{}
+
+func (c *TestTiersCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 128, 0, 0
+}
+
+func (c *TestTiersCircuit) AllocationTiers() []Allocation {
+	return []Allocation{{MaxReceipts: 128}, {MaxReceipts: 32}}
+}
+
+func (c *TestTiersCircuit) Define(api *CircuitAPI, in DataInput) error {
+	receipts := NewDataStream(api, in.Receipts)
+	api.OutputUint(248, Sum(Map(receipts, func(r Receipt) Uint248 {
+		return api.ToUint248(r.Fields[0].Value)
+	})))
+	return nil
+}
@@ -3,6 +3,7 @@ package sdk
 import (
 	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 
 	"github.com/consensys/gnark/frontend"
@@ -387,3 +388,119 @@ func (t Tuple8[F0, F1, F2, F3, F4, F5, F6, F7]) NumVars() uint32 {
 func (t Tuple8[F0, F1, F2, F3, F4, F5, F6, F7]) String() string {
 	return fmt.Sprintf("(%s, %s, %s, %s, %s, %s, %s, %s)", t.F0, t.F1, t.F2, t.F3, t.F4, t.F5, t.F6, t.F7)
 }
+
+// Struct derives CircuitVariable for any struct type T whose exported fields are
+// SDK circuit types (Uint248, Bytes32, etc.), other types implementing
+// CircuitVariable, nested structs of such types, or fixed size arrays of such
+// types. Fields are flattened in declaration order. Unexported fields are
+// ignored. This allows using custom domain records in DataStream without having
+// to hand-implement Values, FromValues, NumVars and String. Example:
+//
+//	type Trade struct {
+//		Trader Uint248
+//		Amounts [2]Uint248
+//		Meta struct{ BlockNum Uint32 }
+//	}
+//	trades := Map(receipts, func(r Receipt) Struct[Trade] {
+//		return Struct[Trade]{Val: Trade{...}}
+//	})
+//	volume := Sum(Map(trades, func(t Struct[Trade]) Uint248 { return t.Val.Amounts[0] }))
+//
+// Panics on the first use if T contains fields of unsupported types, such as
+// slices, maps, pointers or plain Go numbers.
+type Struct[T any] struct {
+	Val T
+}
+
+var _ CircuitVariable = Struct[struct{}]{}
+
+var circuitVariableType = reflect.TypeOf((*CircuitVariable)(nil)).Elem()
+
+func (s Struct[T]) Values() []frontend.Variable {
+	var ret []frontend.Variable
+	walkCircuitVariables(reflect.ValueOf(&s.Val).Elem(), func(_ reflect.Value, v CircuitVariable) {
+		ret = append(ret, v.Values()...)
+	})
+	return ret
+}
+
+func (s Struct[T]) FromValues(vs ...frontend.Variable) CircuitVariable {
+	if n := s.NumVars(); len(vs) != int(n) {
+		panic(fmt.Sprintf("Struct[%T].FromValues takes %d params, got %d", s.Val, n, len(vs)))
+	}
+	start := uint32(0)
+	walkCircuitVariables(reflect.ValueOf(&s.Val).Elem(), func(field reflect.Value, v CircuitVariable) {
+		end := start + v.NumVars()
+		field.Set(reflect.ValueOf(v.FromValues(vs[start:end]...)))
+		start = end
+	})
+	return s
+}
+
+func (s Struct[T]) NumVars() uint32 {
+	sum := uint32(0)
+	walkCircuitVariables(reflect.ValueOf(&s.Val).Elem(), func(_ reflect.Value, v CircuitVariable) {
+		sum += v.NumVars()
+	})
+	return sum
+}
+
+func (s Struct[T]) String() string {
+	return circuitVariableString(reflect.ValueOf(s.Val))
+}
+
+// walkCircuitVariables calls visit on each CircuitVariable found in v in
+// declaration order, descending into structs and arrays. v must be addressable
+// if visit sets the fields.
+func walkCircuitVariables(v reflect.Value, visit func(field reflect.Value, v CircuitVariable)) {
+	if v.Kind() != reflect.Struct {
+		panic(fmt.Errorf("cannot derive CircuitVariable for non-struct type %s", v.Type()))
+	}
+	walkField(v, v.Type().Name(), visit)
+}
+
+func walkField(v reflect.Value, path string, visit func(field reflect.Value, v CircuitVariable)) {
+	if v.Type().Implements(circuitVariableType) {
+		visit(v, v.Interface().(CircuitVariable))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			walkField(v.Field(i), path+"."+f.Name, visit)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkField(v.Index(i), fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+	default:
+		panic(fmt.Errorf("cannot derive CircuitVariable for field %s: unsupported type %s", path, v.Type()))
+	}
+}
+
+func circuitVariableString(v reflect.Value) string {
+	if v.Type().Implements(circuitVariableType) {
+		return v.Interface().(CircuitVariable).String()
+	}
+	var strs []string
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if f.IsExported() {
+				strs = append(strs, fmt.Sprintf("%s: %s", f.Name, circuitVariableString(v.Field(i))))
+			}
+		}
+		return fmt.Sprintf("{%s}", strings.Join(strs, ", "))
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			strs = append(strs, circuitVariableString(v.Index(i)))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(strs, ", "))
+	}
+	panic(fmt.Errorf("cannot format unsupported type %s", v.Type()))
+}
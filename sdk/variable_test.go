@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type testTrade struct {
+	Trader  Uint248
+	Token   Bytes32
+	Amounts [2]Uint248
+	Meta    testTradeMeta
+	Legs    [2]testTradeMeta
+	Tuple   Tuple2[Uint32, Uint64]
+	ignored int
+}
+
+type testTradeMeta struct {
+	BlockNum Uint32
+	Fee      Uint521
+}
+
+func newTestTrade(i int) testTrade {
+	return testTrade{
+		Trader:  ConstUint248(i),
+		Token:   ConstFromBigEndianBytes([]byte{byte(i), 1}),
+		Amounts: [2]Uint248{ConstUint248(10 * i), ConstUint248(20 * i)},
+		Meta:    testTradeMeta{BlockNum: ConstUint32(100 + i), Fee: ConstUint521(1000 + i)},
+		Legs: [2]testTradeMeta{
+			{BlockNum: ConstUint32(1), Fee: ConstUint521(2)},
+			{BlockNum: ConstUint32(3), Fee: ConstUint521(4)},
+		},
+		Tuple: Tuple2[Uint32, Uint64]{F0: ConstUint32(5), F1: ConstUint64(6)},
+	}
+}
+
+func TestStruct(t *testing.T) {
+	s := Struct[testTrade]{Val: newTestTrade(1)}
+	// 1 + 2 + 2 + (1 + 6) + 2 * (1 + 6) + 2
+	if n := s.NumVars(); n != 28 {
+		t.Fatalf("expected 28 vars, got %d", n)
+	}
+	values := s.Values()
+	if len(values) != 28 {
+		t.Fatalf("expected 28 values, got %d", len(values))
+	}
+	restored := Struct[testTrade]{}.FromValues(values...).(Struct[testTrade])
+	if restored.String() != s.String() {
+		t.Fatalf("expected %s, got %s", s, restored)
+	}
+	expected := "{Trader: 1, Token: 0000000000000000000000000000000000000000000000000000000000000101, " +
+		"Amounts: [10, 20], Meta: {BlockNum: 101, Fee: 1001}, " +
+		"Legs: [{BlockNum: 1, Fee: 2}, {BlockNum: 3, Fee: 4}], Tuple: (5, 6)}"
+	if s.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, s)
+	}
+
+	c := &TestStructCircuit{}
+	err := test.IsSolved(c, c, ecc.BN254.ScalarField())
+	check(err)
+}
+
+func TestStructUnsupported(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on unsupported field type")
+		}
+	}()
+	Struct[struct{ Amounts []Uint248 }]{}.NumVars()
+}
+
+type TestStructCircuit struct{}
+
+func (c *TestStructCircuit) Define(g frontend.API) error {
+	api := NewCircuitAPI(g)
+	u248 := api.Uint248
+
+	in := NewDataStream(api, DataPoints[Uint248]{
+		Raw:     newU248s(1, 2, 3, 4),
+		Toggles: []frontend.Variable{1, 1, 0, 1},
+	})
+	trades := Map(in, func(cur Uint248) Struct[testTrade] {
+		trade := newTestTrade(0)
+		trade.Trader = cur
+		trade.Amounts[0] = u248.Mul(cur, newU248(10))
+		trade.Meta.Fee = api.ToUint521(cur)
+		return Struct[testTrade]{Val: trade}
+	})
+
+	// reduce over the derived struct type, which goes through FromValues
+	largest := MaxGeneric(trades, Struct[testTrade]{Val: newTestTrade(0)}, func(a, b Struct[testTrade]) Uint248 {
+		return u248.IsGreaterThan(a.Val.Amounts[0], b.Val.Amounts[0])
+	})
+	u248.AssertIsEqual(largest.Val.Trader, newU248(4))
+	u248.AssertIsEqual(largest.Val.Amounts[0], newU248(40))
+	api.Uint521.AssertIsEqual(largest.Val.Meta.Fee, ConstUint521(4))
+
+	volume := Sum(Map(trades, func(cur Struct[testTrade]) Uint248 { return cur.Val.Amounts[0] }))
+	u248.AssertIsEqual(volume, newU248(70))
+	return nil
+}
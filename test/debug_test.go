@@ -0,0 +1,83 @@
+package test
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TestDebugSolve checks that sdk.DebugSolve reports the index of the receipt
+// that fails an assertion of the app circuit
+func TestDebugSolve(t *testing.T) {
+	for _, c := range []struct {
+		name      string
+		blockNums []int64
+		values    []int64
+		context   string
+	}{
+		{"unsorted", []int64{100, 101, 99, 102}, []int64{1, 2, 3, 4}, "AssertSorted on receipt #2"},
+		{"over bound", []int64{100, 101, 102, 103}, []int64{1, 2000, 3, 4}, "AssertEach on receipt #1"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			app := sdk.NewMockBrevisApp()
+			for i, blockNum := range c.blockNums {
+				app.AddMockReceipt(sdk.ReceiptData{
+					BlockNum:     big.NewInt(blockNum),
+					BlockBaseFee: big.NewInt(1),
+					MptKeyPath:   big.NewInt(int64(i)),
+					Fields: []sdk.LogFieldData{
+						{Contract: common.HexToAddress("0x01"), LogPos: 0, Value: common.BigToHash(big.NewInt(c.values[i]))},
+					},
+				})
+			}
+			// the dry run of BuildMockCircuitInput fails on the assertions, so the
+			// input is built without them
+			in, err := app.BuildMockCircuitInput(&debugAppCircuit{skipAsserts: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			circuit := &debugAppCircuit{}
+			report, err := sdk.DebugSolve(circuit, circuit, in)
+			if err == nil {
+				t.Fatal("expected the circuit to fail")
+			}
+			if report == nil {
+				t.Fatalf("expected a debug report, got error %s", err.Error())
+			}
+			if contexts := strings.Join(report.Contexts, " > "); contexts != c.context {
+				t.Errorf("expected contexts %q, got %q:\n%s", c.context, contexts, report)
+			}
+		})
+	}
+}
+
+// debugAppCircuit asserts that the receipts are sorted by block number and that
+// the value of every receipt is at most 1000
+type debugAppCircuit struct {
+	skipAsserts bool
+}
+
+var _ sdk.AppCircuit = &debugAppCircuit{}
+
+func (c *debugAppCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 32, 0, 0
+}
+
+func (c *debugAppCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
+	u248 := api.Uint248
+	receipts := sdk.NewDataStream(api, in.Receipts)
+	api.OutputUint(64, api.ToUint248(sdk.Count(receipts)))
+	if c.skipAsserts {
+		return nil
+	}
+	sdk.AssertSorted(receipts, func(a, b sdk.Receipt) sdk.Uint248 {
+		return u248.IsLessThan(api.ToUint248(a.BlockNum), api.ToUint248(b.BlockNum))
+	})
+	sdk.AssertEach(receipts, func(r sdk.Receipt) sdk.Uint248 {
+		return u248.Not(u248.IsGreaterThan(api.ToUint248(r.Fields[0].Value), sdk.ConstUint248(1000)))
+	})
+	return nil
+}
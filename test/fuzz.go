@@ -0,0 +1,360 @@
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/test"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// MockData records the mock data of a fuzzing case. Generators add data with
+// the same methods as sdk.BrevisApp, and reference implementations read it back
+// in the order it is assigned to the circuit input.
+type MockData struct {
+	receipts     []mockItem[sdk.ReceiptData]
+	storage      []mockItem[sdk.StorageData]
+	transactions []mockItem[sdk.TransactionData]
+}
+
+type mockItem[T any] struct {
+	data T
+	// the index the data is pinned at, or -1
+	index int
+}
+
+func newMockItem[T any](data T, index []int) mockItem[T] {
+	if len(index) > 1 {
+		panic("no more than one index should be supplied")
+	}
+	if len(index) == 1 {
+		return mockItem[T]{data, index[0]}
+	}
+	return mockItem[T]{data, -1}
+}
+
+// AddMockReceipt records a receipt, see sdk.BrevisApp.AddMockReceipt
+func (d *MockData) AddMockReceipt(data sdk.ReceiptData, index ...int) {
+	d.receipts = append(d.receipts, newMockItem(data, index))
+}
+
+// AddMockStorage records a storage slot, see sdk.BrevisApp.AddMockStorage
+func (d *MockData) AddMockStorage(data sdk.StorageData, index ...int) {
+	d.storage = append(d.storage, newMockItem(data, index))
+}
+
+// AddMockTransaction records a transaction, see sdk.BrevisApp.AddMockTransaction
+func (d *MockData) AddMockTransaction(data sdk.TransactionData, index ...int) {
+	d.transactions = append(d.transactions, newMockItem(data, index))
+}
+
+// Receipts returns the receipts in the order they are assigned to
+// DataInput.Receipts
+func (d *MockData) Receipts() []sdk.ReceiptData {
+	return mockList(d.receipts)
+}
+
+// StorageSlots returns the storage slots in the order they are assigned to
+// DataInput.StorageSlots
+func (d *MockData) StorageSlots() []sdk.StorageData {
+	return mockList(d.storage)
+}
+
+// Transactions returns the transactions in the order they are assigned to
+// DataInput.Transactions
+func (d *MockData) Transactions() []sdk.TransactionData {
+	return mockList(d.transactions)
+}
+
+// mockList orders the items like sdk.BrevisApp: pinned items at their index,
+// and the others in the remaining slots in the order they are added
+func mockList[T any](items []mockItem[T]) []T {
+	pinned := map[int]T{}
+	var ordered []T
+	for _, item := range items {
+		if item.index >= 0 {
+			pinned[item.index] = item.data
+		} else {
+			ordered = append(ordered, item.data)
+		}
+	}
+	var l []T
+	for i := 0; len(pinned) > 0 || len(ordered) > 0; i++ {
+		if data, ok := pinned[i]; ok {
+			l = append(l, data)
+			delete(pinned, i)
+		} else if len(ordered) > 0 {
+			l = append(l, ordered[0])
+			ordered = ordered[1:]
+		}
+	}
+	return l
+}
+
+func (d *MockData) addTo(app *sdk.BrevisApp) {
+	for _, r := range d.receipts {
+		app.AddMockReceipt(r.data, pinnedIndex(r.index)...)
+	}
+	for _, s := range d.storage {
+		app.AddMockStorage(s.data, pinnedIndex(s.index)...)
+	}
+	for _, tx := range d.transactions {
+		app.AddMockTransaction(tx.data, pinnedIndex(tx.index)...)
+	}
+}
+
+func pinnedIndex(index int) []int {
+	if index < 0 {
+		return nil
+	}
+	return []int{index}
+}
+
+func (d *MockData) String() string {
+	b := &strings.Builder{}
+	for _, r := range d.receipts {
+		fmt.Fprintf(b, "receipt%s: block %v, fields %+v\n", pinnedString(r.index), r.data.BlockNum, r.data.Fields)
+	}
+	for _, s := range d.storage {
+		fmt.Fprintf(b, "storage%s: block %v, address %s, slot %s, value %s\n", pinnedString(s.index), s.data.BlockNum, s.data.Address, s.data.Slot, s.data.Value)
+	}
+	for _, tx := range d.transactions {
+		fmt.Fprintf(b, "transaction%s: block %v, hash %s\n", pinnedString(tx.index), tx.data.BlockNum, tx.data.Hash)
+	}
+	return b.String()
+}
+
+func pinnedString(index int) string {
+	if index < 0 {
+		return ""
+	}
+	return fmt.Sprintf(" #%d", index)
+}
+
+// FuzzOption configures DifferentialFuzz
+type FuzzOption func(*fuzzConfig)
+
+type fuzzConfig struct {
+	cases        int
+	seed         int64
+	shrinkBudget int
+}
+
+// WithFuzzCases sets the number of random cases, 20 by default
+func WithFuzzCases(n int) FuzzOption {
+	return func(c *fuzzConfig) { c.cases = n }
+}
+
+// WithFuzzSeed sets the seed of the random source given to the generator, 0 by
+// default so that failures are reproducible
+func WithFuzzSeed(seed int64) FuzzOption {
+	return func(c *fuzzConfig) { c.seed = seed }
+}
+
+// WithShrinkBudget sets the maximum number of cases tried when shrinking a
+// failing case, 200 by default
+func WithShrinkBudget(n int) FuzzOption {
+	return func(c *fuzzConfig) { c.shrinkBudget = n }
+}
+
+// DifferentialFuzz checks the application circuit against a Go reference
+// implementation of the same logic. For each case, gen adds random mock data,
+// the circuit input is built with sdk.BrevisApp.BuildMockCircuitInput and
+// solved like IsSolved, and the circuit output must equal the output of
+// reference, i.e. the abi.encodePacked encoding of the expected outputs.
+//
+// The first failing case is shrunk to a minimal one by removing data and
+// halving values while it still fails, and reported along with its seed.
+//
+// Example:
+//
+//	test.DifferentialFuzz(t, &AppCircuit{}, &AppCircuit{},
+//		func(d *test.MockData) []byte {
+//			sum := new(big.Int)
+//			for _, r := range d.Receipts() {
+//				sum.Add(sum, r.Fields[0].Value.Big())
+//			}
+//			return common.LeftPadBytes(sum.Bytes(), 31)
+//		},
+//		func(r *rand.Rand, d *test.MockData) {
+//			for i := 0; i < r.Intn(10); i++ {
+//				d.AddMockReceipt(sdk.ReceiptData{...})
+//			}
+//		})
+func DifferentialFuzz(
+	t *testing.T,
+	circuit, assign sdk.AppCircuit,
+	reference func(d *MockData) []byte,
+	gen func(r *rand.Rand, d *MockData),
+	opts ...FuzzOption,
+) {
+	failure := differentialFuzz(circuit, assign, reference, gen, opts...)
+	if failure != nil {
+		t.Fatal(failure)
+	}
+}
+
+// FuzzFailure describes a shrunk failing case of DifferentialFuzz
+type FuzzFailure struct {
+	// Seed is the seed of the case before shrinking
+	Seed int64
+	// Data is the shrunk data
+	Data *MockData
+	// Err is why the shrunk case fails
+	Err error
+}
+
+func (f *FuzzFailure) Error() string {
+	return fmt.Sprintf("differential fuzzing failed with seed %d: %s\nshrunk case:\n%s", f.Seed, f.Err.Error(), f.Data)
+}
+
+func differentialFuzz(
+	circuit, assign sdk.AppCircuit,
+	reference func(d *MockData) []byte,
+	gen func(r *rand.Rand, d *MockData),
+	opts ...FuzzOption,
+) *FuzzFailure {
+	config := &fuzzConfig{cases: 20, shrinkBudget: 200}
+	for _, opt := range opts {
+		opt(config)
+	}
+	run := func(d *MockData) error {
+		return runFuzzCase(circuit, assign, reference, d)
+	}
+	for i := 0; i < config.cases; i++ {
+		seed := config.seed + int64(i)
+		d := &MockData{}
+		gen(rand.New(rand.NewSource(seed)), d)
+		err := run(d)
+		if err == nil {
+			continue
+		}
+		d, err = shrink(d, err, run, config.shrinkBudget)
+		return &FuzzFailure{Seed: seed, Data: d, Err: err}
+	}
+	return nil
+}
+
+func runFuzzCase(circuit, assign sdk.AppCircuit, reference func(d *MockData) []byte, d *MockData) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	app := sdk.NewMockBrevisApp()
+	d.addTo(app)
+	in, err := app.BuildMockCircuitInput(assign)
+	if err != nil {
+		return err
+	}
+	host := sdk.DefaultHostCircuit(sdk.TierOf(circuit, in))
+	assignment := sdk.NewHostCircuit(in.Clone(), assign)
+	err = test.IsSolved(host, assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return fmt.Errorf("circuit not satisfied: %s", err.Error())
+	}
+	expected := reference(d)
+	output := in.GetAbiPackedOutput()
+	if !bytes.Equal(output, expected) {
+		return fmt.Errorf("circuit output %x, reference output %x", output, expected)
+	}
+	return nil
+}
+
+// shrink returns the smallest case found that still fails, along with its error
+func shrink(d *MockData, err error, run func(d *MockData) error, budget int) (*MockData, error) {
+	for improved := true; improved && budget > 0; {
+		improved = false
+		for _, c := range d.shrinkCandidates() {
+			if budget == 0 {
+				break
+			}
+			budget--
+			if cErr := run(c); cErr != nil {
+				d, err, improved = c, cErr, true
+				break
+			}
+		}
+	}
+	return d, err
+}
+
+// shrinkCandidates returns smaller versions of the case, from the most to the
+// least aggressive: without chunks of data, then with values halved
+func (d *MockData) shrinkCandidates() []*MockData {
+	var ret []*MockData
+	n := len(d.receipts) + len(d.storage) + len(d.transactions)
+	for size := n / 2; size >= 1; size /= 2 {
+		for start := 0; start+size <= n; start += size {
+			ret = append(ret, d.without(start, start+size))
+		}
+	}
+	for i := range d.receipts {
+		for j, f := range d.receipts[i].data.Fields {
+			if v, ok := halve(f.Value); ok {
+				fields := append([]sdk.LogFieldData{}, d.receipts[i].data.Fields...)
+				fields[j].Value = v
+				c := d.clone()
+				c.receipts[i].data.Fields = fields
+				ret = append(ret, c)
+			}
+		}
+	}
+	for i := range d.storage {
+		if v, ok := halve(d.storage[i].data.Value); ok {
+			c := d.clone()
+			c.storage[i].data.Value = v
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// halve returns h/2, and false if h is already zero
+func halve(h common.Hash) (common.Hash, bool) {
+	v := h.Big()
+	if v.Sign() == 0 {
+		return h, false
+	}
+	return common.BigToHash(v.Rsh(v, 1)), true
+}
+
+// without returns a copy of the case without the items in [start, end) of the
+// concatenation of receipts, storage slots and transactions
+func (d *MockData) without(start, end int) *MockData {
+	c := &MockData{}
+	i := 0
+	keep := func() bool {
+		i++
+		return i-1 < start || i-1 >= end
+	}
+	for _, r := range d.receipts {
+		if keep() {
+			c.receipts = append(c.receipts, r)
+		}
+	}
+	for _, s := range d.storage {
+		if keep() {
+			c.storage = append(c.storage, s)
+		}
+	}
+	for _, tx := range d.transactions {
+		if keep() {
+			c.transactions = append(c.transactions, tx)
+		}
+	}
+	return c
+}
+
+func (d *MockData) clone() *MockData {
+	return &MockData{
+		receipts:     append([]mockItem[sdk.ReceiptData]{}, d.receipts...),
+		storage:      append([]mockItem[sdk.StorageData]{}, d.storage...),
+		transactions: append([]mockItem[sdk.TransactionData]{}, d.transactions...),
+	}
+}
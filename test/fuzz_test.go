@@ -0,0 +1,105 @@
+package test
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDifferentialFuzz(t *testing.T) {
+	sum := func(d *MockData) []byte {
+		s := new(big.Int)
+		for _, r := range d.Receipts() {
+			s.Add(s, r.Fields[0].Value.Big())
+		}
+		return common.LeftPadBytes(s.Bytes(), 31)
+	}
+	DifferentialFuzz(t, &sumCircuit{}, &sumCircuit{}, sum, genReceipts, WithFuzzCases(3))
+	// the inputs are built for the smallest tier, not the allocation
+	DifferentialFuzz(t, &tieredSumCircuit{}, &tieredSumCircuit{}, sum, genReceipts, WithFuzzCases(3))
+
+	// a reference that disagrees with the circuit on values of at least 1000
+	buggy := func(d *MockData) []byte {
+		s := new(big.Int)
+		for _, r := range d.Receipts() {
+			if v := r.Fields[0].Value.Big(); v.Cmp(big.NewInt(1000)) < 0 {
+				s.Add(s, v)
+			}
+		}
+		return common.LeftPadBytes(s.Bytes(), 31)
+	}
+	failure := differentialFuzz(&sumCircuit{}, &sumCircuit{}, buggy, genReceipts, WithFuzzCases(3))
+	if failure == nil {
+		t.Fatal("expected fuzzing to fail")
+	}
+	t.Log(failure)
+	// shrunk to a single receipt with the smallest value halving can reach
+	receipts := failure.Data.Receipts()
+	if len(receipts) != 1 || !strings.Contains(failure.Err.Error(), "reference output") {
+		t.Fatalf("unexpected shrunk case %s", failure)
+	}
+	if v := receipts[0].Fields[0].Value.Big(); v.Cmp(big.NewInt(1000)) < 0 || v.Cmp(big.NewInt(2000)) >= 0 {
+		t.Errorf("expected value in [1000, 2000), got %s", v)
+	}
+}
+
+func TestMockDataOrder(t *testing.T) {
+	d := &MockData{}
+	for i := 0; i < 3; i++ {
+		d.AddMockReceipt(sdk.ReceiptData{BlockNum: big.NewInt(int64(i))})
+	}
+	d.AddMockReceipt(sdk.ReceiptData{BlockNum: big.NewInt(10)}, 1)
+	var blocks []int64
+	for _, r := range d.Receipts() {
+		blocks = append(blocks, r.BlockNum.Int64())
+	}
+	if len(blocks) != 4 || blocks[0] != 0 || blocks[1] != 10 || blocks[2] != 1 || blocks[3] != 2 {
+		t.Errorf("unexpected order %v", blocks)
+	}
+}
+
+func genReceipts(r *rand.Rand, d *MockData) {
+	n := 1 + r.Intn(8)
+	for i := 0; i < n; i++ {
+		d.AddMockReceipt(sdk.ReceiptData{
+			BlockNum:     big.NewInt(int64(100 + i)),
+			BlockBaseFee: big.NewInt(1),
+			MptKeyPath:   big.NewInt(int64(i)),
+			Fields: []sdk.LogFieldData{{
+				LogPos: uint(i),
+				Value:  common.BigToHash(big.NewInt(r.Int63n(5000))),
+			}},
+		})
+	}
+}
+
+type sumCircuit struct{}
+
+func (c *sumCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 32, 0, 0
+}
+
+func (c *sumCircuit) Define(api *sdk.CircuitAPI, in sdk.DataInput) error {
+	receipts := sdk.NewDataStream(api, in.Receipts)
+	values := sdk.Map(receipts, func(r sdk.Receipt) sdk.Uint248 {
+		return api.ToUint248(r.Fields[0].Value)
+	})
+	api.OutputUint(248, sdk.Sum(values))
+	return nil
+}
+
+type tieredSumCircuit struct {
+	sumCircuit
+}
+
+func (c *tieredSumCircuit) Allocate() (maxReceipts, maxStorage, maxTransactions int) {
+	return 64, 0, 0
+}
+
+func (c *tieredSumCircuit) AllocationTiers() []sdk.Allocation {
+	return []sdk.Allocation{{MaxReceipts: 32}, {MaxReceipts: 64}}
+}
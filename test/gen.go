@@ -0,0 +1,110 @@
+package test
+
+import (
+	"math/big"
+	"math/rand"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+)
+
+// The generators below return random values for property-based tests of
+// circuits. Half of the values are picked from the boundaries of the type, e.g.
+// 0, 1, the largest value and the powers of two around it, so that overflow and
+// sign edge cases come up in every few cases. They take a *rand.Rand so that
+// they compose with the generators given to DifferentialFuzz.
+
+// RandUint returns a random value in [0, 2^bits)
+func RandUint(r *rand.Rand, bits int) *big.Int {
+	if r.Intn(2) == 0 {
+		return pick(r, UintBoundaries(bits))
+	}
+	// uniformly random bit length so that small values are as likely as large
+	n := 1 + r.Intn(bits)
+	return new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(n)))
+}
+
+// RandUint32 returns a random value in the range of sdk.Uint32
+func RandUint32(r *rand.Rand) *big.Int { return RandUint(r, 32) }
+
+// RandUint64 returns a random value in the range of sdk.Uint64
+func RandUint64(r *rand.Rand) *big.Int { return RandUint(r, 64) }
+
+// RandUint248 returns a random value in the range of sdk.Uint248, i.e. [0,
+// sdk.MaxUint248]
+func RandUint248(r *rand.Rand) *big.Int { return RandUint(r, 248) }
+
+// RandBytes32 returns a random value in the range of sdk.Bytes32
+func RandBytes32(r *rand.Rand) *big.Int { return RandUint(r, 256) }
+
+// RandUint521 returns a random value in the range of sdk.Uint521, i.e. [0,
+// 2^521-1)
+func RandUint521(r *rand.Rand) *big.Int {
+	v := RandUint(r, 521)
+	// 2^521-1 is the modulus of Uint521 and the only value of 521 bits it cannot
+	// hold
+	if v.Cmp(Uint521Modulus) >= 0 {
+		v.Sub(v, big.NewInt(1))
+	}
+	return v
+}
+
+// RandInt248 returns a random value in the range of sdk.Int248, i.e. [-2^247,
+// 2^247)
+func RandInt248(r *rand.Rand) *big.Int {
+	if r.Intn(2) == 0 {
+		return pick(r, Int248Boundaries())
+	}
+	v := RandUint(r, 247)
+	if r.Intn(2) == 0 {
+		// -v-1 covers [-2^247, -1]
+		v.Neg(v).Sub(v, big.NewInt(1))
+	}
+	return v
+}
+
+// RandBit returns 0 or 1
+func RandBit(r *rand.Rand) *big.Int {
+	return big.NewInt(int64(r.Intn(2)))
+}
+
+// RandNonZero returns a value of gen that is not zero
+func RandNonZero(r *rand.Rand, gen func(r *rand.Rand) *big.Int) *big.Int {
+	for {
+		if v := gen(r); v.Sign() != 0 {
+			return v
+		}
+	}
+}
+
+// Uint521Modulus is the modulus of the sdk.Uint521 arithmetic, 2^521-1
+var Uint521Modulus = sdk.Uint521Field{}.Modulus()
+
+// UintBoundaries returns the edge cases of unsigned integers of the bit size:
+// 0, 1, 2, 2^bits-1, 2^bits-2, and 2^k-1, 2^k and 2^k+1 for k = bits/2 and
+// k = bits-1
+func UintBoundaries(bits int) []*big.Int {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(bits)), big.NewInt(1))
+	ret := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), max, new(big.Int).Sub(max, big.NewInt(1))}
+	for _, k := range []int{bits / 2, bits - 1} {
+		p := new(big.Int).Lsh(big.NewInt(1), uint(k))
+		ret = append(ret, new(big.Int).Sub(p, big.NewInt(1)), p, new(big.Int).Add(p, big.NewInt(1)))
+	}
+	return ret
+}
+
+// Int248Boundaries returns the edge cases of sdk.Int248: 0, ±1, ±2, the
+// largest and smallest values and the values next to them
+func Int248Boundaries() []*big.Int {
+	p := new(big.Int).Lsh(big.NewInt(1), 247)
+	max := new(big.Int).Sub(p, big.NewInt(1))
+	min := new(big.Int).Neg(p)
+	return []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(-1), big.NewInt(2), big.NewInt(-2),
+		max, new(big.Int).Sub(max, big.NewInt(1)),
+		min, new(big.Int).Add(min, big.NewInt(1)),
+	}
+}
+
+func pick(r *rand.Rand, vs []*big.Int) *big.Int {
+	return new(big.Int).Set(vs[r.Intn(len(vs))])
+}
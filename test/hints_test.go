@@ -0,0 +1,113 @@
+package test
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/examples/balance"
+	"github.com/brevis-network/brevis-sdk/examples/dummy"
+	"github.com/brevis-network/brevis-sdk/examples/slot"
+	"github.com/brevis-network/brevis-sdk/examples/tokenTransfer"
+	"github.com/brevis-network/brevis-sdk/examples/tradingvolume"
+	"github.com/brevis-network/brevis-sdk/examples/twap"
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TestExampleHints checks that the hints of the example circuits are
+// sufficiently constrained, using mock data that satisfies each circuit
+func TestExampleHints(t *testing.T) {
+	user := common.HexToAddress("0xaefB31e9EEee2822f4C1cBC13B70948b0B5C0b3c")
+	for _, c := range []struct {
+		name   string
+		assign sdk.AppCircuit
+		data   func(d *MockData)
+	}{
+		{"balance", &balance.AppCircuit{}, func(d *MockData) {
+			balanceSlot := crypto.Keccak256(
+				common.LeftPadBytes(common.HexToAddress("0xB14a13ddaEa5df325732DB991F1A766ae0DbD75a").Bytes(), 32),
+				common.LeftPadBytes([]byte{2}, 32))
+			for _, v := range []int64{1000, 3000} {
+				d.AddMockStorage(sdk.StorageData{
+					BlockNum:     big.NewInt(100),
+					BlockBaseFee: big.NewInt(1),
+					Address:      common.HexToAddress("0xdac17f958d2ee523a2206206994597c13d831ec7"),
+					Slot:         common.BytesToHash(balanceSlot),
+					Value:        common.BigToHash(big.NewInt(v)),
+				})
+			}
+		}},
+		{"dummy", dummy.DefaultAppCircuit(), func(d *MockData) {}},
+		{"slot", &slot.AppCircuit{}, func(d *MockData) {
+			d.AddMockStorage(sdk.StorageData{
+				BlockNum:     big.NewInt(100),
+				BlockBaseFee: big.NewInt(1),
+				Address:      common.HexToAddress("0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc"),
+				Value:        common.BytesToHash(user.Bytes()),
+			}, 1)
+		}},
+		{"tokenTransfer", &tokenTransfer.AppCircuit{}, func(d *MockData) {
+			usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+			d.AddMockReceipt(sdk.ReceiptData{
+				BlockNum:     big.NewInt(100),
+				BlockBaseFee: big.NewInt(1),
+				MptKeyPath:   big.NewInt(0),
+				Fields: []sdk.LogFieldData{
+					{Contract: usdc, LogPos: 1, IsTopic: true, FieldIndex: 1, Value: common.BytesToHash(user.Bytes())},
+					{Contract: usdc, LogPos: 1, Value: common.BigToHash(big.NewInt(600000000))},
+				},
+			}, 0)
+		}},
+		{"tradingvolume", &tradingvolume.AppCircuit{UserAddr: sdk.ConstUint248(user)}, func(d *MockData) {
+			pool := common.HexToAddress("0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640")
+			swap := common.HexToHash("0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67")
+			transfer := common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+			for i, amount := range []int64{500, -700} {
+				d.AddMockReceipt(sdk.ReceiptData{
+					BlockNum:     big.NewInt(int64(100 + i)),
+					BlockBaseFee: big.NewInt(1),
+					MptKeyPath:   big.NewInt(int64(i)),
+					Fields: []sdk.LogFieldData{
+						{Contract: pool, EventID: swap, LogPos: 1, Value: common.BigToHash(int256(amount))},
+						{Contract: pool, EventID: swap, LogPos: 1, IsTopic: true, FieldIndex: 2, Value: common.BytesToHash(user.Bytes())},
+						{Contract: common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), EventID: transfer, LogPos: 2, IsTopic: true, FieldIndex: 1},
+					},
+				})
+			}
+		}},
+		{"twap", &twap.AppCircuit{}, func(d *MockData) {
+			pair := common.HexToAddress("0xb4e16d0168e52d35cacd2c6185b44281ec28c9dc")
+			for i, block := range []int64{100, 200} {
+				// blockTimestampLast is packed in the highest 32 bits of the slot
+				timestamp := new(big.Int).Lsh(big.NewInt(1700000000+int64(i)*1200), 224)
+				for j, value := range []*big.Int{timestamp, big.NewInt(1000 + int64(i)*600000), big.NewInt(2000 + int64(i)*900000)} {
+					d.AddMockStorage(sdk.StorageData{
+						BlockNum:     big.NewInt(block),
+						BlockBaseFee: big.NewInt(1),
+						Address:      pair,
+						Slot:         common.BigToHash(big.NewInt(int64(8 + j))),
+						Value:        common.BigToHash(value),
+					})
+				}
+			}
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			d := &MockData{}
+			c.data(d)
+			app := sdk.NewMockBrevisApp()
+			d.addTo(app)
+			in, err := app.BuildMockCircuitInput(c.assign)
+			if err != nil {
+				t.Fatal(err)
+			}
+			CheckHints(t, c.assign, in)
+		})
+	}
+}
+
+// int256 returns the two's complement of v in 256 bits
+func int256(v int64) *big.Int {
+	return new(big.Int).Mod(big.NewInt(v), new(big.Int).Lsh(big.NewInt(1), 256))
+}
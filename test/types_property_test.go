@@ -0,0 +1,664 @@
+package test
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/brevis-network/brevis-sdk/sdk"
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/std/math/emulated"
+	"github.com/consensys/gnark/test"
+)
+
+// The tests below check each operation of the circuit type APIs against its
+// math/big semantics on random values. Every case is solved both by the test
+// engine and by the compiled PLONK constraint system, and both must be
+// satisfied exactly when the reference says the inputs are valid, with the
+// outputs of the reference.
+
+func TestUint32Properties(t *testing.T) {
+	checkProperties(t, uintProperties("Uint32", kUint32, 32, func(api *sdk.CircuitAPI) uintAPI[sdk.Uint32] { return api.Uint32 }))
+}
+
+func TestUint64Properties(t *testing.T) {
+	checkProperties(t, uintProperties("Uint64", kUint64, 64, func(api *sdk.CircuitAPI) uintAPI[sdk.Uint64] { return api.Uint64 }))
+}
+
+func TestUint248Properties(t *testing.T) {
+	ps := uintProperties("Uint248", kUint248, 248, func(api *sdk.CircuitAPI) uintAPI[sdk.Uint248] { return api.Uint248 })
+	ps = append(ps, &property{
+		name: "Uint248.AssertIsLessOrEqual", in: kinds(kUint248, 2), gen: genPair(RandUint248),
+		define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+			api.Uint248.AssertIsLessOrEqual(as[sdk.Uint248](in[0]), as[sdk.Uint248](in[1]))
+			return nil
+		},
+		ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) <= 0 },
+	})
+	checkProperties(t, ps)
+}
+
+func TestInt248Properties(t *testing.T) {
+	two128 := new(big.Int).Lsh(big.NewInt(1), 128)
+	checkProperties(t, []*property{
+		{
+			name: "Int248.IsEqual", in: kinds(kInt248, 2), gen: genPair(RandInt248), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.IsEqual(as[sdk.Int248](in[0]), as[sdk.Int248](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) == 0)), true },
+		},
+		{
+			name: "Int248.IsLessThan", in: kinds(kInt248, 2), gen: genPair(RandInt248), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.IsLessThan(as[sdk.Int248](in[0]), as[sdk.Int248](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) < 0)), true },
+		},
+		{
+			name: "Int248.IsGreaterThan", in: kinds(kInt248, 2), gen: genPair(RandInt248), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.IsGreaterThan(as[sdk.Int248](in[0]), as[sdk.Int248](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) > 0)), true },
+		},
+		{
+			name: "Int248.IsZero", in: kinds(kInt248, 1), gen: genN(1, RandInt248), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.IsZero(as[sdk.Int248](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Sign() == 0)), true },
+		},
+		{
+			name: "Int248.ABS", in: kinds(kInt248, 1), gen: genN(1, RandInt248), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.ABS(as[sdk.Int248](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(new(big.Int).Abs(v[0])), true },
+		},
+		{
+			name: "Int248.Select", in: []kind{kUint248, kInt248, kInt248}, gen: genSelect(RandInt248), outs: 1, out: kInt248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Int248.Select(as[sdk.Uint248](in[0]), as[sdk.Int248](in[1]), as[sdk.Int248](in[2])))
+			},
+			ref: refSelect,
+		},
+		{
+			name: "Int248.ToBinary", in: kinds(kInt248, 1), gen: genN(1, RandInt248), outs: 248, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return list(api.Int248.ToBinary(as[sdk.Int248](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return bitsOf(kInt248.unsigned(v[0]), 248), true },
+		},
+		{
+			name: "Int248.FromBinary", in: kinds(kInt248, 1), gen: genN(1, RandInt248), outs: 2, out: kInt248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				bits := api.Int248.ToBinary(as[sdk.Int248](in[0]))
+				// fewer bits are sign extended
+				return vars(api.Int248.FromBinary(bits...), api.Int248.FromBinary(bits[:128]...))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) {
+				low := new(big.Int).Mod(v[0], two128)
+				if low.Bit(127) == 1 {
+					low.Sub(low, two128)
+				}
+				return ints(v[0], low), true
+			},
+		},
+		{
+			name: "Int248.AssertIsEqual", in: kinds(kInt248, 2), gen: genPair(RandInt248),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Int248.AssertIsEqual(as[sdk.Int248](in[0]), as[sdk.Int248](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) == 0 },
+		},
+		{
+			name: "Int248.AssertIsDifferent", in: kinds(kInt248, 2), gen: genPair(RandInt248),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Int248.AssertIsDifferent(as[sdk.Int248](in[0]), as[sdk.Int248](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) != 0 },
+		},
+	})
+}
+
+func TestUint521Properties(t *testing.T) {
+	m := Uint521Modulus
+	two256 := new(big.Int).Lsh(big.NewInt(1), 256)
+	checkProperties(t, []*property{
+		{
+			name: "Uint521.Add", in: kinds(kUint521, 2), gen: genPair(RandUint521), outs: 1, out: kUint521,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.Add(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(mod(new(big.Int).Add(v[0], v[1]), m)), true },
+		},
+		{
+			name: "Uint521.Sub", in: kinds(kUint521, 2), gen: genPair(RandUint521), outs: 1, out: kUint521,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.Sub(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(mod(new(big.Int).Sub(v[0], v[1]), m)), true },
+		},
+		{
+			name: "Uint521.Mul", in: kinds(kUint521, 2), gen: genPair(RandUint521), outs: 1, out: kUint521,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.Mul(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(mod(new(big.Int).Mul(v[0], v[1]), m)), true },
+		},
+		{
+			name: "Uint521.Select", in: []kind{kUint248, kUint521, kUint521}, gen: genSelect(RandUint521), outs: 1, out: kUint521,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.Select(as[sdk.Uint248](in[0]), as[sdk.Uint521](in[1]), as[sdk.Uint521](in[2])))
+			},
+			ref: refSelect,
+		},
+		{
+			name: "Uint521.IsEqual", in: kinds(kUint521, 2), gen: genPair(RandUint521), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.IsEqual(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) == 0)), true },
+		},
+		{
+			name: "Uint521.ToBinary", in: kinds(kUint521, 1), gen: genN(1, RandUint521), outs: 256, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return list(api.Uint521.ToBinary(as[sdk.Uint521](in[0]), 256))
+			},
+			// the bits above n must be zero
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return bitsOf(v[0], 256), v[0].Cmp(two256) < 0 },
+		},
+		{
+			name: "Uint521.FromBinary", in: kinds(kUint521, 1), gen: genN(1, RandUint521), outs: 1, out: kUint521,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Uint521.FromBinary(api.Uint521.ToBinary(as[sdk.Uint521](in[0]), 521)...))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(v[0]), true },
+		},
+		{
+			name: "Uint521.AssertIsEqual", in: kinds(kUint521, 2), gen: genPair(RandUint521),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Uint521.AssertIsEqual(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) == 0 },
+		},
+		{
+			name: "Uint521.AssertIsLessOrEqual", in: kinds(kUint521, 2), gen: genPair(RandUint521),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Uint521.AssertIsLessOrEqual(as[sdk.Uint521](in[0]), as[sdk.Uint521](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) <= 0 },
+		},
+	})
+}
+
+func TestBytes32Properties(t *testing.T) {
+	two100 := new(big.Int).Lsh(big.NewInt(1), 100)
+	field := ecc.BN254.ScalarField()
+	checkProperties(t, []*property{
+		{
+			name: "Bytes32.ToBinary", in: kinds(kBytes32, 1), gen: genN(1, RandBytes32), outs: 256, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return list(api.Bytes32.ToBinary(as[sdk.Bytes32](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return bitsOf(v[0], 256), true },
+		},
+		{
+			name: "Bytes32.FromBinary", in: kinds(kBytes32, 1), gen: genN(1, RandBytes32), outs: 2, out: kBytes32,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				bits := api.Bytes32.ToBinary(as[sdk.Bytes32](in[0]))
+				// fewer bits are zero extended
+				return vars(api.Bytes32.FromBinary(bits...), api.Bytes32.FromBinary(bits[:100]...))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(v[0], new(big.Int).Mod(v[0], two100)), true },
+		},
+		{
+			name: "Bytes32.FromFV", in: kinds(kUint248, 1), outs: 1, out: kBytes32,
+			gen: func(r *rand.Rand) []*big.Int { return ints(mod(RandUint(r, 254), field)) },
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Bytes32.FromFV(in[0][0]))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(v[0]), true },
+		},
+		{
+			name: "Bytes32.IsEqual", in: kinds(kBytes32, 2), gen: genPair(RandBytes32), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Bytes32.IsEqual(as[sdk.Bytes32](in[0]), as[sdk.Bytes32](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) == 0)), true },
+		},
+		{
+			name: "Bytes32.IsZero", in: kinds(kBytes32, 1), gen: genN(1, RandBytes32), outs: 1, out: kUint248,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Bytes32.IsZero(as[sdk.Bytes32](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Sign() == 0)), true },
+		},
+		{
+			name: "Bytes32.Select", in: []kind{kUint248, kBytes32, kBytes32}, gen: genSelect(RandBytes32), outs: 1, out: kBytes32,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(api.Bytes32.Select(as[sdk.Uint248](in[0]), as[sdk.Bytes32](in[1]), as[sdk.Bytes32](in[2])))
+			},
+			ref: refSelect,
+		},
+		{
+			name: "Bytes32.AssertIsEqual", in: kinds(kBytes32, 2), gen: genPair(RandBytes32),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Bytes32.AssertIsEqual(as[sdk.Bytes32](in[0]), as[sdk.Bytes32](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) == 0 },
+		},
+		{
+			name: "Bytes32.AssertIsDifferent", in: kinds(kBytes32, 2), gen: genPair(RandBytes32),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				api.Bytes32.AssertIsDifferent(as[sdk.Bytes32](in[0]), as[sdk.Bytes32](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) != 0 },
+		},
+	})
+}
+
+// uintAPI is implemented by the APIs of the unsigned types that live in a
+// single field element
+type uintAPI[T sdk.CircuitVariable] interface {
+	FromBinary(vs ...T) T
+	ToBinary(v T, n int) sdk.List[T]
+	Add(a, b T, other ...T) T
+	Sub(a, b T) T
+	Mul(a, b T) T
+	Div(a, b T) (quotient, remainder T)
+	Sqrt(a T) T
+	IsZero(a T) T
+	IsEqual(a, b T) T
+	IsLessThan(a, b T) T
+	IsGreaterThan(a, b T) T
+	And(a, b T, other ...T) T
+	Or(a, b T, other ...T) T
+	Not(a T) T
+	Select(s T, a, b T) T
+	AssertIsEqual(a, b T)
+	AssertIsDifferent(a, b T)
+}
+
+// uintProperties returns the properties of the unsigned type T. The arithmetic
+// is that of the scalar field, i.e. Add and Mul don't wrap at 2^bits and Sub
+// wraps modulo the field
+func uintProperties[T sdk.CircuitVariable](name string, k kind, bits int, uapi func(api *sdk.CircuitAPI) uintAPI[T]) []*property {
+	p := ecc.BN254.ScalarField()
+	gen := func(r *rand.Rand) *big.Int { return RandUint(r, bits) }
+	half := new(big.Int).Lsh(big.NewInt(1), uint(bits/2))
+	return []*property{
+		{
+			name: name + ".Add", in: kinds(k, 3), gen: genN(3, gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Add(as[T](in[0]), as[T](in[1]), as[T](in[2])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) {
+				return ints(new(big.Int).Add(v[0], new(big.Int).Add(v[1], v[2]))), true
+			},
+		},
+		{
+			name: name + ".Sub", in: kinds(k, 2), gen: genPair(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Sub(as[T](in[0]), as[T](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(mod(new(big.Int).Sub(v[0], v[1]), p)), true },
+		},
+		{
+			name: name + ".Mul", in: kinds(k, 2), gen: genPair(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Mul(as[T](in[0]), as[T](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(mod(new(big.Int).Mul(v[0], v[1]), p)), true },
+		},
+		{
+			name: name + ".Div", in: kinds(k, 2), outs: 2, out: k,
+			gen: func(r *rand.Rand) []*big.Int {
+				// zero divisors in a quarter of the cases
+				if r.Intn(4) == 0 {
+					return ints(gen(r), big.NewInt(0))
+				}
+				return genPair(gen)(r)
+			},
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				q, r := uapi(api).Div(as[T](in[0]), as[T](in[1]))
+				return vars(q, r)
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) {
+				// division by zero is not satisfiable
+				if v[1].Sign() == 0 {
+					return nil, false
+				}
+				q, r := new(big.Int).QuoRem(v[0], v[1], new(big.Int))
+				return ints(q, r), true
+			},
+		},
+		{
+			name: name + ".Sqrt", in: kinds(k, 1), gen: genN(1, gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Sqrt(as[T](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(new(big.Int).Sqrt(v[0])), true },
+		},
+		{
+			name: name + ".IsZero", in: kinds(k, 1), gen: genN(1, gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).IsZero(as[T](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Sign() == 0)), true },
+		},
+		{
+			name: name + ".IsEqual", in: kinds(k, 2), gen: genPair(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).IsEqual(as[T](in[0]), as[T](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) == 0)), true },
+		},
+		{
+			name: name + ".IsLessThan", in: kinds(k, 2), gen: genPair(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).IsLessThan(as[T](in[0]), as[T](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) < 0)), true },
+		},
+		{
+			name: name + ".IsGreaterThan", in: kinds(k, 2), gen: genPair(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).IsGreaterThan(as[T](in[0]), as[T](in[1])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Cmp(v[1]) > 0)), true },
+		},
+		{
+			name: name + ".And", in: kinds(k, 3), gen: genN(3, RandBit), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).And(as[T](in[0]), as[T](in[1]), as[T](in[2])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) {
+				return ints(new(big.Int).And(v[0], new(big.Int).And(v[1], v[2]))), true
+			},
+		},
+		{
+			name: name + ".Or", in: kinds(k, 3), gen: genN(3, RandBit), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Or(as[T](in[0]), as[T](in[1]), as[T](in[2])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) {
+				return ints(new(big.Int).Or(v[0], new(big.Int).Or(v[1], v[2]))), true
+			},
+		},
+		{
+			name: name + ".Not", in: kinds(k, 1), gen: genN(1, RandBit), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Not(as[T](in[0])))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(boolInt(v[0].Sign() == 0)), true },
+		},
+		{
+			name: name + ".Select", in: kinds(k, 3), gen: genSelect(gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return vars(uapi(api).Select(as[T](in[0]), as[T](in[1]), as[T](in[2])))
+			},
+			ref: refSelect,
+		},
+		{
+			name: name + ".ToBinary", in: kinds(k, 1), gen: genN(1, gen), outs: bits / 2, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				return list(uapi(api).ToBinary(as[T](in[0]), bits/2))
+			},
+			// the value must fit in n bits
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return bitsOf(v[0], bits/2), v[0].Cmp(half) < 0 },
+		},
+		{
+			name: name + ".FromBinary", in: kinds(k, 1), gen: genN(1, gen), outs: 1, out: k,
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				u := uapi(api)
+				return vars(u.FromBinary(u.ToBinary(as[T](in[0]), bits)...))
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return ints(v[0]), true },
+		},
+		{
+			name: name + ".AssertIsEqual", in: kinds(k, 2), gen: genPair(gen),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				uapi(api).AssertIsEqual(as[T](in[0]), as[T](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) == 0 },
+		},
+		{
+			name: name + ".AssertIsDifferent", in: kinds(k, 2), gen: genPair(gen),
+			define: func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable {
+				uapi(api).AssertIsDifferent(as[T](in[0]), as[T](in[1]))
+				return nil
+			},
+			ref: func(v []*big.Int) ([]*big.Int, bool) { return nil, v[0].Cmp(v[1]) != 0 },
+		},
+	}
+}
+
+// property relates a circuit operation to its math/big reference
+type property struct {
+	name string
+	in   []kind
+	gen  func(r *rand.Rand) []*big.Int
+	// the number and kind of the outputs
+	outs   int
+	out    kind
+	define func(api *sdk.CircuitAPI, in [][]frontend.Variable) []sdk.CircuitVariable
+	// ref returns the expected outputs, and false if the circuit must not be
+	// satisfied
+	ref func(in []*big.Int) ([]*big.Int, bool)
+}
+
+type propertyCircuit struct {
+	In  [][]frontend.Variable
+	Out [][]frontend.Variable
+	p   *property
+}
+
+func (c *propertyCircuit) Define(g frontend.API) error {
+	outs := c.p.define(sdk.NewCircuitAPI(g), c.In)
+	if len(outs) != len(c.Out) {
+		return fmt.Errorf("%s returns %d outputs, expected %d", c.p.name, len(outs), len(c.Out))
+	}
+	for i, out := range outs {
+		for j, v := range out.Values() {
+			g.AssertIsEqual(v, c.Out[i][j])
+		}
+	}
+	return nil
+}
+
+// assign returns the assignment for the inputs and outputs, or the placeholder
+// circuit if in is nil
+func (p *property) assign(in, out []*big.Int) *propertyCircuit {
+	c := &propertyCircuit{p: p}
+	for i, k := range p.in {
+		c.In = append(c.In, k.encode(nth(in, i)))
+	}
+	for i := 0; i < p.outs; i++ {
+		c.Out = append(c.Out, p.out.encode(nth(out, i)))
+	}
+	return c
+}
+
+func nth(vs []*big.Int, i int) *big.Int {
+	if i < len(vs) {
+		return vs[i]
+	}
+	return big.NewInt(0)
+}
+
+var propertyCases = 24
+
+func checkProperties(t *testing.T, ps []*property) {
+	cases := propertyCases
+	if testing.Short() {
+		cases = 4
+	}
+	for _, p := range ps {
+		t.Run(p.name, func(t *testing.T) { checkProperty(t, p, cases) })
+	}
+}
+
+func checkProperty(t *testing.T, p *property, cases int) {
+	field := ecc.BN254.ScalarField()
+	ccs, err := frontend.Compile(field, scs.NewBuilder, p.assign(nil, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < cases; i++ {
+		in := p.gen(r)
+		out, ok := p.ref(in)
+		assignment := p.assign(in, out)
+		engineErr := recoverErr(func() error {
+			return test.IsSolved(assignment, assignment, field)
+		})
+		plonkErr := recoverErr(func() error {
+			w, err := frontend.NewWitness(assignment, field)
+			if err != nil {
+				return err
+			}
+			return ccs.IsSolved(w)
+		})
+		for engine, err := range map[string]error{"test engine": engineErr, "plonk": plonkErr} {
+			if (err == nil) != ok {
+				t.Errorf("%s%v: expected satisfied %v with outputs %v, %s error: %v", p.name, in, ok, out, engine, err)
+			}
+		}
+	}
+}
+
+func recoverErr(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f()
+}
+
+// kind is the circuit type of a property input or output
+type kind int
+
+const (
+	kUint32 kind = iota
+	kUint64
+	kUint248
+	kInt248
+	kBytes32
+	kUint521
+)
+
+// encode returns the circuit variables of v
+func (k kind) encode(v *big.Int) []frontend.Variable {
+	switch k {
+	case kInt248:
+		return []frontend.Variable{k.unsigned(v)}
+	case kBytes32:
+		return sdk.ConstFromBigEndianBytes(v.Bytes()).Values()
+	case kUint521:
+		return emulated.ValueOf[sdk.Uint521Field](v).Limbs
+	}
+	return []frontend.Variable{v}
+}
+
+// unsigned returns the two's complement of v in 248 bits
+func (k kind) unsigned(v *big.Int) *big.Int {
+	return mod(v, new(big.Int).Lsh(big.NewInt(1), 248))
+}
+
+func kinds(k kind, n int) []kind {
+	ret := make([]kind, n)
+	for i := range ret {
+		ret[i] = k
+	}
+	return ret
+}
+
+// as converts the circuit variables of an input to T
+func as[T sdk.CircuitVariable](vs []frontend.Variable) T {
+	var t T
+	return t.FromValues(vs...).(T)
+}
+
+func vars(vs ...sdk.CircuitVariable) []sdk.CircuitVariable { return vs }
+
+func list[T sdk.CircuitVariable](l sdk.List[T]) []sdk.CircuitVariable {
+	ret := make([]sdk.CircuitVariable, len(l))
+	for i, v := range l {
+		ret[i] = v
+	}
+	return ret
+}
+
+func genN(n int, gen func(r *rand.Rand) *big.Int) func(r *rand.Rand) []*big.Int {
+	return func(r *rand.Rand) []*big.Int {
+		ret := make([]*big.Int, n)
+		for i := range ret {
+			ret[i] = gen(r)
+		}
+		return ret
+	}
+}
+
+// genPair generates two values that are equal in a quarter of the cases
+func genPair(gen func(r *rand.Rand) *big.Int) func(r *rand.Rand) []*big.Int {
+	return func(r *rand.Rand) []*big.Int {
+		a := gen(r)
+		if r.Intn(4) == 0 {
+			return ints(a, new(big.Int).Set(a))
+		}
+		return ints(a, gen(r))
+	}
+}
+
+// genSelect generates a selector in [0, 4) followed by two values
+func genSelect(gen func(r *rand.Rand) *big.Int) func(r *rand.Rand) []*big.Int {
+	return func(r *rand.Rand) []*big.Int {
+		return ints(RandUint(r, 2), gen(r), gen(r))
+	}
+}
+
+// refSelect is the reference of Select, which requires a boolean selector
+func refSelect(v []*big.Int) ([]*big.Int, bool) {
+	switch v[0].Int64() {
+	case 1:
+		return ints(v[1]), true
+	case 0:
+		return ints(v[2]), true
+	}
+	return nil, false
+}
+
+func ints(vs ...*big.Int) []*big.Int { return vs }
+
+func boolInt(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return big.NewInt(0)
+}
+
+func mod(v, m *big.Int) *big.Int {
+	return new(big.Int).Mod(v, m)
+}
+
+// bitsOf returns the n little-endian bits of v
+func bitsOf(v *big.Int, n int) []*big.Int {
+	ret := make([]*big.Int, n)
+	for i := range ret {
+		ret[i] = big.NewInt(int64(v.Bit(i)))
+	}
+	return ret
+}
@@ -13,10 +13,19 @@ import (
 // ProverSucceeded checks:
 // - a proof can be generated with the application circuit/assignment and the sdk generated circuit inputs.
 // - the generated proof can be verified.
+// If the circuit cannot be solved, the failing assertion is reported like in
+// IsSolved instead of proving.
 func ProverSucceeded(t *testing.T, circuit, assign sdk.AppCircuit, in sdk.CircuitInput, hints ...solver.Hint) {
-	host := sdk.DefaultHostCircuit(circuit)
+	host := sdk.DefaultHostCircuit(sdk.TierOf(circuit, in))
 	assignment := sdk.NewHostCircuit(in.Clone(), assign)
 
+	err := test.IsSolved(host, assignment, ecc.BN254.ScalarField(),
+		test.WithBackendProverOptions(backend.WithSolverOptions(solver.WithHints(hints...))))
+	if err != nil {
+		t.Error(err)
+		Debug(t, circuit, assign, in)
+		return
+	}
 	assert := test.NewAssert(t)
 	assert.ProverSucceeded(host, assignment, test.WithBackends(backend.PLONK), test.WithCurves(ecc.BN254), test.WithSolverOpts(solver.WithHints(hints...)))
 }
@@ -24,20 +33,63 @@ func ProverSucceeded(t *testing.T, circuit, assign sdk.AppCircuit, in sdk.Circui
 // ProverFailed checks:
 // - a proof cannot be generated with the application circuit & invalid assignment and the sdk generated circuit inputs.
 func ProverFailed(t *testing.T, circuit, assign sdk.AppCircuit, in sdk.CircuitInput) {
-	host := sdk.DefaultHostCircuit(circuit)
+	host := sdk.DefaultHostCircuit(sdk.TierOf(circuit, in))
 	assignment := sdk.NewHostCircuit(in.Clone(), assign)
 
 	assert := test.NewAssert(t)
 	assert.ProverFailed(host, assignment, test.WithBackends(backend.PLONK), test.WithCurves(ecc.BN254))
 }
 
-// IsSolved checks if the given application circuit/assignment and the input can be solved
+// IsSolved checks if the given application circuit/assignment and the input can be solved.
+// On failure, the circuit is solved again in debug mode to report the failing
+// assertion along with the SDK call and the data index that lead to it
 func IsSolved(t *testing.T, circuit, assign sdk.AppCircuit, in sdk.CircuitInput) {
-	host := sdk.DefaultHostCircuit(circuit)
+	host := sdk.DefaultHostCircuit(sdk.TierOf(circuit, in))
 	assignment := sdk.NewHostCircuit(in.Clone(), assign)
 
 	err := test.IsSolved(host, assignment, ecc.BN254.ScalarField())
 	if err != nil {
 		t.Error(err)
+		Debug(t, circuit, assign, in)
+	}
+}
+
+// Debug solves the application circuit in debug mode and logs the first failing
+// assertion, see sdk.DebugSolve
+func Debug(t *testing.T, circuit, assign sdk.AppCircuit, in sdk.CircuitInput) {
+	report, err := sdk.DebugSolve(circuit, assign, in)
+	if report != nil {
+		t.Logf("debug run:\n%s", report)
+	} else if err != nil {
+		t.Logf("debug run failed without a failing assertion: %s", err.Error())
+	}
+}
+
+// Emulate runs the application circuit in the emulator, which is much faster
+// than IsSolved but skips the input and output commitments, see sdk.Emulate.
+// Every failing assertion is reported. It returns the circuit output.
+func Emulate(t *testing.T, assign sdk.AppCircuit, in sdk.CircuitInput) []byte {
+	res, err := sdk.Emulate(assign, in)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	for _, f := range res.Failures {
+		t.Errorf("emulator:\n%s", f)
+	}
+	return res.Output
+}
+
+// CheckHints reports the hints called by the application circuit whose outputs
+// can be changed while the circuit is still satisfied, see sdk.AnalyzeHints.
+// The assignment and the input must satisfy the circuit.
+func CheckHints(t *testing.T, assign sdk.AppCircuit, in sdk.CircuitInput) {
+	hints, err := sdk.AnalyzeHints(assign, in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, h := range hints {
+		t.Error(h)
 	}
 }